@@ -33,19 +33,43 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+// String returns the source of the program, and likewise for every node:
+// source that parses back into the same tree, with every compound expression
+// in parentheses.
 func (p *Program) String() string {
-	var out bytes.Buffer
+	return statementsString(p.Statements)
+}
 
-	for _, s := range p.Statements {
-		out.WriteString(s.String())
+// statementsString returns the source of stmts, separated by semicolons.
+func statementsString(stmts []Statement) string {
+	strs := make([]string, len(stmts))
+	for i, s := range stmts {
+		strs[i] = s.String()
 	}
 
-	return out.String()
+	return strings.Join(strs, "; ")
 }
 
 type Identifier struct {
-	Token token.Token // the token.IDENT token
-	Value string
+	Token   token.Token // the token.IDENT token
+	Value   string
+	Binding *Binding // where the identifier is bound, set by the resolver
+}
+
+// Binding locates the variable an identifier refers to: the environment
+// Depth scopes out from where the identifier is evaluated, and the Slot of
+// that environment's frame holding the variable. Slot is -1 for a variable
+// looked up by name, such as a global.
+type Binding struct {
+	Depth int
+	Slot  int
+}
+
+// Scope lists the variables declared in a function, match arm, catch block,
+// for loop or select case, indexed by their slots. Each evaluation of the
+// scope gets a frame with a slot for each of them.
+type Scope struct {
+	Names []string
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -53,9 +77,10 @@ func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
 
 type LetStatement struct {
-	Token token.Token // the token.LET token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token // the token.LET token
+	Name    *Identifier
+	Pattern Expression // destructuring pattern, nil when binding a single Name
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -64,7 +89,13 @@ func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+
+	if ls.Pattern != nil {
+		out.WriteString(patternString(ls.Pattern))
+	} else {
+		out.WriteString(ls.Name.String())
+	}
+
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -90,11 +121,97 @@ func (rs *ReturnStatement) String() string {
 		out.WriteString(rs.ReturnValue.String())
 	}
 
-	out.WriteString(";")
+	return out.String()
+}
+
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
 
 	return out.String()
 }
 
+// ImportStatement binds the module at Path to Name.
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " " + is.Path.String() + " as " + is.Name.String()
+}
+
+// StructStatement declares a struct type, e.g. struct Point { x, y }.
+type StructStatement struct {
+	Token  token.Token // the 'struct' token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) String() string {
+	fields := []string{}
+	for _, field := range ss.Fields {
+		fields = append(fields, field.String())
+	}
+
+	return ss.TokenLiteral() + " " + ss.Name.String() + " { " + strings.Join(fields, ", ") + " }"
+}
+
+// EnumStatement declares an enum type, e.g. enum Shape { Circle(r), Empty }.
+type EnumStatement struct {
+	Token    token.Token // the 'enum' token
+	Name     *Identifier
+	Variants []*EnumVariant
+}
+
+func (es *EnumStatement) statementNode()       {}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) String() string {
+	variants := []string{}
+	for _, variant := range es.Variants {
+		variants = append(variants, variant.String())
+	}
+
+	return es.TokenLiteral() + " " + es.Name.String() + " { " + strings.Join(variants, ", ") + " }"
+}
+
+// EnumVariant is a variant of an enum declaration. Fields is nil for a unit
+// variant such as Empty.
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ev *EnumVariant) String() string {
+	if ev.Fields == nil {
+		return ev.Name.String()
+	}
+
+	fields := []string{}
+	for _, field := range ev.Fields {
+		fields = append(fields, field.String())
+	}
+
+	return ev.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -126,7 +243,65 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return "\"" + Escape(sl.Value) + "\"" }
+
+// Escape returns s as the contents of a string literal, escaping quotes,
+// backslashes, control characters and ${, which starts an interpolation.
+func Escape(s string) string {
+	var out strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(ch)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '$':
+			if i+1 < len(s) && s[i+1] == '{' {
+				out.WriteByte('\\')
+			}
+			out.WriteByte(ch)
+		default:
+			out.WriteByte(ch)
+		}
+	}
+
+	return out.String()
+}
+
+// InterpolatedString is a string literal with embedded expressions, e.g.
+// "Hello ${name}!". Parts holds the literal text as *StringLiterals
+// alternating with the embedded expressions, in source order.
+type InterpolatedString struct {
+	Token token.Token // the token.STRING_START token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+
+	for _, part := range is.Parts {
+		// An embedded string literal is a STRING token, unlike the text
+		if str, ok := part.(*StringLiteral); ok && str.Token.Type != token.STRING {
+			out.WriteString(Escape(str.Value))
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	out.WriteString("\"")
+
+	return out.String()
+}
 
 type PrefixExpression struct {
 	Token    token.Token // The prefix token, e.g. !
@@ -145,6 +320,19 @@ func (pe *PrefixExpression) String() string {
 	return out.String()
 }
 
+// PropagateExpression is the postfix ? operator: it unwraps an ok result or
+// returns an err result from the enclosing function.
+type PropagateExpression struct {
+	Token token.Token // the '?' token
+	Value Expression
+}
+
+func (pe *PropagateExpression) expressionNode()      {}
+func (pe *PropagateExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PropagateExpression) String() string {
+	return "(" + pe.Value.String() + "?)"
+}
+
 type InfixExpression struct {
 	Token    token.Token // The infix token. e.g. +, -,
 	Left     Expression
@@ -185,19 +373,151 @@ func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("if")
-	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
+	out.WriteString("if (")
 	out.WriteString(ie.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(blockString(ie.Consequence))
 
 	if ie.Alternative != nil {
-		out.WriteString("else ")
-		out.WriteString(ie.Alternative.String())
+		out.WriteString(" else ")
+
+		if ie.Alternative.Token.Type == token.IF {
+			// else if, whose if is the only statement of the block
+			out.WriteString(ie.Alternative.String())
+		} else {
+			out.WriteString(blockString(ie.Alternative))
+		}
 	}
 
 	return out.String()
 }
 
+// TryExpression evaluates Block and, if it raises an error, binds the error
+// to Param and evaluates Catch. Finally always runs afterwards. At least one
+// of Catch and Finally is present.
+type TryExpression struct {
+	Token   token.Token // the 'try' token
+	Block   *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+
+	CatchScope *Scope // the variables of the catch block, set by the resolver
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(blockString(te.Block))
+
+	if te.Catch != nil {
+		out.WriteString(" catch (" + te.Param.String() + ") ")
+		out.WriteString(blockString(te.Catch))
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(blockString(te.Finally))
+	}
+
+	return out.String()
+}
+
+// YieldExpression suspends the enclosing generator, producing Value from
+// it. It evaluates to the value passed to the next call of next, or null.
+type YieldExpression struct {
+	Token token.Token // the 'yield' token
+	Value Expression  // nil for a bare yield, which produces null
+}
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	if ye.Value == nil {
+		return "(yield)"
+	}
+	return "(yield " + ye.Value.String() + ")"
+}
+
+// ForExpression evaluates Body once for each element of Iterable, an array,
+// generator or channel, binding the element to Pattern.
+type ForExpression struct {
+	Token    token.Token // the 'for' token
+	Pattern  Expression  // an identifier or an array or hash pattern
+	Iterable Expression
+	Body     *BlockStatement
+	Scope    *Scope // the variables of the body, set by the resolver
+}
+
+func (fe *ForExpression) expressionNode()      {}
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForExpression) String() string {
+	return "for (" + patternString(fe.Pattern) + " in " + fe.Iterable.String() + ") " + blockString(fe.Body)
+}
+
+// SpawnExpression runs a call, or a function called without arguments, on
+// a new task, e.g. spawn worker(1) or spawn fn() { ... }.
+type SpawnExpression struct {
+	Token token.Token // the 'spawn' token
+	Call  Expression
+}
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpawnExpression) String() string       { return "(spawn " + se.Call.String() + ")" }
+
+// SelectExpression waits until one of its cases can proceed and evaluates
+// that case, or its default case when none can proceed right away.
+type SelectExpression struct {
+	Token token.Token // the 'select' token
+	Cases []*SelectCase
+}
+
+func (se *SelectExpression) expressionNode()      {}
+func (se *SelectExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectExpression) String() string {
+	cases := []string{}
+	for _, c := range se.Cases {
+		cases = append(cases, c.String())
+	}
+
+	return "select { " + strings.Join(cases, ", ") + " }"
+}
+
+// SelectCase is a single `operation [as name] => body` case of a select
+// expression. Operation is a recv(channel) or send(channel, value) call, or
+// nil for the default case _. Name binds the received value.
+type SelectCase struct {
+	Token     token.Token // the first token of the case
+	Operation *CallExpression
+	Name      *Identifier
+	Body      Expression // an expression or a *BlockStatement
+	Scope     *Scope     // the variables of the case, set by the resolver
+}
+
+func (sc *SelectCase) TokenLiteral() string { return sc.Token.Literal }
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+
+	if sc.Operation == nil {
+		out.WriteString("_")
+	} else {
+		out.WriteString(sc.Operation.String())
+	}
+
+	if sc.Name != nil {
+		out.WriteString(" as " + sc.Name.String())
+	}
+
+	out.WriteString(" => ")
+	out.WriteString(bodyString(sc.Body))
+
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -205,20 +525,46 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) expressionNode()      {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+
+// String returns the source of the statements of the block, without the
+// braces around them.
 func (bs *BlockStatement) String() string {
-	var out bytes.Buffer
+	return statementsString(bs.Statements)
+}
 
-	for _, s := range bs.Statements {
-		out.WriteString(s.String())
+// blockString returns the source of bs with its braces.
+func blockString(bs *BlockStatement) string {
+	if len(bs.Statements) == 0 {
+		return "{}"
 	}
+	return "{ " + bs.String() + " }"
+}
 
-	return out.String()
+// bodyString returns the source of the body following an =>: a block, or
+// an expression, in parentheses when it would start with a brace.
+func bodyString(body Expression) string {
+	switch body := body.(type) {
+	case *BlockStatement:
+		return blockString(body)
+	case *HashLiteral:
+		return "(" + body.String() + ")"
+	default:
+		return body.String()
+	}
 }
 
+// FunctionLiteral is a function, e.g. fn(x) { x }. A generator function,
+// written fn*, returns a generator running Body instead of running it. Name
+// is nil for an anonymous function.
 type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
+	Token      token.Token  // The 'fn' token
+	Name       *Identifier  // binds the function where it is declared, nil when anonymous
+	Generator  bool         // declared with fn*
+	Arrow      bool         // written as params => body
+	Parameters []Expression // Identifiers, destructuring patterns or DefaultParameters
+	Rest       *Identifier  // collects extra arguments, nil when not variadic
 	Body       *BlockStatement
+	Scope      *Scope // the parameters and variables of Body, set by the resolver
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -228,35 +574,383 @@ func (fl *FunctionLiteral) String() string {
 
 	params := []string{}
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		params = append(params, patternString(p))
+	}
+
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
+	}
+
+	if fl.Arrow {
+		// An arrow function extends as far right as it can
+		body := blockString(fl.Body)
+		if fl.Body.Token.Type != token.LBRACE && len(fl.Body.Statements) == 1 {
+			if stmt, ok := fl.Body.Statements[0].(*ExpressionStatement); ok {
+				body = bodyString(stmt.Expression)
+			}
+		}
+
+		return "((" + strings.Join(params, ", ") + ") => " + body + ")"
 	}
 
 	out.WriteString(fl.TokenLiteral())
+
+	if fl.Generator {
+		out.WriteString("*")
+	}
+
+	if fl.Name != nil {
+		out.WriteString(" " + fl.Name.String())
+	}
+
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
-	out.WriteString(fl.Body.String())
+	out.WriteString(blockString(fl.Body))
 
 	return out.String()
 }
 
+// MacroLiteral is a macro, e.g. macro(x) { quote(unquote(x) + 1) }. A
+// macro is called with the source of its arguments, quoted, and returns the
+// quoted source replacing the call.
+type MacroLiteral struct {
+	Token      token.Token // the 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	return ml.TokenLiteral() + "(" + strings.Join(params, ", ") + ") " + blockString(ml.Body)
+}
+
+// DefaultParameter is a function parameter with a default value, e.g. the
+// `y = 10` in `fn(x, y = 10)`. The default is evaluated at call time when
+// the argument is omitted.
+type DefaultParameter struct {
+	Token     token.Token // the '=' token
+	Parameter Expression
+	Default   Expression
+}
+
+func (dp *DefaultParameter) expressionNode()      {}
+func (dp *DefaultParameter) TokenLiteral() string { return dp.Token.Literal }
+func (dp *DefaultParameter) String() string {
+	return patternString(dp.Parameter) + " = " + dp.Default.String()
+}
+
+// SpreadExpression expands an array into separate call arguments or array
+// elements, e.g. `f(...xs)`.
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// NamedArgument passes a call argument by parameter name, e.g. `f(y: 2)`.
+type NamedArgument struct {
+	Token token.Token // the name token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string       { return na.Name.String() + ": " + na.Value.String() }
+
 type CallExpression struct {
-	Token     token.Token // The '(' token
-	Function  Expression  // Identifier or FunctionLiteral
-	Arguments []Expression
+	Token     token.Token  // The '(' token
+	Function  Expression   // Identifier or FunctionLiteral
+	Arguments []Expression // may contain SpreadExpressions and NamedArguments
+	Pipe      bool         // written as Arguments[0] |> Function(rest)
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+
+// IsCallTo reports whether ce calls the function named name, e.g. whether
+// quote(x) calls quote.
+func (ce *CallExpression) IsCallTo(name string) bool {
+	ident, ok := ce.Function.(*Identifier)
+	return ok && ident.Value == name
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
 	for _, a := range ce.Arguments {
 		args = append(args, a.String())
 	}
+	if ce.Pipe {
+		return "(" + args[0] + " |> " + ce.Function.String() + "(" + strings.Join(args[1:], ", ") + "))"
+	}
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
 	return out.String()
 }
+
+type ArrayLiteral struct {
+	Token    token.Token // the '[' token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token token.Token // The '[' token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// MemberExpression is a field access or, as the function of a call, a
+// method call, e.g. person.name or xs.join(", ").
+type MemberExpression struct {
+	Token    token.Token // The '.' token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Property.String() + ")"
+}
+
+// HashPair is a single key/value entry of a hash literal or hash pattern.
+// Pairs are kept in source order.
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+type HashLiteral struct {
+	Token token.Token // the '{' token
+	Pairs []HashPair
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// ArrayPattern destructures an array, e.g. `[a, b, ...rest]`. Elements are
+// themselves patterns and Rest, when present, collects the remaining items.
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []Expression
+	Rest     *Identifier
+}
+
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, patternString(el))
+	}
+
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashPattern destructures a hash, e.g. `{name, age: years}`. An identifier
+// key names a string key; the shorthand `name` binds it to an identifier of
+// the same name.
+type HashPattern struct {
+	Token token.Token // the '{' token
+	Pairs []HashPair
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		key, isIdent := pair.Key.(*Identifier)
+		value, isSame := pair.Value.(*Identifier)
+
+		if isIdent && isSame && key.Value == value.Value {
+			pairs = append(pairs, key.String())
+		} else {
+			pairs = append(pairs, pair.Key.String()+": "+patternString(pair.Value))
+		}
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// StructPattern matches instances of the struct Name whose fields match
+// Fields, e.g. Point{x, y: 0}.
+type StructPattern struct {
+	Token  token.Token // the struct name token
+	Name   *Identifier
+	Fields *HashPattern
+}
+
+func (sp *StructPattern) expressionNode()      {}
+func (sp *StructPattern) TokenLiteral() string { return sp.Token.Literal }
+func (sp *StructPattern) String() string       { return sp.Name.String() + sp.Fields.String() }
+
+// VariantPattern matches values of an enum variant whose fields match
+// Arguments, e.g. Circle(r) or Shape.Rect(w, _). Enum is nil for an
+// unqualified variant and Arguments is nil for a unit variant such as
+// Shape.Empty.
+type VariantPattern struct {
+	Token     token.Token // the first token of the pattern
+	Enum      *Identifier
+	Name      *Identifier
+	Arguments []Expression
+}
+
+func (vp *VariantPattern) expressionNode()      {}
+func (vp *VariantPattern) TokenLiteral() string { return vp.Token.Literal }
+func (vp *VariantPattern) String() string {
+	var out bytes.Buffer
+
+	if vp.Enum != nil {
+		out.WriteString(vp.Enum.String() + ".")
+	}
+
+	out.WriteString(vp.Name.String())
+
+	if vp.Arguments != nil {
+		args := []string{}
+		for _, arg := range vp.Arguments {
+			args = append(args, patternString(arg))
+		}
+
+		out.WriteString("(" + strings.Join(args, ", ") + ")")
+	}
+
+	return out.String()
+}
+
+type MatchExpression struct {
+	Token   token.Token // The 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, a := range me.Arms {
+		arms = append(arms, a.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// MatchArm is a single `pattern [if guard] => body` case of a match
+// expression. A pattern is a literal, the wildcard _, an identifier that
+// binds the matched value or an array or hash pattern.
+type MatchArm struct {
+	Token   token.Token // The first token of the pattern
+	Pattern Expression
+	Guard   Expression // nil when the arm has no guard
+	Body    Expression // an expression or a *BlockStatement
+	Scope   *Scope     // the variables of the arm, set by the resolver
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Token.Literal }
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(patternString(ma.Pattern))
+
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+
+	out.WriteString(" => ")
+	out.WriteString(bodyString(ma.Body))
+
+	return out.String()
+}
+
+// patternString returns the source of a pattern, which is the source of the
+// node but for a negative integer, whose parentheses a pattern can't have.
+func patternString(pattern Expression) string {
+	if prefix, ok := pattern.(*PrefixExpression); ok {
+		return prefix.Operator + prefix.Right.String()
+	}
+	return pattern.String()
+}
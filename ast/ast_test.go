@@ -12,6 +12,7 @@ func TestString(t *testing.T) {
 				Token: token.Token{Type: token.LET, Literal: "let"},
 				Name: &Identifier{
 					Token: token.Token{Type: token.IDENT, Literal: "myVar"},
+					Value: "myVar",
 				},
 				Value: &Identifier{
 					Token: token.Token{Type: token.IDENT, Literal: "anotherVar"},
@@ -21,7 +22,7 @@ func TestString(t *testing.T) {
 		},
 	}
 
-	if program.String() != "let myVar = anotherVar;" {
+	if program.String() != "let myVar = anotherVar" {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
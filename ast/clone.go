@@ -0,0 +1,54 @@
+package ast
+
+import "reflect"
+
+// Clone returns a deep copy of node sharing no nodes with it, so the copy can
+// be modified or resolved apart from node. Nodes shared within node, as the
+// name of a shorthand hash pattern is, stay shared within the copy.
+func Clone(node Node) Node {
+	return clone(reflect.ValueOf(node), map[interface{}]reflect.Value{}).Interface().(Node)
+}
+
+// clone copies v, reusing the copies of the pointers already copied.
+func clone(v reflect.Value, copies map[interface{}]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		if c, ok := copies[v.Interface()]; ok {
+			return c
+		}
+
+		c := reflect.New(v.Type().Elem())
+		copies[v.Interface()] = c
+		c.Elem().Set(clone(v.Elem(), copies))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Type()).Elem()
+		c.Set(clone(v.Elem(), copies))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			c.Field(i).Set(clone(v.Field(i), copies))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(clone(v.Index(i), copies))
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,39 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone(t *testing.T) {
+	for _, node := range nodeTypes {
+		node, _ := populate(node)
+
+		copied := Clone(node)
+		assert.Equal(t, node, copied)
+
+		original := map[Node]bool{}
+		Inspect(node, func(n Node) bool {
+			original[n] = true
+			return true
+		})
+
+		Inspect(copied, func(n Node) bool {
+			if n != nil {
+				assert.False(t, original[n], "Clone shares a %T of %T", n, node)
+			}
+			return true
+		})
+	}
+}
+
+func TestCloneKeepsSharing(t *testing.T) {
+	name := ident("x")
+	pattern := &HashPattern{Pairs: []HashPair{{Key: name, Value: name}}}
+
+	copied := Clone(pattern).(*HashPattern)
+
+	assert.Same(t, copied.Pairs[0].Key, copied.Pairs[0].Value)
+	assert.NotSame(t, name, copied.Pairs[0].Key)
+}
@@ -0,0 +1,463 @@
+package ast
+
+import "fmt"
+
+// Visitor is called by Walk for every node. Visit returns the visitor to
+// walk the children of node with, or nil to skip them. Walk calls Visit(nil)
+// on that visitor once the children are walked.
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node depth-first in source order,
+// calling v.Visit(node) and then walking each non-nil child of node with the
+// visitor returned. Patterns, parameters and declared names are children
+// too. The name of a shorthand hash pattern, e.g. {x}, is both the key and
+// the value of its pair, and is visited twice.
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(n.Statements, v)
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean:
+		// leaves
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		if n.Pattern != nil {
+			Walk(n.Pattern, v)
+		}
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			Walk(n.ReturnValue, v)
+		}
+	case *ThrowStatement:
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *ImportStatement:
+		if n.Path != nil {
+			Walk(n.Path, v)
+		}
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+	case *StructStatement:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		walkIdentifiers(n.Fields, v)
+	case *EnumStatement:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		for _, variant := range n.Variants {
+			if variant.Name != nil {
+				Walk(variant.Name, v)
+			}
+			walkIdentifiers(variant.Fields, v)
+		}
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			Walk(n.Expression, v)
+		}
+	case *InterpolatedString:
+		walkExpressions(n.Parts, v)
+	case *PrefixExpression:
+		if n.Right != nil {
+			Walk(n.Right, v)
+		}
+	case *PropagateExpression:
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *InfixExpression:
+		if n.Left != nil {
+			Walk(n.Left, v)
+		}
+		if n.Right != nil {
+			Walk(n.Right, v)
+		}
+	case *IfExpression:
+		if n.Condition != nil {
+			Walk(n.Condition, v)
+		}
+		if n.Consequence != nil {
+			Walk(n.Consequence, v)
+		}
+		if n.Alternative != nil {
+			Walk(n.Alternative, v)
+		}
+	case *TryExpression:
+		if n.Block != nil {
+			Walk(n.Block, v)
+		}
+		if n.Param != nil {
+			Walk(n.Param, v)
+		}
+		if n.Catch != nil {
+			Walk(n.Catch, v)
+		}
+		if n.Finally != nil {
+			Walk(n.Finally, v)
+		}
+	case *YieldExpression:
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *ForExpression:
+		if n.Pattern != nil {
+			Walk(n.Pattern, v)
+		}
+		if n.Iterable != nil {
+			Walk(n.Iterable, v)
+		}
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	case *SpawnExpression:
+		if n.Call != nil {
+			Walk(n.Call, v)
+		}
+	case *SelectExpression:
+		for _, c := range n.Cases {
+			Walk(c, v)
+		}
+	case *SelectCase:
+		if n.Operation != nil {
+			Walk(n.Operation, v)
+		}
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	case *BlockStatement:
+		walkStatements(n.Statements, v)
+	case *FunctionLiteral:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		walkExpressions(n.Parameters, v)
+		if n.Rest != nil {
+			Walk(n.Rest, v)
+		}
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	case *MacroLiteral:
+		walkIdentifiers(n.Parameters, v)
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	case *DefaultParameter:
+		if n.Parameter != nil {
+			Walk(n.Parameter, v)
+		}
+		if n.Default != nil {
+			Walk(n.Default, v)
+		}
+	case *SpreadExpression:
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *NamedArgument:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *CallExpression:
+		if n.Function != nil {
+			Walk(n.Function, v)
+		}
+		walkExpressions(n.Arguments, v)
+	case *ArrayLiteral:
+		walkExpressions(n.Elements, v)
+	case *IndexExpression:
+		if n.Left != nil {
+			Walk(n.Left, v)
+		}
+		if n.Index != nil {
+			Walk(n.Index, v)
+		}
+	case *MemberExpression:
+		if n.Object != nil {
+			Walk(n.Object, v)
+		}
+		if n.Property != nil {
+			Walk(n.Property, v)
+		}
+	case *HashLiteral:
+		walkPairs(n.Pairs, v)
+	case *ArrayPattern:
+		walkExpressions(n.Elements, v)
+		if n.Rest != nil {
+			Walk(n.Rest, v)
+		}
+	case *HashPattern:
+		walkPairs(n.Pairs, v)
+	case *StructPattern:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		if n.Fields != nil {
+			Walk(n.Fields, v)
+		}
+	case *VariantPattern:
+		if n.Enum != nil {
+			Walk(n.Enum, v)
+		}
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		walkExpressions(n.Arguments, v)
+	case *MatchExpression:
+		if n.Subject != nil {
+			Walk(n.Subject, v)
+		}
+		for _, arm := range n.Arms {
+			Walk(arm, v)
+		}
+	case *MatchArm:
+		if n.Pattern != nil {
+			Walk(n.Pattern, v)
+		}
+		if n.Guard != nil {
+			Walk(n.Guard, v)
+		}
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(stmts []Statement, v Visitor) {
+	for _, stmt := range stmts {
+		Walk(stmt, v)
+	}
+}
+
+func walkExpressions(exps []Expression, v Visitor) {
+	for _, e := range exps {
+		Walk(e, v)
+	}
+}
+
+func walkIdentifiers(idents []*Identifier, v Visitor) {
+	for _, ident := range idents {
+		Walk(ident, v)
+	}
+}
+
+func walkPairs(pairs []HashPair, v Visitor) {
+	for _, pair := range pairs {
+		Walk(pair.Key, v)
+		Walk(pair.Value, v)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node like Walk, calling f(node) for
+// every node and walking its children when f returns true. Once the children
+// are walked, f is called with nil.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
+
+// Modify rewrites the tree rooted at node bottom-up: it replaces each child
+// of node by the result of modifying it, then returns modifier(node). The
+// modifier must return a node that fits where it was, e.g. an Expression for
+// an operand or a *BlockStatement for a body, and Modify panics when not.
+func Modify(node Node, modifier func(Node) Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		modifyStatements(n.Statements, modifier)
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean:
+		// leaves
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Pattern = modifyExpression(n.Pattern, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+	case *ThrowStatement:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ImportStatement:
+		if n.Path != nil {
+			n.Path = Modify(n.Path, modifier).(*StringLiteral)
+		}
+		n.Name = modifyIdentifier(n.Name, modifier)
+	case *StructStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		modifyIdentifiers(n.Fields, modifier)
+	case *EnumStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		for _, variant := range n.Variants {
+			variant.Name = modifyIdentifier(variant.Name, modifier)
+			modifyIdentifiers(variant.Fields, modifier)
+		}
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *InterpolatedString:
+		modifyExpressions(n.Parts, modifier)
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *PropagateExpression:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		n.Alternative = modifyBlock(n.Alternative, modifier)
+	case *TryExpression:
+		n.Block = modifyBlock(n.Block, modifier)
+		n.Param = modifyIdentifier(n.Param, modifier)
+		n.Catch = modifyBlock(n.Catch, modifier)
+		n.Finally = modifyBlock(n.Finally, modifier)
+	case *YieldExpression:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ForExpression:
+		n.Pattern = modifyExpression(n.Pattern, modifier)
+		n.Iterable = modifyExpression(n.Iterable, modifier)
+		n.Body = modifyBlock(n.Body, modifier)
+	case *SpawnExpression:
+		n.Call = modifyExpression(n.Call, modifier)
+	case *SelectExpression:
+		for i, c := range n.Cases {
+			n.Cases[i] = Modify(c, modifier).(*SelectCase)
+		}
+	case *SelectCase:
+		if n.Operation != nil {
+			n.Operation = Modify(n.Operation, modifier).(*CallExpression)
+		}
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Body = modifyExpression(n.Body, modifier)
+	case *BlockStatement:
+		modifyStatements(n.Statements, modifier)
+	case *FunctionLiteral:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		modifyExpressions(n.Parameters, modifier)
+		n.Rest = modifyIdentifier(n.Rest, modifier)
+		n.Body = modifyBlock(n.Body, modifier)
+	case *MacroLiteral:
+		modifyIdentifiers(n.Parameters, modifier)
+		n.Body = modifyBlock(n.Body, modifier)
+	case *DefaultParameter:
+		n.Parameter = modifyExpression(n.Parameter, modifier)
+		n.Default = modifyExpression(n.Default, modifier)
+	case *SpreadExpression:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *NamedArgument:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		modifyExpressions(n.Arguments, modifier)
+	case *ArrayLiteral:
+		modifyExpressions(n.Elements, modifier)
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *MemberExpression:
+		n.Object = modifyExpression(n.Object, modifier)
+		n.Property = modifyIdentifier(n.Property, modifier)
+	case *HashLiteral:
+		modifyPairs(n.Pairs, modifier)
+	case *ArrayPattern:
+		modifyExpressions(n.Elements, modifier)
+		n.Rest = modifyIdentifier(n.Rest, modifier)
+	case *HashPattern:
+		modifyPairs(n.Pairs, modifier)
+	case *StructPattern:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		if n.Fields != nil {
+			n.Fields = Modify(n.Fields, modifier).(*HashPattern)
+		}
+	case *VariantPattern:
+		n.Enum = modifyIdentifier(n.Enum, modifier)
+		n.Name = modifyIdentifier(n.Name, modifier)
+		modifyExpressions(n.Arguments, modifier)
+	case *MatchExpression:
+		n.Subject = modifyExpression(n.Subject, modifier)
+		for i, arm := range n.Arms {
+			n.Arms[i] = Modify(arm, modifier).(*MatchArm)
+		}
+	case *MatchArm:
+		n.Pattern = modifyExpression(n.Pattern, modifier)
+		n.Guard = modifyExpression(n.Guard, modifier)
+		n.Body = modifyExpression(n.Body, modifier)
+	default:
+		panic(fmt.Sprintf("ast.Modify: unexpected node type %T", n))
+	}
+
+	return modifier(node)
+}
+
+func modifyExpression(e Expression, modifier func(Node) Node) Expression {
+	if e == nil {
+		return nil
+	}
+	return Modify(e, modifier).(Expression)
+}
+
+func modifyIdentifier(ident *Identifier, modifier func(Node) Node) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return Modify(ident, modifier).(*Identifier)
+}
+
+func modifyBlock(block *BlockStatement, modifier func(Node) Node) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return Modify(block, modifier).(*BlockStatement)
+}
+
+func modifyStatements(stmts []Statement, modifier func(Node) Node) {
+	for i, stmt := range stmts {
+		stmts[i] = Modify(stmt, modifier).(Statement)
+	}
+}
+
+func modifyExpressions(exps []Expression, modifier func(Node) Node) {
+	for i, e := range exps {
+		exps[i] = modifyExpression(e, modifier)
+	}
+}
+
+func modifyIdentifiers(idents []*Identifier, modifier func(Node) Node) {
+	for i, ident := range idents {
+		idents[i] = modifyIdentifier(ident, modifier)
+	}
+}
+
+func modifyPairs(pairs []HashPair, modifier func(Node) Node) {
+	for i, pair := range pairs {
+		pairs[i] = HashPair{Key: modifyExpression(pair.Key, modifier), Value: modifyExpression(pair.Value, modifier)}
+	}
+}
@@ -0,0 +1,281 @@
+package ast
+
+import (
+	"arkham/token"
+	"fmt"
+	goast "go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nodeTypes holds a value of every node type. TestNodeTypesListed fails when
+// a type is missing, and the tests below fail when Walk or Modify misses it
+// or one of its children.
+var nodeTypes = []Node{
+	&Program{}, &Identifier{}, &LetStatement{}, &ReturnStatement{},
+	&ThrowStatement{}, &ImportStatement{}, &StructStatement{}, &EnumStatement{},
+	&ExpressionStatement{}, &IntegerLiteral{}, &StringLiteral{},
+	&InterpolatedString{}, &PrefixExpression{}, &PropagateExpression{},
+	&InfixExpression{}, &Boolean{}, &IfExpression{}, &TryExpression{},
+	&YieldExpression{}, &ForExpression{}, &SpawnExpression{},
+	&SelectExpression{}, &SelectCase{}, &BlockStatement{}, &FunctionLiteral{},
+	&MacroLiteral{}, &DefaultParameter{}, &SpreadExpression{}, &NamedArgument{},
+	&CallExpression{}, &ArrayLiteral{}, &IndexExpression{}, &MemberExpression{},
+	&HashLiteral{}, &ArrayPattern{}, &HashPattern{}, &StructPattern{},
+	&VariantPattern{}, &MatchExpression{}, &MatchArm{},
+}
+
+func TestNodeTypesListed(t *testing.T) {
+	sources := func(info fs.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }
+	packages, err := parser.ParseDir(gotoken.NewFileSet(), ".", sources, 0)
+	require.NoError(t, err)
+
+	// Node types are the types with a TokenLiteral method
+	declared := []string{}
+	for _, file := range packages["ast"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Name.Name != "TokenLiteral" || fn.Recv == nil {
+				continue
+			}
+			recv := fn.Recv.List[0].Type.(*goast.StarExpr).X.(*goast.Ident)
+			declared = append(declared, recv.Name)
+		}
+	}
+
+	listed := []string{}
+	for _, node := range nodeTypes {
+		listed = append(listed, reflect.TypeOf(node).Elem().Name())
+	}
+
+	sort.Strings(declared)
+	sort.Strings(listed)
+	assert.Equal(t, declared, listed, "every node type must be in nodeTypes and handled by Walk and Modify")
+}
+
+func TestWalkVisitsEveryChild(t *testing.T) {
+	for _, node := range nodeTypes {
+		node, children := populate(node)
+
+		visited := map[Node]bool{}
+		Inspect(node, func(n Node) bool {
+			if n != nil {
+				visited[n] = true
+			}
+			return true
+		})
+
+		for _, child := range children {
+			assert.True(t, visited[child], "Walk skips a %T child of %T", child, node)
+		}
+	}
+}
+
+func TestModifyVisitsEveryChild(t *testing.T) {
+	for _, node := range nodeTypes {
+		node, children := populate(node)
+
+		modified := map[Node]bool{}
+		result := Modify(node, func(n Node) Node {
+			modified[n] = true
+			return n
+		})
+
+		assert.Same(t, node, result)
+		for _, child := range children {
+			assert.True(t, modified[child], "Modify skips a %T child of %T", child, node)
+		}
+	}
+}
+
+func TestWalkOrder(t *testing.T) {
+	// if (x) { y } else { z(a, b) }
+	node := &IfExpression{
+		Condition:   ident("x"),
+		Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("y")}}},
+		Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &CallExpression{
+			Function:  ident("z"),
+			Arguments: []Expression{ident("a"), ident("b")},
+		}}}},
+	}
+
+	names := []string{}
+	Inspect(node, func(n Node) bool {
+		if id, ok := n.(*Identifier); ok {
+			names = append(names, id.Value)
+		}
+		return true
+	})
+
+	assert.Equal(t, []string{"x", "y", "z", "a", "b"}, names)
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	// fn(a) { b }(c)
+	node := &CallExpression{
+		Function: &FunctionLiteral{
+			Parameters: []Expression{ident("a")},
+			Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("b")}}},
+		},
+		Arguments: []Expression{ident("c")},
+	}
+
+	names := []string{}
+	Inspect(node, func(n Node) bool {
+		if id, ok := n.(*Identifier); ok {
+			names = append(names, id.Value)
+		}
+		_, fn := n.(*FunctionLiteral)
+		return !fn
+	})
+
+	assert.Equal(t, []string{"c"}, names)
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    Node
+		expected string
+	}{
+		{one(), "2"},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, "(2 + 2)"},
+		{&PrefixExpression{Operator: "-", Right: one()}, "(-2)"},
+		{&IndexExpression{Left: one(), Index: one()}, "(2[2])"},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, "[2, 2]"},
+		{&CallExpression{Function: ident("f"), Arguments: []Expression{one(), two()}}, "f(2, 2)"},
+		{&ReturnStatement{Token: token.Token{Literal: "return"}, ReturnValue: one()}, "return 2"},
+		{&HashLiteral{Pairs: []HashPair{{Key: one(), Value: one()}}}, "{2: 2}"},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+		assert.Equal(t, tt.expected, modified.String())
+	}
+
+	// if (1) { 1 } else { 1 }
+	ifExpression := &IfExpression{
+		Condition:   one(),
+		Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+		Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+	}
+	Modify(ifExpression, turnOneIntoTwo)
+	assert.Equal(t, int64(2), ifExpression.Condition.(*IntegerLiteral).Value)
+	assert.Equal(t, "2", ifExpression.Consequence.String())
+	assert.Equal(t, "2", ifExpression.Alternative.String())
+
+	// fn(x = 1) { 1 }
+	function := &FunctionLiteral{
+		Parameters: []Expression{&DefaultParameter{Parameter: ident("x"), Default: one()}},
+		Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+	}
+	Modify(function, turnOneIntoTwo)
+	assert.Equal(t, int64(2), function.Parameters[0].(*DefaultParameter).Default.(*IntegerLiteral).Value)
+	assert.Equal(t, "2", function.Body.String())
+}
+
+func TestModifyPanicsOnMisfit(t *testing.T) {
+	node := &IfExpression{Condition: ident("x"), Consequence: &BlockStatement{}}
+
+	assert.Panics(t, func() {
+		Modify(node, func(n Node) Node {
+			if _, ok := n.(*BlockStatement); ok {
+				return ident("y")
+			}
+			return n
+		})
+	})
+}
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+var (
+	nodeType       = reflect.TypeOf((*Node)(nil)).Elem()
+	expressionType = reflect.TypeOf((*Expression)(nil)).Elem()
+	statementType  = reflect.TypeOf((*Statement)(nil)).Elem()
+)
+
+// populate returns a new node of the type of node with every child set, in
+// slices too, and those children. Children are leaves, or nodes of a fitting
+// type without children of their own.
+func populate(node Node) (Node, []Node) {
+	value := reflect.New(reflect.TypeOf(node).Elem())
+	children := []Node{}
+	fill(value.Elem(), &children)
+	return value.Interface().(Node), children
+}
+
+func fill(value reflect.Value, children *[]Node) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if child, ok := child(field.Type(), len(*children)); ok {
+			field.Set(reflect.ValueOf(child))
+			*children = append(*children, child)
+			continue
+		}
+
+		if field.Kind() != reflect.Slice {
+			continue
+		}
+
+		elem := field.Type().Elem()
+		if child, ok := child(elem, len(*children)); ok {
+			field.Set(reflect.Append(field, reflect.ValueOf(child)))
+			*children = append(*children, child)
+		} else if elem.Kind() == reflect.Struct || elem.Kind() == reflect.Pointer && elem.Elem().Kind() == reflect.Struct {
+			// HashPair and EnumVariant group children without being nodes
+			holder := reflect.New(structType(elem)).Elem()
+			fill(holder, children)
+			if elem.Kind() == reflect.Pointer {
+				holder = holder.Addr()
+			}
+			field.Set(reflect.Append(field, holder))
+		}
+	}
+}
+
+// child returns a new node assignable to typ, whose token is numbered n, if
+// typ holds nodes.
+func child(typ reflect.Type, n int) (Node, bool) {
+	tok := token.Token{Literal: fmt.Sprintf("child%d", n)}
+
+	switch {
+	case typ == expressionType || typ == nodeType:
+		return &Identifier{Token: tok, Value: tok.Literal}, true
+	case typ == statementType:
+		return &ExpressionStatement{Token: tok}, true
+	case typ.Kind() == reflect.Pointer && typ.Implements(nodeType):
+		value := reflect.New(typ.Elem())
+		value.Elem().FieldByName("Token").Set(reflect.ValueOf(tok))
+		return value.Interface().(Node), true
+	default:
+		return nil, false
+	}
+}
+
+func structType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Pointer {
+		return typ.Elem()
+	}
+	return typ
+}
@@ -0,0 +1,683 @@
+// Package astjson converts Arkham tokens and syntax trees to and from JSON,
+// for tools that are not written in Go.
+//
+// A token is an object holding its type, as named by package token, its
+// literal and its span:
+//
+//	{"type": "IDENT", "literal": "x", "span": {"start": {"line": 1, "column": 5}, "end": {"line": 1, "column": 6}}}
+//
+// Lines and columns count from 1 and a span ends just past its last
+// character. A node is an object holding:
+//
+//   - kind: the name of its type in package ast, e.g. "InfixExpression"
+//   - token: the token it was parsed from, absent for the Program. A tree
+//     built by other tools may leave it out: Decode then makes the token
+//     from the kind, value and attributes of the node, with no position.
+//   - span: from its first token to its last, brackets included
+//   - value: the value of an Identifier, IntegerLiteral, StringLiteral or
+//     Boolean, as a JSON string, number or boolean
+//   - attributes: its other strings and booleans by field name, such as the
+//     operator of an InfixExpression and whether a FunctionLiteral is a
+//     generator
+//   - children: its child nodes by field name
+//
+// Field names are those of package ast with a lower case first letter, so
+// an InfixExpression has the children "left" and "right" and the attribute
+// "operator". A child is a node, or an array of nodes when the field holds
+// several. Absent nodes are left out, while an empty array differs from a
+// missing one, as it does for VariantPattern arguments. Hash pairs are
+// objects holding the nodes "key" and "value", with "value" left out in a
+// pattern's shorthand, e.g. {a}. Enum variants are objects holding the node
+// "name" and the array "fields", left out for a variant written without
+// parentheses.
+//
+// The scopes and bindings the resolver sets are not encoded. Decoding fails
+// on a node lacking a child the parser always sets, such as the right of an
+// InfixExpression or the body of a FunctionLiteral, and on arrays holding
+// null.
+package astjson
+
+import (
+	"arkham/ast"
+	"arkham/lexer"
+	"arkham/token"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Position is a line and column in the source, both counting from 1.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Span is the source from Start to just before End.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Token is the encoding of a token.Token.
+type Token struct {
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+	Span    Span   `json:"span"`
+}
+
+// Node is the encoding of an ast.Node. Children hold encoded nodes, arrays
+// of them and arrays of hash pairs or enum variants.
+type Node struct {
+	Kind       string                     `json:"kind"`
+	Token      *Token                     `json:"token,omitempty"`
+	Span       Span                       `json:"span"`
+	Value      json.RawMessage            `json:"value,omitempty"`
+	Attributes map[string]json.RawMessage `json:"attributes,omitempty"`
+	Children   map[string]json.RawMessage `json:"children,omitempty"`
+}
+
+// Tokens returns the tokens of src, comments included and the final EOF
+// left out.
+func Tokens(src string) []Token {
+	s := scan(lexer.NewWithComments(src))
+	tokens := make([]Token, len(s.tokens))
+	for i, tok := range s.tokens {
+		tokens[i] = Token{Type: string(tok.Type), Literal: tok.Literal, Span: Span{start(tok), s.ends[i]}}
+	}
+	return tokens
+}
+
+// Encode returns the encoding of program, which was parsed from src. The
+// spans are found in src; with no source each span is empty and starts at
+// the node's token.
+func Encode(program *ast.Program, src string) *Node {
+	s := scan(lexer.New(src))
+	n, _, _ := s.node(reflect.ValueOf(program))
+	return n
+}
+
+// Spans returns the span of each node of program, which was parsed from
+// src, as Encode finds them.
+func Spans(program *ast.Program, src string) map[ast.Node]Span {
+	s := scan(lexer.New(src))
+	s.spans = map[ast.Node]Span{}
+	s.node(reflect.ValueOf(program))
+	return s.spans
+}
+
+// Decode returns the program n encodes.
+func Decode(n *Node) (*ast.Program, error) {
+	node, err := decode(n)
+	if err != nil {
+		return nil, err
+	}
+
+	program, ok := node.(*ast.Program)
+	if !ok {
+		return nil, fmt.Errorf("astjson: expected a Program, got %s", n.Kind)
+	}
+	return program, nil
+}
+
+var (
+	tokenType    = reflect.TypeOf(token.Token{})
+	scopeType    = reflect.TypeOf(&ast.Scope{})
+	bindingType  = reflect.TypeOf(&ast.Binding{})
+	hashPairType = reflect.TypeOf(ast.HashPair{})
+	nodeType     = reflect.TypeOf((*ast.Node)(nil)).Elem()
+)
+
+// kinds holds the node types by name.
+var kinds = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []ast.Node{
+		&ast.Program{}, &ast.Identifier{}, &ast.LetStatement{}, &ast.ReturnStatement{},
+		&ast.ThrowStatement{}, &ast.ImportStatement{}, &ast.StructStatement{}, &ast.EnumStatement{},
+		&ast.ExpressionStatement{}, &ast.IntegerLiteral{}, &ast.StringLiteral{},
+		&ast.InterpolatedString{}, &ast.PrefixExpression{}, &ast.PropagateExpression{},
+		&ast.InfixExpression{}, &ast.Boolean{}, &ast.IfExpression{}, &ast.TryExpression{},
+		&ast.YieldExpression{}, &ast.ForExpression{}, &ast.SpawnExpression{},
+		&ast.SelectExpression{}, &ast.SelectCase{}, &ast.BlockStatement{}, &ast.FunctionLiteral{},
+		&ast.MacroLiteral{}, &ast.DefaultParameter{}, &ast.SpreadExpression{}, &ast.NamedArgument{},
+		&ast.CallExpression{}, &ast.ArrayLiteral{}, &ast.IndexExpression{}, &ast.MemberExpression{},
+		&ast.HashLiteral{}, &ast.ArrayPattern{}, &ast.HashPattern{}, &ast.StructPattern{},
+		&ast.VariantPattern{}, &ast.MatchExpression{}, &ast.MatchArm{},
+	} {
+		t := reflect.TypeOf(node).Elem()
+		kinds[t.Name()] = t
+	}
+}
+
+// optional holds the children a node or group may lack, by its type name
+// and field name. The parser sets every other child that is a single node.
+var optional = map[string]bool{
+	"ArrayPattern.rest":        true,
+	"FunctionLiteral.name":     true,
+	"FunctionLiteral.rest":     true,
+	"IfExpression.alternative": true,
+	"LetStatement.name":        true, // or the pattern, see complete
+	"LetStatement.pattern":     true,
+	"MatchArm.guard":           true,
+	"SelectCase.operation":     true,
+	"SelectCase.name":          true,
+	"TryExpression.param":      true,
+	"TryExpression.catch":      true,
+	"TryExpression.finally":    true,
+	"VariantPattern.enum":      true,
+	"YieldExpression.value":    true,
+	"HashPair.value":           true,
+}
+
+// key returns the JSON name of a field.
+func key(field string) string {
+	r, size := utf8.DecodeRuneInString(field)
+	return string(unicode.ToLower(r)) + field[size:]
+}
+
+// basic reports whether a field of type t is a value or an attribute rather
+// than a child.
+func basic(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func start(tok token.Token) Position {
+	return Position{tok.Line, tok.Column}
+}
+
+func marshal(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// source holds the tokens of the source a tree is encoded from.
+type source struct {
+	tokens  []token.Token     // the tokens but the final EOF
+	ends    []Position        // where each token ends
+	index   map[Position]int  // of each token in tokens
+	partner []int             // of the bracket matching each one, or -1
+	spans   map[ast.Node]Span // of the nodes encoded, when not nil
+}
+
+func scan(l *lexer.Lexer) *source {
+	s := &source{index: map[Position]int{}}
+	open := []int{}
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		line, column := l.Position()
+		i := len(s.tokens)
+		if tok.Type != token.COMMENT {
+			s.index[start(tok)] = i
+		}
+		s.tokens = append(s.tokens, tok)
+		s.ends = append(s.ends, Position{line, column})
+		s.partner = append(s.partner, -1)
+
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			open = append(open, i)
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			if len(open) > 0 {
+				s.partner[i], s.partner[open[len(open)-1]] = open[len(open)-1], i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+
+	return s
+}
+
+// token returns the encoding of tok and its index in the source, or -1
+// when the parser made it up.
+func (s *source) token(tok token.Token) (*Token, int) {
+	enc := &Token{Type: string(tok.Type), Literal: tok.Literal, Span: Span{start(tok), start(tok)}}
+
+	i, ok := s.index[start(tok)]
+	if !ok {
+		return enc, -1
+	}
+	if s.tokens[i].Type == tok.Type {
+		enc.Span.End = s.ends[i]
+	}
+	return enc, i
+}
+
+// node returns the encoding of the node v points to and the range of the
+// tokens it spans, empty when first > last.
+func (s *source) node(v reflect.Value) (n *Node, first, last int) {
+	t := v.Elem().Type()
+	n = &Node{Kind: t.Name(), Attributes: map[string]json.RawMessage{}, Children: map[string]json.RawMessage{}}
+	first, last = len(s.tokens), -1
+	cover := func(f, l int) {
+		first, last = min(first, f), max(last, l)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Elem().Field(i)
+
+		switch {
+		case field.Type == tokenType:
+			var index int
+			n.Token, index = s.token(fv.Interface().(token.Token))
+			if index >= 0 {
+				cover(index, index)
+			}
+		case field.Type == scopeType || field.Type == bindingType:
+		case basic(field.Type) && field.Name == "Value":
+			n.Value = marshal(fv.Interface())
+		case basic(field.Type):
+			n.Attributes[key(field.Name)] = marshal(fv.Interface())
+		default:
+			child, f, l := s.value(fv)
+			if child != nil {
+				n.Children[key(field.Name)] = child
+				cover(f, l)
+			}
+		}
+	}
+
+	if len(n.Attributes) == 0 {
+		n.Attributes = nil
+	}
+	if len(n.Children) == 0 {
+		n.Children = nil
+	}
+
+	first, last = s.balance(first, last)
+	switch {
+	case first <= last:
+		n.Span = Span{start(s.tokens[first]), s.ends[last]}
+	case n.Token != nil:
+		n.Span = Span{n.Token.Span.Start, n.Token.Span.Start}
+	default:
+		n.Span = Span{Position{1, 1}, Position{1, 1}}
+	}
+	if s.spans != nil {
+		s.spans[v.Interface().(ast.Node)] = n.Span
+	}
+	return n, first, last
+}
+
+// balance widens the range of tokens from first to last until it holds both
+// or neither bracket of each pair, so a span takes in the brackets closing
+// a call or block and those around a parenthesized operand.
+func (s *source) balance(first, last int) (int, int) {
+	for changed := true; changed; {
+		changed = false
+		for i := max(first, 0); i <= last; i++ {
+			p := s.partner[i]
+			if p > last {
+				last, changed = p, true
+			}
+			if p >= 0 && p < first {
+				first, changed = p, true
+			}
+		}
+	}
+	return first, last
+}
+
+// value returns the encoding of the child field v, nil when it is absent,
+// and the range of the tokens it spans.
+func (s *source) value(v reflect.Value) (json.RawMessage, int, int) {
+	first, last := len(s.tokens), -1
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil, first, last
+		}
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if v.Type().Implements(nodeType) {
+			n, f, l := s.node(v)
+			return marshal(n), f, l
+		}
+		return s.group(v.Elem())
+	case reflect.Struct:
+		return s.group(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, first, last
+		}
+
+		items := make([]json.RawMessage, v.Len())
+		for i := range items {
+			var f, l int
+			items[i], f, l = s.value(v.Index(i))
+			first, last = min(first, f), max(last, l)
+		}
+		return marshal(items), first, last
+	default:
+		panic(fmt.Sprintf("astjson: unexpected field of type %s", v.Type()))
+	}
+}
+
+// group returns the encoding of a hash pair or enum variant.
+func (s *source) group(v reflect.Value) (json.RawMessage, int, int) {
+	first, last := len(s.tokens), -1
+	fields := map[string]json.RawMessage{}
+
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if v.Type() == hashPairType && name == "Value" && v.Field(i).Interface() == v.Field(0).Interface() {
+			continue // shorthand
+		}
+
+		child, f, l := s.value(v.Field(i))
+		if child != nil {
+			fields[key(name)] = child
+			first, last = min(first, f), max(last, l)
+		}
+	}
+
+	return marshal(fields), first, last
+}
+
+// decode returns the node n encodes.
+func decode(n *Node) (ast.Node, error) {
+	t, ok := kinds[n.Kind]
+	if !ok {
+		return nil, fmt.Errorf("astjson: unknown node kind %q", n.Kind)
+	}
+
+	v := reflect.New(t)
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Elem().Field(i)
+
+		switch {
+		case field.Type == tokenType:
+			if n.Token != nil {
+				fv.Set(reflect.ValueOf(token.Token{
+					Type:    token.TokenType(n.Token.Type),
+					Literal: n.Token.Literal,
+					Line:    n.Token.Span.Start.Line,
+					Column:  n.Token.Span.Start.Column,
+				}))
+			}
+		case field.Type == scopeType || field.Type == bindingType:
+		case basic(field.Type):
+			raw := n.Attributes[key(field.Name)]
+			if field.Name == "Value" {
+				raw = n.Value
+			}
+			if raw == nil {
+				continue
+			}
+			if err := json.Unmarshal(raw, fv.Addr().Interface()); err != nil {
+				return nil, fmt.Errorf("astjson: %s %s: %w", n.Kind, key(field.Name), err)
+			}
+		default:
+			if raw, ok := n.Children[key(field.Name)]; ok {
+				if err := decodeValue(raw, fv); err != nil {
+					return nil, err
+				}
+			}
+			if err := checkChild(n.Kind, field, fv); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	node := v.Interface().(ast.Node)
+	if err := complete(node); err != nil {
+		return nil, err
+	}
+
+	if n.Token == nil && n.Kind != "Program" {
+		v.Elem().FieldByName("Token").Set(reflect.ValueOf(makeToken(node)))
+	}
+	if str, ok := node.(*ast.InterpolatedString); ok {
+		textTokens(str)
+	}
+
+	return node, nil
+}
+
+// keywords holds the literals of the tokens the nodes of a kind are always
+// parsed from.
+var keywords = map[string]string{
+	"LetStatement":        "let",
+	"ReturnStatement":     "return",
+	"ThrowStatement":      "throw",
+	"ImportStatement":     "import",
+	"StructStatement":     "struct",
+	"EnumStatement":       "enum",
+	"IfExpression":        "if",
+	"TryExpression":       "try",
+	"YieldExpression":     "yield",
+	"ForExpression":       "for",
+	"SpawnExpression":     "spawn",
+	"SelectExpression":    "select",
+	"FunctionLiteral":     "fn",
+	"MacroLiteral":        "macro",
+	"MatchExpression":     "match",
+	"BlockStatement":      "{",
+	"HashLiteral":         "{",
+	"HashPattern":         "{",
+	"ArrayLiteral":        "[",
+	"ArrayPattern":        "[",
+	"IndexExpression":     "[",
+	"CallExpression":      "(",
+	"MemberExpression":    ".",
+	"PropagateExpression": "?",
+	"DefaultParameter":    "=",
+	"SpreadExpression":    "...",
+}
+
+// makeToken returns the token node is parsed from, for a node decoded
+// without one.
+func makeToken(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return literal(node.Value)
+	case *ast.IntegerLiteral:
+		return token.Token{Type: token.INT, Literal: strconv.FormatInt(node.Value, 10)}
+	case *ast.StringLiteral:
+		return token.Token{Type: token.STRING, Literal: node.Value}
+	case *ast.Boolean:
+		return literal(strconv.FormatBool(node.Value))
+	case *ast.PrefixExpression:
+		return literal(node.Operator)
+	case *ast.InfixExpression:
+		return literal(node.Operator)
+	case *ast.InterpolatedString:
+		if len(node.Parts) == 0 {
+			return token.Token{Type: token.STRING_START}
+		}
+		return token.Token{Type: token.STRING_START, Literal: textOf(node.Parts[0])}
+	case *ast.ExpressionStatement:
+		return tokenOf(node.Expression)
+	case *ast.NamedArgument:
+		return tokenOf(node.Name)
+	case *ast.StructPattern:
+		return tokenOf(node.Name)
+	case *ast.VariantPattern:
+		if node.Enum != nil {
+			return tokenOf(node.Enum)
+		}
+		return tokenOf(node.Name)
+	case *ast.MatchArm:
+		return tokenOf(node.Pattern)
+	case *ast.SelectCase:
+		if node.Operation == nil {
+			return literal("_")
+		}
+		return tokenOf(node.Operation.Function)
+	}
+
+	kind := reflect.TypeOf(node).Elem().Name()
+	return literal(keywords[kind])
+}
+
+// literal returns the token of a keyword, identifier or operator.
+func literal(lit string) token.Token {
+	typ := token.LookupIdent(lit)
+	if typ == token.IDENT && lit != "" && !unicode.IsLetter([]rune(lit)[0]) && lit[0] != '_' {
+		typ = token.TokenType(lit)
+	}
+	return token.Token{Type: typ, Literal: lit}
+}
+
+func tokenOf(node ast.Node) token.Token {
+	return reflect.ValueOf(node).Elem().FieldByName("Token").Interface().(token.Token)
+}
+
+func textOf(part ast.Expression) string {
+	if str, ok := part.(*ast.StringLiteral); ok {
+		return str.Value
+	}
+	return ""
+}
+
+// textTokens gives the text parts of str, every other part from the first,
+// the tokens of text where they have the token of a string literal, as
+// those decoded without a token do.
+func textTokens(str *ast.InterpolatedString) {
+	for i := 0; i < len(str.Parts); i += 2 {
+		part, ok := str.Parts[i].(*ast.StringLiteral)
+		if !ok || part.Token.Type != token.STRING {
+			continue
+		}
+
+		switch i {
+		case 0:
+			part.Token.Type = token.STRING_START
+		case len(str.Parts) - 1:
+			part.Token.Type = token.STRING_END
+		default:
+			part.Token.Type = token.STRING_MIDDLE
+		}
+	}
+}
+
+// checkChild fails when the child field of a node or group of type owner is
+// required but absent, or an array holding null.
+func checkChild(owner string, field reflect.StructField, v reflect.Value) error {
+	name := key(field.Name)
+
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if el := v.Index(i); nullable(el) && el.IsNil() {
+				return fmt.Errorf("astjson: %s %s holds null", owner, name)
+			}
+		}
+		return nil
+	}
+
+	if nullable(v) && v.IsNil() && !optional[owner+"."+name] {
+		return fmt.Errorf("astjson: %s lacks %s", owner, name)
+	}
+	return nil
+}
+
+func nullable(v reflect.Value) bool {
+	return v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface
+}
+
+// complete fails on the nodes lacking a child that depends on others.
+func complete(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if node.Name == nil && node.Pattern == nil {
+			return fmt.Errorf("astjson: LetStatement lacks name or pattern")
+		}
+	case *ast.TryExpression:
+		if node.Catch == nil && node.Finally == nil {
+			return fmt.Errorf("astjson: TryExpression lacks catch or finally")
+		}
+		if node.Catch != nil && node.Param == nil {
+			return fmt.Errorf("astjson: TryExpression lacks param")
+		}
+	}
+	return nil
+}
+
+// decodeValue sets the child field v to what raw encodes.
+func decodeValue(raw json.RawMessage, v reflect.Value) error {
+	if strings.TrimSpace(string(raw)) == "null" {
+		return nil
+	}
+
+	switch {
+	case v.Kind() == reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return fmt.Errorf("astjson: %w", err)
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case v.Kind() == reflect.Struct:
+		return decodeGroup(raw, v)
+	case v.Kind() == reflect.Pointer && !v.Type().Implements(nodeType):
+		group := reflect.New(v.Type().Elem())
+		if err := decodeGroup(raw, group.Elem()); err != nil {
+			return err
+		}
+		v.Set(group)
+	default:
+		var n Node
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return fmt.Errorf("astjson: %w", err)
+		}
+
+		node, err := decode(&n)
+		if err != nil {
+			return err
+		}
+
+		child := reflect.ValueOf(node)
+		if !child.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("astjson: %s is not a %s", n.Kind, v.Type())
+		}
+		v.Set(child)
+	}
+
+	return nil
+}
+
+// decodeGroup sets the hash pair or enum variant v to what raw encodes.
+func decodeGroup(raw json.RawMessage, v reflect.Value) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("astjson: %w", err)
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if raw, ok := fields[key(field.Name)]; ok {
+			if err := decodeValue(raw, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		if err := checkChild(v.Type().Name(), field, v.Field(i)); err != nil {
+			return err
+		}
+	}
+
+	if v.Type() == hashPairType {
+		pair := v.Addr().Interface().(*ast.HashPair)
+		if pair.Value == nil {
+			pair.Value = pair.Key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package astjson
+
+import (
+	"arkham/ast"
+	"arkham/lexer"
+	"arkham/parser"
+	"encoding/json"
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	input := "-x + f(1)"
+
+	expected := `{
+		"kind": "Program",
+		"span": {"start": {"line": 1, "column": 1}, "end": {"line": 1, "column": 10}},
+		"children": {"statements": [{
+			"kind": "ExpressionStatement",
+			"token": {"type": "-", "literal": "-", "span": {"start": {"line": 1, "column": 1}, "end": {"line": 1, "column": 2}}},
+			"span": {"start": {"line": 1, "column": 1}, "end": {"line": 1, "column": 10}},
+			"children": {"expression": {
+				"kind": "InfixExpression",
+				"token": {"type": "+", "literal": "+", "span": {"start": {"line": 1, "column": 4}, "end": {"line": 1, "column": 5}}},
+				"span": {"start": {"line": 1, "column": 1}, "end": {"line": 1, "column": 10}},
+				"attributes": {"operator": "+"},
+				"children": {
+					"left": {
+						"kind": "PrefixExpression",
+						"token": {"type": "-", "literal": "-", "span": {"start": {"line": 1, "column": 1}, "end": {"line": 1, "column": 2}}},
+						"span": {"start": {"line": 1, "column": 1}, "end": {"line": 1, "column": 3}},
+						"attributes": {"operator": "-"},
+						"children": {"right": {
+							"kind": "Identifier",
+							"token": {"type": "IDENT", "literal": "x", "span": {"start": {"line": 1, "column": 2}, "end": {"line": 1, "column": 3}}},
+							"span": {"start": {"line": 1, "column": 2}, "end": {"line": 1, "column": 3}},
+							"value": "x"
+						}}
+					},
+					"right": {
+						"kind": "CallExpression",
+						"token": {"type": "(", "literal": "(", "span": {"start": {"line": 1, "column": 7}, "end": {"line": 1, "column": 8}}},
+						"span": {"start": {"line": 1, "column": 6}, "end": {"line": 1, "column": 10}},
+						"attributes": {"pipe": false},
+						"children": {
+							"function": {
+								"kind": "Identifier",
+								"token": {"type": "IDENT", "literal": "f", "span": {"start": {"line": 1, "column": 6}, "end": {"line": 1, "column": 7}}},
+								"span": {"start": {"line": 1, "column": 6}, "end": {"line": 1, "column": 7}},
+								"value": "f"
+							},
+							"arguments": [{
+								"kind": "IntegerLiteral",
+								"token": {"type": "INT", "literal": "1", "span": {"start": {"line": 1, "column": 8}, "end": {"line": 1, "column": 9}}},
+								"span": {"start": {"line": 1, "column": 8}, "end": {"line": 1, "column": 9}},
+								"value": 1
+							}]
+						}
+					}
+				}
+			}}
+		}]}
+	}`
+
+	data, err := json.Marshal(Encode(parse(t, input), input))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, expected, string(data))
+}
+
+func TestSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		kind     string
+		expected string
+	}{
+		{"f(a, b)", "CallExpression", "f(a, b)"},
+		{"xs[0]", "IndexExpression", "xs[0]"},
+		{"(a + b) * c", "InfixExpression", "(a + b) * c"},
+		{"a * (b + c)", "InfixExpression", "a * (b + c)"},
+		{"1 + (2)", "InfixExpression", "1 + (2)"},
+		{"let s = \"a${b}c\";", "InterpolatedString", "\"a${b}c\""},
+		{"let s = \"a${b}c\";", "LetStatement", "let s = \"a${b}c\""},
+		{"if (x) { 1 } else { 2 }", "IfExpression", "if (x) { 1 } else { 2 }"},
+		{"if (x) { 1 } else { 2 }", "BlockStatement", "{ 1 }"},
+		{"let f = fn(x) { x };", "FunctionLiteral", "fn(x) { x }"},
+		{"map(xs, (a, b) => a)", "FunctionLiteral", "(a, b) => a"},
+		{"xs |> f(1)", "CallExpression", "xs |> f(1)"},
+		{"match (x) { [a] => a }", "MatchArm", "[a] => a"},
+		{"{\"a\": [1]}", "HashLiteral", "{\"a\": [1]}"},
+		{"  x  // comment", "Program", "x"},
+	}
+
+	for _, tt := range tests {
+		n := find(t, Encode(parse(t, tt.input), tt.input), tt.kind)
+		require.NotNilf(t, n, "no %s in %q", tt.kind, tt.input)
+
+		require.Equal(t, 1, n.Span.Start.Line, tt.input)
+		require.Equal(t, 1, n.Span.End.Line, tt.input)
+		assert.Equalf(t, tt.expected, tt.input[n.Span.Start.Column-1:n.Span.End.Column-1], "span of the %s in %q", tt.kind, tt.input)
+	}
+}
+
+func TestSpansOfNodes(t *testing.T) {
+	input := "let f = fn(x) {\n  x + 1\n}"
+	program := parse(t, input)
+	spans := Spans(program, input)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	assert.Equal(t, Span{Position{1, 1}, Position{3, 2}}, spans[let])
+	assert.Equal(t, Span{Position{1, 15}, Position{3, 2}}, spans[fn.Body])
+	assert.Equal(t, Span{Position{2, 3}, Position{2, 8}}, spans[fn.Body.Statements[0]])
+	assert.Equal(t, Span{Position{1, 5}, Position{1, 6}}, spans[let.Name])
+}
+
+func TestEncodeWithoutSource(t *testing.T) {
+	n := Encode(parse(t, "a +\n  b"), "")
+
+	infix := find(t, n, "InfixExpression")
+	require.NotNil(t, infix)
+	assert.Equal(t, Span{Position{1, 3}, Position{1, 3}}, infix.Span)
+}
+
+func TestTokens(t *testing.T) {
+	expected := []Token{
+		{"LET", "let", Span{Position{1, 1}, Position{1, 4}}},
+		{"IDENT", "s", Span{Position{1, 5}, Position{1, 6}}},
+		{"=", "=", Span{Position{1, 7}, Position{1, 8}}},
+		{"STRING", "a\nb", Span{Position{1, 9}, Position{1, 15}}},
+		{"COMMENT", "// text", Span{Position{1, 16}, Position{1, 23}}},
+		{"}", "}", Span{Position{2, 1}, Position{2, 2}}},
+	}
+
+	assert.Equal(t, expected, Tokens("let s = \"a\\nb\" // text\n}"))
+	assert.Empty(t, Tokens(""))
+}
+
+// corpus holds programs between them using every node type.
+var corpus = []string{
+	`let fib = fn(n) { if (n < 2) { n } else if (n == 2) { 1 } else { fib(n - 1) + fib(n - 2) } }; fib(xs[0])`,
+	`struct Point { x, y }
+enum Shape { Circle(r), Rect(w, h), Dot, Unit() }
+let area = fn(s) {
+  match (s) {
+    Shape.Circle(r) => 3 * r * r,
+    Rect(w, h) if w == h => w * w,
+    Point{x, "y": [y, ...ys]} => { x },
+    Unit() => -1,
+    _ => 0,
+  }
+};
+[Circle(1), Rect(2, 3)] |> map(area) |> sum()`,
+	`fn* naturals() { let n = 0; for ({a, b: [c]} in range(10)) { yield a }; yield }
+let ch = channel(); spawn fn() { send(ch, 1) }()
+select { recv(ch) as v => puts("${v}!"), send(ch, 2) => { 1 }, _ => { puts("none") } }`,
+	`import "lib/a.ark" as a;
+let parse = fn(s) {
+	let n = toInt(s)?
+	try { check(n.value) } catch (e) { throw e } finally { cleanup() }
+	return !n
+}
+let g = x => y => x + y
+let h = (a, [b, c], {d}, e = 1, ...rest) => { a }
+h(1, ...[2], e: true, f: {"k": false})
+let m = macro(a, b) { quote(unquote(b) - unquote(a)) }`,
+}
+
+func TestRoundTrip(t *testing.T) {
+	covered := map[string]bool{}
+
+	for _, input := range corpus {
+		program := parse(t, input)
+		ast.Inspect(program, func(n ast.Node) bool {
+			if n != nil {
+				covered[strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")] = true
+			}
+			return true
+		})
+
+		data, err := json.Marshal(Encode(program, input))
+		require.NoError(t, err)
+
+		var n Node
+		require.NoError(t, json.Unmarshal(data, &n))
+
+		decoded, err := Decode(&n)
+		require.NoError(t, err)
+		assert.Equalf(t, program, decoded, "decoding %q", input)
+	}
+
+	for kind := range kinds {
+		assert.Truef(t, covered[kind], "the corpus has no %s", kind)
+	}
+}
+
+func TestDecodeShorthand(t *testing.T) {
+	program := parse(t, "let {a} = h;")
+	n := Encode(program, "")
+
+	decoded, err := Decode(n)
+	require.NoError(t, err)
+
+	pattern := decoded.Statements[0].(*ast.LetStatement).Pattern.(*ast.HashPattern)
+	assert.Same(t, pattern.Pairs[0].Key, pattern.Pairs[0].Value)
+}
+
+func TestDecodeWithoutTokens(t *testing.T) {
+	tests := []string{
+		`let f = fn(x) { x * 2 }; f()`,
+		`let s = "a${b}c${"d"}e"; -s + !true`,
+		`let {a, "b": [c, ...d]} = h; xs |> map(fn(x = 1, ...r) { x })?`,
+		`match (v) { Shape.Circle(r) if r > 1 => r, Point{x} => x, _ => { [1][0] } }`,
+		`select { recv(ch) as v => v, _ => 0 }; try { throw 1 } catch (e) { e.value }`,
+		`import "m" as m; struct P { x }; enum E { A }; fn* g() { yield; for (x in xs) { spawn f(x: 1) } }`,
+	}
+
+	for _, input := range tests {
+		program := parse(t, input)
+
+		data, err := json.Marshal(Encode(program, input))
+		require.NoError(t, err)
+
+		var tree interface{}
+		require.NoError(t, json.Unmarshal(data, &tree))
+		data, err = json.Marshal(withoutTokens(tree))
+		require.NoError(t, err)
+
+		var n Node
+		require.NoError(t, json.Unmarshal(data, &n))
+		decoded, err := Decode(&n)
+		require.NoError(t, err)
+
+		assert.Equalf(t, program.String(), decoded.String(), "decoding %q without tokens", input)
+	}
+}
+
+// withoutTokens removes the tokens from the JSON tree v.
+func withoutTokens(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		delete(v, "token")
+		for _, child := range v {
+			withoutTokens(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			withoutTokens(child)
+		}
+	}
+	return v
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"kind": "Nothing"}`, `astjson: unknown node kind "Nothing"`},
+		{`{"kind": "Identifier", "value": "x"}`, "astjson: expected a Program, got Identifier"},
+		{
+			`{"kind": "Program", "children": {"statements": [{"kind": "Identifier", "value": "x"}]}}`,
+			"astjson: Identifier is not a ast.Statement",
+		},
+		{
+			`{"kind": "Program", "children": {"statements": [{"kind": "LetStatement", "children": {"name": {"kind": "IntegerLiteral", "value": 1}}}]}}`,
+			"astjson: IntegerLiteral is not a *ast.Identifier",
+		},
+		{
+			`{"kind": "Program", "children": {"statements": [{"kind": "ExpressionStatement", "children": {"expression": {"kind": "IntegerLiteral", "value": "1"}}}]}}`,
+			"astjson: IntegerLiteral value: json: cannot unmarshal string into Go value of type int64",
+		},
+		{program(`{"kind": "ExpressionStatement"}`), "astjson: ExpressionStatement lacks expression"},
+		{expression(`{"kind": "InfixExpression", "attributes": {"operator": "+"}}`), "astjson: InfixExpression lacks left"},
+		{
+			expression(`{"kind": "InfixExpression", "attributes": {"operator": "+"}, "children": {"left": {"kind": "IntegerLiteral", "value": 1}}}`),
+			"astjson: InfixExpression lacks right",
+		},
+		{
+			expression(`{"kind": "InfixExpression", "children": {"left": {"kind": "IntegerLiteral", "value": 1}, "right": null}}`),
+			"astjson: InfixExpression lacks right",
+		},
+		{expression(`{"kind": "IfExpression", "children": {"condition": {"kind": "Boolean", "value": true}}}`), "astjson: IfExpression lacks consequence"},
+		{expression(`{"kind": "CallExpression", "children": {"arguments": []}}`), "astjson: CallExpression lacks function"},
+		{expression(`{"kind": "FunctionLiteral", "children": {"parameters": []}}`), "astjson: FunctionLiteral lacks body"},
+		{program(`{"kind": "LetStatement", "children": {"value": {"kind": "IntegerLiteral", "value": 1}}}`), "astjson: LetStatement lacks name or pattern"},
+		{program(`{"kind": "LetStatement", "children": {"name": {"kind": "Identifier", "value": "x"}}}`), "astjson: LetStatement lacks value"},
+		{
+			expression(`{"kind": "TryExpression", "children": {"block": {"kind": "BlockStatement"}, "catch": {"kind": "BlockStatement"}}}`),
+			"astjson: TryExpression lacks param",
+		},
+		{expression(`{"kind": "TryExpression", "children": {"block": {"kind": "BlockStatement"}}}`), "astjson: TryExpression lacks catch or finally"},
+		{expression(`{"kind": "HashLiteral", "children": {"pairs": [{"value": {"kind": "IntegerLiteral", "value": 1}}]}}`), "astjson: HashPair lacks key"},
+		{program(`{"kind": "EnumStatement", "children": {"name": {"kind": "Identifier", "value": "E"}, "variants": [{}]}}`), "astjson: EnumVariant lacks name"},
+		{expression(`{"kind": "ArrayLiteral", "children": {"elements": [null]}}`), "astjson: ArrayLiteral elements holds null"},
+		{`{"kind": "Program", "children": {"statements": [null]}}`, "astjson: Program statements holds null"},
+	}
+
+	for _, tt := range tests {
+		var n Node
+		require.NoError(t, json.Unmarshal([]byte(tt.input), &n))
+
+		_, err := Decode(&n)
+		assert.EqualErrorf(t, err, tt.expected, "decoding %s", tt.input)
+	}
+}
+
+// program returns a Program holding statement.
+func program(statement string) string {
+	return `{"kind": "Program", "children": {"statements": [` + statement + `]}}`
+}
+
+// expression returns a Program holding an ExpressionStatement of expression.
+func expression(expression string) string {
+	return program(`{"kind": "ExpressionStatement", "children": {"expression": ` + expression + `}}`)
+}
+
+func TestOptionalChildrenExist(t *testing.T) {
+	groups := map[string]reflect.Type{"HashPair": hashPairType}
+	for name, t := range kinds {
+		groups[name] = t
+	}
+
+	for child := range optional {
+		owner, field, _ := strings.Cut(child, ".")
+		ty, ok := groups[owner]
+		if assert.True(t, ok, "%s: unknown kind", child) {
+			_, ok = ty.FieldByName(strings.ToUpper(field[:1]) + field[1:])
+			assert.True(t, ok, "%s: unknown field", child)
+		}
+	}
+}
+
+func TestKindsListed(t *testing.T) {
+	sources := func(info fs.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }
+	packages, err := goparser.ParseDir(gotoken.NewFileSet(), "../ast", sources, 0)
+	require.NoError(t, err)
+
+	// Node types are the types with a TokenLiteral method
+	declared := []string{}
+	for _, file := range packages["ast"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Name.Name != "TokenLiteral" || fn.Recv == nil {
+				continue
+			}
+			recv := fn.Recv.List[0].Type.(*goast.StarExpr).X.(*goast.Ident)
+			declared = append(declared, recv.Name)
+		}
+	}
+
+	listed := []string{}
+	for kind := range kinds {
+		listed = append(listed, kind)
+	}
+
+	sort.Strings(declared)
+	sort.Strings(listed)
+	assert.Equal(t, declared, listed, "every node type must be in kinds")
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), input)
+	return program
+}
+
+// find returns the node of the kind in n starting first, the outermost of
+// those starting together.
+func find(t *testing.T, n *Node, kind string) *Node {
+	var found *Node
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		if n.Kind == kind && (found == nil || before(n.Span.Start, found.Span.Start) ||
+			n.Span.Start == found.Span.Start && before(found.Span.End, n.Span.End)) {
+			found = n
+		}
+		for _, child := range n.Children {
+			for _, c := range nodes(t, child) {
+				visit(c)
+			}
+		}
+	}
+
+	visit(n)
+	return found
+}
+
+func before(a, b Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// nodes returns the nodes in the child raw, which may be a node, an array or
+// a hash pair or enum variant.
+func nodes(t *testing.T, raw json.RawMessage) []*Node {
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) == nil {
+		found := []*Node{}
+		for _, item := range items {
+			found = append(found, nodes(t, item)...)
+		}
+		return found
+	}
+
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(raw, &fields))
+	if _, ok := fields["kind"]; ok {
+		var n Node
+		require.NoError(t, json.Unmarshal(raw, &n))
+		return []*Node{&n}
+	}
+
+	found := []*Node{}
+	for _, field := range fields {
+		found = append(found, nodes(t, field)...)
+	}
+	return found
+}
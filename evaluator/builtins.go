@@ -0,0 +1,370 @@
+package evaluator
+
+import (
+	"arkham/object"
+	"fmt"
+	"sort"
+)
+
+// builtins is populated in init because the higher-order builtins call back
+// into applyFunction, which would otherwise be an initialization cycle.
+var builtins map[string]*object.Builtin
+
+// callbackBuiltins holds the builtins that call functions passed to them.
+// They are applied with the call depth of the code calling them, so
+// recursion through them counts towards the maximum recursion depth.
+var callbackBuiltins map[*object.Builtin]callbackBuiltin
+
+type callbackBuiltin func(depth int, args ...object.Object) object.Object
+
+func init() {
+	builtins = map[string]*object.Builtin{
+		"len":   {Fn: builtinLen},
+		"first": {Fn: builtinFirst},
+		"last":  {Fn: builtinLast},
+		"rest":  {Fn: builtinRest},
+		"push":  {Fn: builtinPush},
+		"puts":  {Fn: builtinPuts},
+		"sum":   {Fn: builtinSum},
+		"error": {Fn: builtinError},
+		"type":  {Fn: builtinType},
+
+		"ok":        {Fn: builtinOk},
+		"err":       {Fn: builtinErr},
+		"is_ok":     {Fn: builtinIsOk},
+		"is_error":  {Fn: builtinIsError},
+		"unwrap":    {Fn: builtinUnwrap},
+		"unwrap_or": {Fn: builtinUnwrapOr},
+
+		"await": {Fn: builtinAwait},
+		"chan":  {Fn: builtinChan},
+		"send":  {Fn: builtinSend},
+		"recv":  {Fn: builtinRecv},
+		"close": {Fn: builtinClose},
+
+		"next": {Fn: builtinNext},
+	}
+
+	callbackBuiltins = map[*object.Builtin]callbackBuiltin{}
+	for name, callback := range map[string]callbackBuiltin{
+		"map":    builtinMap,
+		"filter": builtinFilter,
+		"reduce": builtinReduce,
+	} {
+		builtin := &object.Builtin{Fn: func(args ...object.Object) object.Object { return callback(0, args...) }}
+		builtins[name] = builtin
+		callbackBuiltins[builtin] = callback
+	}
+}
+
+// Builtins returns the names of the builtin functions in alphabetical order.
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func builtinLen(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(len(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(arg.Pairs))}
+	default:
+		return newTypeError("argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+func builtinFirst(args ...object.Object) object.Object {
+	arr, err := arrayArgument("first", 1, args)
+	if err != nil {
+		return err
+	}
+
+	if len(arr.Elements) > 0 {
+		return arr.Elements[0]
+	}
+
+	return NULL
+}
+
+func builtinLast(args ...object.Object) object.Object {
+	arr, err := arrayArgument("last", 1, args)
+	if err != nil {
+		return err
+	}
+
+	if length := len(arr.Elements); length > 0 {
+		return arr.Elements[length-1]
+	}
+
+	return NULL
+}
+
+func builtinRest(args ...object.Object) object.Object {
+	arr, err := arrayArgument("rest", 1, args)
+	if err != nil {
+		return err
+	}
+
+	length := len(arr.Elements)
+	if length == 0 {
+		return NULL
+	}
+
+	newElements := make([]object.Object, length-1)
+	copy(newElements, arr.Elements[1:length])
+
+	return &object.Array{Elements: newElements}
+}
+
+func builtinPush(args ...object.Object) object.Object {
+	arr, err := arrayArgument("push", 2, args)
+	if err != nil {
+		return err
+	}
+
+	length := len(arr.Elements)
+
+	newElements := make([]object.Object, length+1)
+	copy(newElements, arr.Elements)
+	newElements[length] = args[1]
+
+	return &object.Array{Elements: newElements}
+}
+
+func builtinPuts(args ...object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Println(arg.Inspect())
+	}
+
+	return NULL
+}
+
+func builtinMap(depth int, args ...object.Object) object.Object {
+	arr, err := arrayArgument("map", 2, args)
+	if err != nil {
+		return err
+	}
+
+	result := make([]object.Object, 0, len(arr.Elements))
+
+	for _, el := range arr.Elements {
+		mapped := applyFunction(args[1], []object.Object{el}, depth)
+		if isError(mapped) {
+			return mapped
+		}
+		result = append(result, mapped)
+	}
+
+	return &object.Array{Elements: result}
+}
+
+func builtinFilter(depth int, args ...object.Object) object.Object {
+	arr, err := arrayArgument("filter", 2, args)
+	if err != nil {
+		return err
+	}
+
+	result := []object.Object{}
+
+	for _, el := range arr.Elements {
+		keep := applyFunction(args[1], []object.Object{el}, depth)
+		if isError(keep) {
+			return keep
+		}
+
+		if isTruthy(keep) {
+			result = append(result, el)
+		}
+	}
+
+	return &object.Array{Elements: result}
+}
+
+func builtinReduce(depth int, args ...object.Object) object.Object {
+	arr, err := arrayArgument("reduce", 3, args)
+	if err != nil {
+		return err
+	}
+
+	acc := args[1]
+
+	for _, el := range arr.Elements {
+		acc = applyFunction(args[2], []object.Object{acc, el}, depth)
+		if isError(acc) {
+			return acc
+		}
+	}
+
+	return acc
+}
+
+func builtinSum(args ...object.Object) object.Object {
+	arr, err := arrayArgument("sum", 1, args)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+
+	for _, el := range arr.Elements {
+		integer, ok := el.(*object.Integer)
+		if !ok {
+			return newTypeError("argument to `sum` must contain only INTEGER, got %s", el.Type())
+		}
+		total += integer.Value
+	}
+
+	return &object.Integer{Value: total}
+}
+
+// builtinError raises an error with the given message and, optionally, kind.
+func builtinError(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newArgumentError("wrong number of arguments: want 1 to 2, got %d", len(args))
+	}
+
+	message, ok := args[0].(*object.String)
+	if !ok {
+		return newTypeError("argument to `error` must be STRING, got %s", args[0].Type())
+	}
+
+	kind := object.USER_ERROR
+
+	if len(args) == 2 {
+		k, ok := args[1].(*object.String)
+		if !ok {
+			return newTypeError("argument to `error` must be STRING, got %s", args[1].Type())
+		}
+		kind = k.Value
+	}
+
+	return &object.Error{Message: message.Value, Kind: kind}
+}
+
+// builtinType returns the type name of its argument, or the declared name of
+// a struct instance or enum value.
+func builtinType(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.StructInstance:
+		return &object.String{Value: arg.Struct.Name}
+	case *object.EnumValue:
+		return &object.String{Value: arg.Variant.Enum.Name}
+	}
+
+	return &object.String{Value: string(args[0].Type())}
+}
+
+func builtinOk(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(args))
+	}
+
+	return &object.Result{Ok: true, Value: args[0]}
+}
+
+func builtinErr(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(args))
+	}
+
+	return &object.Result{Ok: false, Value: args[0]}
+}
+
+func builtinIsOk(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(args))
+	}
+
+	result, ok := args[0].(*object.Result)
+	return nativeBoolToBooleanObject(ok && result.Ok)
+}
+
+// builtinIsError reports whether its argument is an err result or a caught
+// error.
+func builtinIsError(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Result:
+		return nativeBoolToBooleanObject(!arg.Ok)
+	case *object.ErrorValue:
+		return TRUE
+	default:
+		return FALSE
+	}
+}
+
+// builtinUnwrap returns the value of an ok result and raises the value of an
+// err result.
+func builtinUnwrap(args ...object.Object) object.Object {
+	result, err := resultArgument("unwrap", 1, args)
+	if err != nil {
+		return err
+	}
+
+	if !result.Ok {
+		return throwValue(result.Value)
+	}
+
+	return result.Value
+}
+
+func builtinUnwrapOr(args ...object.Object) object.Object {
+	result, err := resultArgument("unwrap_or", 2, args)
+	if err != nil {
+		return err
+	}
+
+	if !result.Ok {
+		return args[1]
+	}
+
+	return result.Value
+}
+
+// resultArgument checks the argument count of the builtin name and that its
+// first argument is a result.
+func resultArgument(name string, want int, args []object.Object) (*object.Result, *object.Error) {
+	if len(args) != want {
+		return nil, newArgumentError("wrong number of arguments: want %d, got %d", want, len(args))
+	}
+
+	result, ok := args[0].(*object.Result)
+	if !ok {
+		return nil, newTypeError("argument to `%s` must be RESULT, got %s", name, args[0].Type())
+	}
+
+	return result, nil
+}
+
+// arrayArgument checks the argument count of the builtin name and that its
+// first argument is an array.
+func arrayArgument(name string, want int, args []object.Object) (*object.Array, *object.Error) {
+	if len(args) != want {
+		return nil, newArgumentError("wrong number of arguments: want %d, got %d", want, len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newTypeError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	return arr, nil
+}
@@ -0,0 +1,251 @@
+package evaluator
+
+import (
+	"arkham/ast"
+	"arkham/object"
+	"reflect"
+)
+
+// evalSpawnExpression starts a task running a call, or a function called
+// without arguments. The function and arguments of a call are evaluated
+// before the task starts. A spawned closure shares the environment it
+// captured with the spawning code, as any closure does, so it sees the
+// bindings of that environment as they are when it looks them up.
+func evalSpawnExpression(node *ast.SpawnExpression, env *object.Environment) object.Object {
+	var (
+		function object.Object
+		args     []object.Object
+		named    map[string]object.Object
+	)
+
+	call, isCall := node.Call.(*ast.CallExpression)
+	if isCall {
+		var err object.Object
+		function, args, named, err = evalCall(call, env)
+		if err != nil {
+			return err
+		}
+	} else {
+		function = Eval(node.Call, env)
+		if isUnwinding(function) {
+			return function
+		}
+	}
+
+	task := object.NewTask()
+	depth := env.CallDepth()
+
+	go func() {
+		defer func() {
+			// A bug in a task fails it rather than the process running it
+			if r := recover(); r != nil {
+				task.Complete(newError("task panicked: %v", r))
+			}
+		}()
+
+		result := applyFunctionWithNamed(function, args, named, depth)
+		if err, ok := result.(*object.Error); ok && isCall {
+			err.Trace = append(err.Trace, callFrame(call))
+		}
+
+		if result == nil {
+			result = NULL
+		}
+
+		task.Complete(result)
+	}()
+
+	return task
+}
+
+// evalSelectExpression evaluates the channels and values of every case,
+// then waits for one of the cases to proceed. Among several cases that can
+// proceed one is chosen at random; the default case is chosen when no other
+// case can proceed right away.
+func evalSelectExpression(se *ast.SelectExpression, env *object.Environment) object.Object {
+	cases := make([]reflect.SelectCase, len(se.Cases))
+
+	for i, c := range se.Cases {
+		if c.Operation == nil {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectDefault}
+			continue
+		}
+
+		name := c.Operation.Function.(*ast.Identifier).Value
+
+		args := evalExpressions(c.Operation.Arguments, env)
+		if len(args) == 1 && isUnwinding(args[0]) {
+			return args[0]
+		}
+
+		ch, ok := args[0].(*object.Channel)
+		if !ok {
+			return newTypeError("argument to `%s` must be CHANNEL, got %s", name, args[0].Type())
+		}
+
+		if name == "send" {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.Ch), Send: reflect.ValueOf(args[1])}
+		} else {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.Ch)}
+		}
+	}
+
+	chosen, received, err := selectCase(cases)
+	if err != nil {
+		return err
+	}
+
+	c := se.Cases[chosen]
+	caseEnv := newScopeEnvironment(env, c.Scope)
+
+	if c.Name != nil {
+		bind(c.Name, received, caseEnv)
+	}
+
+	return Eval(c.Body, caseEnv)
+}
+
+// selectCase runs a select statement over cases. It returns the received
+// value, or null when the chosen case is not a receive or its channel is
+// closed.
+func selectCase(cases []reflect.SelectCase) (chosen int, received object.Object, err *object.Error) {
+	defer func() {
+		// Sending on a closed channel panics
+		if recover() != nil {
+			err = newError("send on closed channel")
+		}
+	}()
+
+	chosen, value, ok := reflect.Select(cases)
+	if !ok {
+		return chosen, NULL, nil
+	}
+
+	return chosen, value.Interface().(object.Object), nil
+}
+
+// builtinAwait waits for a task to complete and returns its result, raising
+// the error it failed with.
+func builtinAwait(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(args))
+	}
+
+	task, ok := args[0].(*object.Task)
+	if !ok {
+		return newTypeError("argument to `await` must be TASK, got %s", args[0].Type())
+	}
+
+	result := task.Wait()
+
+	// Each await raises its own copy of an error, as callers extend its trace
+	if err, ok := result.(*object.Error); ok {
+		raised := *err
+		raised.Trace = append([]string{}, err.Trace...)
+		return &raised
+	}
+
+	return result
+}
+
+// maxChannelCapacity is the most values a channel holds, keeping chan from
+// allocating without bound.
+const maxChannelCapacity = 1 << 20
+
+// builtinChan creates a channel holding up to the given number of values, or
+// an unbuffered one.
+func builtinChan(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newArgumentError("wrong number of arguments: want 0 to 1, got %d", len(args))
+	}
+
+	var size int64
+	if len(args) == 1 {
+		integer, ok := args[0].(*object.Integer)
+		if !ok {
+			return newTypeError("argument to `chan` must be INTEGER, got %s", args[0].Type())
+		}
+
+		if integer.Value < 0 {
+			return newArgumentError("negative channel capacity: %d", integer.Value)
+		}
+		if integer.Value > maxChannelCapacity {
+			return newArgumentError("channel capacity %d exceeds the maximum %d", integer.Value, maxChannelCapacity)
+		}
+		size = integer.Value
+	}
+
+	return &object.Channel{Ch: make(chan object.Object, size)}
+}
+
+func builtinSend(args ...object.Object) (result object.Object) {
+	ch, err := channelArgument("send", 2, args)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// Sending on a closed channel panics
+		if recover() != nil {
+			result = newError("send on closed channel")
+		}
+	}()
+
+	ch.Ch <- args[1]
+
+	return NULL
+}
+
+// builtinRecv waits for a value from a channel, returning null once the
+// channel is closed and drained.
+func builtinRecv(args ...object.Object) object.Object {
+	ch, err := channelArgument("recv", 1, args)
+	if err != nil {
+		return err
+	}
+
+	value, ok := <-ch.Ch
+	if !ok {
+		return NULL
+	}
+
+	return value
+}
+
+// builtinClose closes a channel, or a generator like its close method.
+func builtinClose(args ...object.Object) (result object.Object) {
+	if len(args) == 1 && args[0].Type() == object.GENERATOR_OBJ {
+		return methodClose(args...)
+	}
+
+	ch, err := channelArgument("close", 1, args)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// Closing a closed channel panics
+		if recover() != nil {
+			result = newError("close of closed channel")
+		}
+	}()
+
+	close(ch.Ch)
+
+	return NULL
+}
+
+// channelArgument checks the argument count of the builtin name and that its
+// first argument is a channel.
+func channelArgument(name string, want int, args []object.Object) (*object.Channel, *object.Error) {
+	if len(args) != want {
+		return nil, newArgumentError("wrong number of arguments: want %d, got %d", want, len(args))
+	}
+
+	ch, ok := args[0].(*object.Channel)
+	if !ok {
+		return nil, newTypeError("argument to `%s` must be CHANNEL, got %s", name, args[0].Type())
+	}
+
+	return ch, nil
+}
@@ -0,0 +1,187 @@
+package evaluator
+
+import (
+	"arkham/object"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"await(spawn fn() { 1 + 2 })", 3},
+		{"let add = fn(a, b) { a + b }; await(spawn add(2, b: 5))", 7},
+		{"let t = spawn fn() { 1 }; t.await()", 1},
+		{"spawn fn() { 1 }", "<task>"},
+		{"let worker = fn(id, ch) { send(ch, id * 10) }; let ch = chan(3); map([1, 2, 3], fn(i) { spawn worker(i, ch) }) |> map(await); sum([recv(ch), recv(ch), recv(ch)])", 60},
+		{"let ch = chan(); spawn fn() { send(ch, 1); send(ch, 2); close(ch) }; let f = fn() { for (x in ch) { if (x == 2) { return x * 10 } } }; f()", 20},
+		{"let ch = chan(1); send(ch, 4); close(ch); [recv(ch), recv(ch)]", "[4, null]"},
+		{"let ch = chan(2); send(ch, 1); ch", "<channel 1/2>"},
+		{"let n = 1; let t = spawn fn() { let n = 2; n }; [await(t), n]", "[2, 1]"},
+		{"await(spawn fn() { 1 + true })", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() { error(\"boom\") }; try { await(spawn f()) } catch (e) { e.trace }", "[error at 1:21, f at 1:52, await at 1:44]"},
+		{"let t = spawn fn() { error(\"x\") }; [try { await(t) } catch (e) { e.trace }, try { await(t) } catch (e) { e.trace }]",
+			"[[error at 1:27, await at 1:48], [error at 1:27, await at 1:88]]"},
+		{"await(spawn 5)", "not a function: INTEGER"},
+		{"await(1)", "argument to `await` must be TASK, got INTEGER"},
+		{"chan(-1)", "negative channel capacity: -1"},
+		{"chan(99999999999999)", "channel capacity 99999999999999 exceeds the maximum 1048576"},
+		{"chan(1048576)", "<channel 0/1048576>"},
+		{"chan(\"a\")", "argument to `chan` must be INTEGER, got STRING"},
+		{"send(1, 2)", "argument to `send` must be CHANNEL, got INTEGER"},
+		{"let ch = chan(1); close(ch); send(ch, 1)", "send on closed channel"},
+		{"let ch = chan(1); close(ch); close(ch)", "close of closed channel"},
+		{"let g = fn*() { yield 1 }(); close(g); g.next().done", "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestSelectExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let ch = chan(1); send(ch, 5); select { recv(ch) as v => v * 2, _ => 0 }", 10},
+		{"let ch = chan(1); select { recv(ch) as v => v, _ => 0 }", 0},
+		{"let ch = chan(1); select { send(ch, 3) => recv(ch), _ => 0 }", 3},
+		{"let ch = chan(1); send(ch, 1); select { send(ch, 3) => 1, _ => { 2 } }", 2},
+		{"let ch = chan(); spawn fn() { send(ch, 7) }; select { recv(ch) as v => v }", 7},
+		{"let a = chan(); let b = chan(); spawn fn() { send(b, 8) }; select { recv(a) as v => v, recv(b) as v => v + 1 }", 9},
+		{"let ch = chan(); close(ch); select { recv(ch) as v => v }", nil},
+		{"let ch = chan(1); close(ch); select { send(ch, 1) => 1 }", "send on closed channel"},
+		{"select { recv(1) => 1 }", "argument to `recv` must be CHANNEL, got INTEGER"},
+		{"select { recv(nope) => 1 }", "identifier not found: nope"},
+		{"select { _ => 4 }", 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestPanickingTask(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("boom", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		panic("boom")
+	}})
+
+	evaluated := testEvalEnv("await(spawn boom())", env)
+
+	errObj, ok := evaluated.(*object.Error)
+	require.True(t, ok, "got %T (%+v)", evaluated, evaluated)
+	assert.Equal(t, "task panicked: boom", errObj.Message)
+}
+
+func TestSpawnedTasksShareEnvironment(t *testing.T) {
+	// Run under -race: the tasks bind names in and read from shared scopes
+	input := `
+	let count = 10;
+	let ch = chan(count);
+	let work = fn(i) {
+		let squared = i * i;
+		send(ch, squared + count - count);
+	};
+	let tasks = map([1, 2, 3, 4, 5, 6, 7, 8, 9, 10], fn(i) { spawn work(i) });
+	let touch = map([1, 2, 3, 4, 5, 6, 7, 8, 9, 10], fn(i) { spawn fn() { let shared = i; shared } });
+	map(tasks, await);
+	map(touch, await);
+	close(ch);
+	let total = fn(xs) { reduce(xs, 0, fn(acc, x) { acc + x }) };
+	let drain = fn*() { for (x in ch) { yield x } };
+	let collect = fn(g, acc) { let step = g.next(); if (step.done) { acc } else { collect(g, push(acc, step.value)) } };
+	total(collect(drain(), []))`
+
+	evaluated := testEval(input)
+	testIntegerObject(t, evaluated, 385)
+}
+
+func TestForkedPrelude(t *testing.T) {
+	prelude := object.NewEnvironment()
+	testEvalEnv(`let double = fn(x) { x * 2 }; let base = 10;`, prelude)
+	prelude.Freeze()
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"double(base)", 20},
+		{"let base = 1; double(base)", 2},
+		{"let double = fn(x) { x }; double(base)", 10},
+		{"struct P { x }; P(base).x", 10},
+	}
+
+	// Run under -race: the forks evaluate concurrently against one prelude
+	results := make([]object.Object, len(tests))
+	done := make(chan struct{})
+	for i, tt := range tests {
+		go func(i int, input string) {
+			results[i] = testEvalEnv(input, prelude.Fork())
+			done <- struct{}{}
+		}(i, tt.input)
+	}
+	for range tests {
+		<-done
+	}
+
+	for i, tt := range tests {
+		testIntegerObject(t, results[i], tt.expected)
+	}
+
+	base, _ := prelude.Get("base")
+	testIntegerObject(t, base, 10)
+}
+
+func TestFrozenEnvironment(t *testing.T) {
+	tests := []string{
+		"let x = 1",
+		"let [a, b] = [1, 2]",
+		"let [a, ...rest] = [1, 2]",
+		"fn f() { 1 }",
+		"struct P { x }",
+		"enum E { A }",
+	}
+
+	for _, input := range tests {
+		env := object.NewEnvironment()
+		env.Freeze()
+
+		evaluated := testEvalEnv(input, env)
+
+		errObj, ok := evaluated.(*object.Error)
+		require.Truef(t, ok, "no error object returned for %s. got=%T(%+v)", input, evaluated, evaluated)
+		assert.Contains(t, errObj.Message, "in a frozen environment", "input: %s", input)
+	}
+}
@@ -0,0 +1,199 @@
+package evaluator
+
+import (
+	"arkham/ast"
+	"arkham/object"
+	"strings"
+)
+
+// evalEnumStatement binds the enum and each of its variants: a constructor
+// for variants with fields and the single value of unit variants.
+func evalEnumStatement(node *ast.EnumStatement, env *object.Environment) object.Object {
+	enum := &object.EnumType{Name: node.Name.Value}
+
+	for _, v := range node.Variants {
+		variant := &object.EnumVariant{Enum: enum, Name: v.Name.Value, Unit: v.Fields == nil}
+		for _, field := range v.Fields {
+			variant.Fields = append(variant.Fields, field.Value)
+		}
+		enum.Variants = append(enum.Variants, variant)
+	}
+
+	if bound := bind(node.Name, enum, env); isError(bound) {
+		return bound
+	}
+
+	for i, variant := range enum.Variants {
+		bind(node.Variants[i].Name, variantObject(variant), env)
+	}
+
+	return nil
+}
+
+// variantObject returns what the name of variant evaluates to.
+func variantObject(variant *object.EnumVariant) object.Object {
+	if variant.Unit {
+		return &object.EnumValue{Variant: variant}
+	}
+	return variant
+}
+
+func constructEnumValue(variant *object.EnumVariant, args []object.Object, named map[string]object.Object) object.Object {
+	values, err := fieldValues(variant.Name, variant.Fields, args, named)
+	if err != nil {
+		return err
+	}
+
+	return &object.EnumValue{Variant: variant, Values: values}
+}
+
+func evalEnumMember(enum *object.EnumType, name string) object.Object {
+	if variant, ok := enum.Variant(name); ok {
+		return variantObject(variant)
+	}
+
+	return newNameError("%s has no variant %s", enum.Name, name)
+}
+
+func evalEnumField(ev *object.EnumValue, name string) object.Object {
+	if val, ok := ev.Get(name); ok {
+		return val
+	}
+
+	return newNameError("%s has no field %s", ev.Variant.Name, name)
+}
+
+// unitVariant reports whether the identifier pattern ident names a unit
+// variant in scope, in which case it matches that variant instead of binding
+// a new name.
+func unitVariant(ident *ast.Identifier, env *object.Environment) (*object.EnumVariant, bool) {
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	ev, ok := obj.(*object.EnumValue)
+	if !ok || !ev.Variant.Unit || ev.Variant.Name != ident.Value {
+		return nil, false
+	}
+
+	return ev.Variant, true
+}
+
+func resolveVariant(pattern *ast.VariantPattern, env *object.Environment) (*object.EnumVariant, *object.Error) {
+	if pattern.Enum != nil {
+		obj, ok := env.Get(pattern.Enum.Value)
+		if !ok {
+			return nil, newNameError("identifier not found: %s", pattern.Enum.Value)
+		}
+
+		enum, ok := obj.(*object.EnumType)
+		if !ok {
+			return nil, newTypeError("not an enum: %s", pattern.Enum.Value)
+		}
+
+		variant, ok := enum.Variant(pattern.Name.Value)
+		if !ok {
+			return nil, newNameError("%s has no variant %s", enum.Name, pattern.Name.Value)
+		}
+
+		return variant, nil
+	}
+
+	obj, ok := env.Get(pattern.Name.Value)
+	if !ok {
+		return nil, newNameError("identifier not found: %s", pattern.Name.Value)
+	}
+
+	switch obj := obj.(type) {
+	case *object.EnumVariant:
+		return obj, nil
+	case *object.EnumValue:
+		return obj.Variant, nil
+	default:
+		return nil, newTypeError("not an enum variant: %s", pattern.Name.Value)
+	}
+}
+
+func matchVariantPattern(pattern *ast.VariantPattern, value object.Object, env *object.Environment) (bool, *object.Error) {
+	variant, err := resolveVariant(pattern, env)
+	if err != nil {
+		return false, err
+	}
+
+	if len(pattern.Arguments) != len(variant.Fields) {
+		return false, newPatternError("wrong number of fields in pattern %s: want %d, got %d",
+			pattern.String(), len(variant.Fields), len(pattern.Arguments))
+	}
+
+	ev, ok := value.(*object.EnumValue)
+	if !ok || ev.Variant != variant {
+		return false, nil
+	}
+
+	for i, arg := range pattern.Arguments {
+		matched, err := matchPattern(arg, ev.Values[i], env)
+		if err != nil || !matched {
+			return matched, err
+		}
+	}
+
+	return true, nil
+}
+
+// checkExhaustive returns an error naming the variants of enum not handled
+// by node. A variant is handled by an unguarded arm matching it with only
+// bindings for its fields, or by an unguarded binding or wildcard arm.
+func checkExhaustive(node *ast.MatchExpression, enum *object.EnumType, env *object.Environment) *object.Error {
+	handled := map[*object.EnumVariant]bool{}
+
+	for _, arm := range node.Arms {
+		if arm.Guard != nil {
+			continue
+		}
+
+		switch pattern := arm.Pattern.(type) {
+		case *ast.Identifier:
+			variant, ok := unitVariant(pattern, env)
+			if !ok {
+				return nil
+			}
+			handled[variant] = true
+		case *ast.VariantPattern:
+			variant, err := resolveVariant(pattern, env)
+			if err == nil && irrefutable(pattern.Arguments, env) {
+				handled[variant] = true
+			}
+		}
+	}
+
+	missing := []string{}
+	for _, variant := range enum.Variants {
+		if !handled[variant] {
+			missing = append(missing, variant.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return newPatternError("non-exhaustive match on %s: missing %s", enum.Name, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// irrefutable reports whether patterns match any values, i.e. are all
+// bindings or wildcards.
+func irrefutable(patterns []ast.Expression, env *object.Environment) bool {
+	for _, pattern := range patterns {
+		ident, ok := pattern.(*ast.Identifier)
+		if !ok {
+			return false
+		}
+
+		if _, ok := unitVariant(ident, env); ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -3,7 +3,9 @@ package evaluator
 import (
 	"arkham/ast"
 	"arkham/object"
+	"bytes"
 	"fmt"
+	"sync/atomic"
 )
 
 var (
@@ -12,6 +14,30 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// DefaultMaxRecursionDepth is the default maximum number of nested calls of
+// user functions. It leaves ample room below the Go stack limit for calls
+// nested deep inside expressions.
+const DefaultMaxRecursionDepth = 10000
+
+var maxRecursionDepth atomic.Int64
+
+func init() {
+	maxRecursionDepth.Store(DefaultMaxRecursionDepth)
+}
+
+// MaxRecursionDepth returns the maximum number of nested calls of user
+// functions. A call nested deeper fails with an error rather than
+// overflowing the Go stack. Calls in tail position do not nest.
+func MaxRecursionDepth() int {
+	return int(maxRecursionDepth.Load())
+}
+
+// SetMaxRecursionDepth sets the maximum number of nested calls of user
+// functions and returns the previous maximum.
+func SetMaxRecursionDepth(depth int) int {
+	return int(maxRecursionDepth.Swap(int64(depth)))
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
@@ -21,18 +47,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isUnwinding(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
 		right := Eval(node.Right, env)
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isUnwinding(left) {
 			return left
 		}
 
-		if isError(right) {
+		if isUnwinding(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
@@ -40,34 +66,98 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isUnwinding(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, val, env); err != nil {
+				return err
+			}
+		} else if bound := bind(node.Name, val, env); isError(bound) {
+			return bound
+		}
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if isUnwinding(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isUnwinding(val) {
+			return val
+		}
+		return throwValue(val)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.StructStatement:
+		return evalStructStatement(node, env)
+	case *ast.EnumStatement:
+		return evalEnumStatement(node, env)
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isUnwinding(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Property.Value)
+	case *ast.PropagateExpression:
+		val := Eval(node.Value, env)
+		if isUnwinding(val) {
+			return val
+		}
+		return evalPropagateExpression(val)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		fn := &object.Function{Parameters: params, Rest: node.Rest, Env: env, Body: body, Generator: node.Generator, Scope: node.Scope}
+		if node.Name != nil {
+			if bound := bind(node.Name, fn, env); isError(bound) {
+				return bound
+			}
+		}
+		return fn
+	case *ast.YieldExpression:
+		return evalYieldExpression(node, env)
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+		if node.IsCallTo("quote") {
+			return quote(node, env)
+		}
+		return evalCallExpression(node, env)
+	case *ast.MacroLiteral:
+		return newError("a macro must be defined by a top-level let statement")
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isUnwinding(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isUnwinding(left) {
+			return left
 		}
 
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		index := Eval(node.Index, env)
+		if isUnwinding(index) {
+			return index
 		}
 
-		return applyFunction(function, args)
+		return evalIndexExpression(left, index)
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
@@ -75,6 +165,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.Integer{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	}
@@ -116,6 +208,11 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 		}
 	}
 
+	// An empty block, or one ending in a let statement, has no value
+	if result == nil {
+		return NULL
+	}
+
 	return result
 }
 
@@ -135,11 +232,20 @@ func evalStatements(stmts []ast.Statement, env *object.Environment) object.Objec
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
-	var result []object.Object
+	result := []object.Object{}
 
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			elements, err := evalSpreadExpression(spread, env)
+			if err != nil {
+				return []object.Object{err}
+			}
+			result = append(result, elements...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		if isUnwinding(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -147,6 +253,20 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
+func evalSpreadExpression(spread *ast.SpreadExpression, env *object.Environment) ([]object.Object, object.Object) {
+	value := Eval(spread.Value, env)
+	if isUnwinding(value) {
+		return nil, value
+	}
+
+	array, ok := value.(*object.Array)
+	if !ok {
+		return nil, newTypeError("cannot spread %s", value.Type())
+	}
+
+	return array.Elements, nil
+}
+
 func nativeBoolToBooleanObject(value bool) *object.Boolean {
 	if value {
 		return TRUE
@@ -161,7 +281,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newTypeError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -170,21 +290,21 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(left.Equals(right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!left.Equals(right))
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newTypeError("unknown operator: -%s", right.Type())
 	}
 
 	value := right.(*object.Integer).Value
@@ -226,13 +346,13 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case ">":
 		return nativeBoolToBooleanObject(leftValue > rightValue)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
@@ -240,10 +360,29 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	return &object.String{Value: leftVal + rightVal}
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		if str, ok := part.(*ast.StringLiteral); ok {
+			out.WriteString(str.Value)
+			continue
+		}
+
+		value := Eval(part, env)
+		if isUnwinding(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 
-	if isError(condition) {
+	if isUnwinding(condition) {
 		return condition
 	}
 
@@ -256,34 +395,701 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	val, ok := env.Get(node.Value)
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		catchEnv := newScopeEnvironment(env, te.CatchScope)
+		bind(te.Param, &object.ErrorValue{Error: err}, catchEnv)
+		result = Eval(te.Catch, catchEnv)
+	}
+
+	if te.Finally != nil {
+		// An error or return in the finally block replaces the pending result
+		final := Eval(te.Finally, env)
+		if final != nil {
+			if rt := final.Type(); rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return final
+			}
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+// throwValue raises val. Throwing a caught error re-raises it with its kind
+// and trace, anything else is raised as a user error carrying the value.
+func throwValue(val object.Object) *object.Error {
+	if ev, ok := val.(*object.ErrorValue); ok {
+		rethrown := *ev.Error
+		rethrown.Trace = append([]string{}, ev.Error.Trace...)
+		return &rethrown
+	}
+
+	return &object.Error{Message: val.Inspect(), Kind: object.USER_ERROR, Value: val}
+}
+
+// evalPropagateExpression unwraps an ok result, or returns an err result
+// from the enclosing function.
+func evalPropagateExpression(val object.Object) object.Object {
+	result, ok := val.(*object.Result)
+	if !ok {
+		return newTypeError("operator ? not supported: %s", val.Type())
+	}
+
+	if !result.Ok {
+		return &object.ReturnValue{Value: result}
+	}
+
+	return result.Value
+}
+
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	arm, armEnv, result := matchArm(me, env)
+	if arm == nil {
+		return result
+	}
+
+	return Eval(arm.Body, armEnv)
+}
+
+// matchArm returns the first arm of me matching its subject and the
+// environment binding the arm's pattern, or a nil arm and the result of the
+// match when no arm matches or matching fails.
+func matchArm(me *ast.MatchExpression, env *object.Environment) (*ast.MatchArm, *object.Environment, object.Object) {
+	subject := Eval(me.Subject, env)
+	if isUnwinding(subject) {
+		return nil, nil, subject
+	}
+
+	if ev, ok := subject.(*object.EnumValue); ok {
+		if err := checkExhaustive(me, ev.Variant.Enum, env); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, arm := range me.Arms {
+		armEnv := newScopeEnvironment(env, arm.Scope)
+
+		matched, err := matchPattern(arm.Pattern, subject, armEnv)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isUnwinding(guard) {
+				return nil, nil, guard
+			}
+
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return arm, armEnv, nil
+	}
+
+	return nil, nil, NULL
+}
+
+// bindPattern destructures value into env, returning an error when value
+// does not have the shape pattern expects.
+func bindPattern(pattern ast.Expression, value object.Object, env *object.Environment) *object.Error {
+	matched, err := matchPattern(pattern, value, env)
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		return newPatternError("cannot destructure %s %s with pattern %s", value.Type(), value.Inspect(), pattern.String())
+	}
+
+	return nil
+}
+
+// matchPattern reports whether value matches pattern, binding any identifiers
+// in the pattern into env. The wildcard _ matches anything without binding.
+func matchPattern(pattern ast.Expression, value object.Object, env *object.Environment) (bool, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if variant, ok := unitVariant(pattern, env); ok {
+			ev, ok := value.(*object.EnumValue)
+			return ok && ev.Variant == variant, nil
+		}
+
+		if pattern.Value != "_" {
+			if bound := bind(pattern, value, env); isError(bound) {
+				return false, bound.(*object.Error)
+			}
+		}
+		return true, nil
+	case *ast.ArrayPattern:
+		return matchArrayPattern(pattern, value, env)
+	case *ast.HashPattern:
+		return matchHashPattern(pattern, value, env)
+	case *ast.StructPattern:
+		return matchStructPattern(pattern, value, env)
+	case *ast.VariantPattern:
+		return matchVariantPattern(pattern, value, env)
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean, *ast.PrefixExpression:
+		literal := Eval(pattern, env)
+		if isError(literal) {
+			return false, literal.(*object.Error)
+		}
+		return literal.Equals(value), nil
+	default:
+		return false, newError("unsupported pattern: %s", pattern.String())
+	}
+}
+
+func matchArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment) (bool, *object.Error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return false, nil
+	}
+
+	elements := array.Elements
+
+	if len(elements) < len(pattern.Elements) {
+		return false, nil
+	}
+
+	if pattern.Rest == nil && len(elements) != len(pattern.Elements) {
+		return false, nil
+	}
+
+	for i, el := range pattern.Elements {
+		matched, err := matchPattern(el, elements[i], env)
+		if err != nil || !matched {
+			return matched, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := make([]object.Object, len(elements)-len(pattern.Elements))
+		copy(rest, elements[len(pattern.Elements):])
+		if bound := bind(pattern.Rest, &object.Array{Elements: rest}, env); isError(bound) {
+			return false, bound.(*object.Error)
+		}
+	}
+
+	return true, nil
+}
+
+func matchHashPattern(pattern *ast.HashPattern, value object.Object, env *object.Environment) (bool, *object.Error) {
+	hash, ok := value.(*object.Hash)
 	if !ok {
-		return newError("identifier not found: " + node.Value)
+		return false, nil
+	}
+
+	for _, pair := range pattern.Pairs {
+		var key object.Object
+
+		// Identifier keys name string keys rather than variables
+		if ident, ok := pair.Key.(*ast.Identifier); ok {
+			key = &object.String{Value: ident.Value}
+		} else {
+			key = Eval(pair.Key, env)
+			if isError(key) {
+				return false, key.(*object.Error)
+			}
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return false, newTypeError("unusable as hash key: %s", key.Type())
+		}
+
+		val, ok := hash.Get(hashKey)
+		if !ok {
+			return false, nil
+		}
+
+		matched, err := matchPattern(pair.Value, val, env)
+		if err != nil || !matched {
+			return matched, err
+		}
+	}
+
+	return true, nil
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorValueField(left.(*object.ErrorValue), index.(*object.String).Value)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalModuleExport(left.(*object.Module), index.(*object.String).Value)
+	default:
+		return newTypeError("index operator not supported: %s", left.Type())
 	}
-	return val
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	function, ok := fn.(*object.Function)
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+	max := int64(len(elements) - 1)
 
+	if idx < 0 || idx > max {
+		return NULL
+	}
+
+	return elements[idx]
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newTypeError("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hash.(*object.Hash).Get(key)
 	if !ok {
-		return newError("not a function: %s", fn.Type())
+		return NULL
 	}
 
-	extendedEnv := extendedFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendedEnv)
-	return unwrapReturnValue(evaluated)
+	return value
+}
+
+// evalErrorValueField looks up the message, kind, trace or thrown value of a
+// caught error.
+func evalErrorValueField(ev *object.ErrorValue, field string) object.Object {
+	switch field {
+	case "message":
+		return &object.String{Value: ev.Error.Message}
+	case "kind":
+		return &object.String{Value: ev.Error.Kind}
+	case "trace":
+		frames := []object.Object{}
+		for _, frame := range ev.Error.Trace {
+			frames = append(frames, &object.String{Value: frame})
+		}
+		return &object.Array{Elements: frames}
+	case "value":
+		if ev.Error.Value != nil {
+			return ev.Error.Value
+		}
+		return NULL
+	default:
+		return NULL
+	}
 }
 
-func extendedFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+// evalMemberExpression looks up the field name of a hash, module or caught
+// error.
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Hash:
+		return evalHashIndexExpression(obj, &object.String{Value: name})
+	case *object.Module:
+		return evalModuleExport(obj, name)
+	case *object.ErrorValue:
+		return evalErrorValueField(obj, name)
+	case *object.StructInstance:
+		return evalStructField(obj, name)
+	case *object.EnumType:
+		return evalEnumMember(obj, name)
+	case *object.EnumValue:
+		return evalEnumField(obj, name)
+	default:
+		return newTypeError("field access not supported: %s.%s", obj.Type(), name)
+	}
+}
+
+// evalMethod resolves the function called by x.f(...). A function stored in
+// a hash field or exported by a module is called as is. Otherwise f is a
+// method of the type of x or the function f in scope, and x is returned as
+// the receiver to pass as the first argument.
+func evalMethod(node *ast.MemberExpression, env *object.Environment) (object.Object, object.Object) {
+	obj := Eval(node.Object, env)
+	if isUnwinding(obj) {
+		return obj, nil
+	}
+
+	name := node.Property.Value
+
+	switch obj := obj.(type) {
+	case *object.Module:
+		return evalModuleExport(obj, name), nil
+	case *object.Hash:
+		if field, ok := obj.Get(&object.String{Value: name}); ok {
+			return field, nil
+		}
+	case *object.StructInstance:
+		if field, ok := obj.Get(name); ok {
+			return field, nil
+		}
+	case *object.EnumType:
+		return evalEnumMember(obj, name), nil
+	case *object.EnumValue:
+		if field, ok := obj.Get(name); ok {
+			return field, nil
+		}
+	}
+
+	if method, ok := methods[obj.Type()][name]; ok {
+		return method, obj
+	}
+
+	if fn, ok := env.Get(name); ok {
+		return fn, obj
+	}
+
+	if builtin, ok := builtins[name]; ok {
+		return builtin, obj
+	}
+
+	return newNameError("undefined method %s for %s", name, obj.Type()), nil
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isUnwinding(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newTypeError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isUnwinding(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Binding != nil {
+		if val, ok := env.Lookup(node.Binding.Depth, node.Binding.Slot, node.Value); ok {
+			return val
+		}
+	} else if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+
+	return newNameError("identifier not found: " + node.Value)
+}
+
+// Defined returns a function reporting whether a name is bound in env or is
+// a builtin, for resolving programs to be evaluated in env.
+func Defined(env *object.Environment) func(name string) bool {
+	return func(name string) bool {
+		if _, ok := builtins[name]; ok {
+			return true
+		}
+
+		_, ok := env.Get(name)
+		return ok
+	}
+}
+
+func evalCallExpression(node *ast.CallExpression, env *object.Environment) object.Object {
+	function, args, named, err := evalCall(node, env)
+	if err != nil {
+		return err
+	}
+
+	return applyCall(node, env, function, args, named)
+}
+
+// applyCall applies the function of the call node evaluated in env, adding
+// the call to the trace of any error it raises.
+func applyCall(node *ast.CallExpression, env *object.Environment, function object.Object, args []object.Object, named map[string]object.Object) object.Object {
+	result := applyFunctionWithNamed(function, args, named, env.CallDepth())
+	if err, ok := result.(*object.Error); ok {
+		err.Trace = append(err.Trace, callFrame(node))
+	}
+
+	return result
+}
+
+// evalCall evaluates the function and arguments of a call, returning any
+// error or return value raised while doing so instead. A method receiver is
+// passed as the first positional argument.
+func evalCall(node *ast.CallExpression, env *object.Environment) (object.Object, []object.Object, map[string]object.Object, object.Object) {
+	var function, receiver object.Object
+	if member, ok := node.Function.(*ast.MemberExpression); ok {
+		function, receiver = evalMethod(member, env)
+	} else {
+		function = Eval(node.Function, env)
+	}
+
+	if isUnwinding(function) {
+		return nil, nil, nil, function
+	}
+
+	positional := []ast.Expression{}
+	named := map[string]object.Object{}
+
+	for _, arg := range node.Arguments {
+		na, ok := arg.(*ast.NamedArgument)
+		if !ok {
+			positional = append(positional, arg)
+			continue
+		}
+
+		if _, ok := named[na.Name.Value]; ok {
+			return nil, nil, nil, newArgumentError("duplicate named argument: %s", na.Name.Value)
+		}
+
+		value := Eval(na.Value, env)
+		if isUnwinding(value) {
+			return nil, nil, nil, value
+		}
+		named[na.Name.Value] = value
+	}
+
+	args := evalExpressions(positional, env)
+	if len(args) == 1 && isUnwinding(args[0]) {
+		return nil, nil, nil, args[0]
+	}
+
+	if receiver != nil {
+		args = append([]object.Object{receiver}, args...)
+	}
+
+	return function, args, named, nil
+}
+
+// callFrame describes a call site for error traces, e.g. "f at 3:5".
+func callFrame(node *ast.CallExpression) string {
+	name := "<anonymous>"
+	switch fn := node.Function.(type) {
+	case *ast.Identifier:
+		name = fn.Value
+	case *ast.MemberExpression:
+		name = fn.Property.Value
+	}
+
+	return fmt.Sprintf("%s at %d:%d", name, node.Token.Line, node.Token.Column)
+}
+
+// applyFunction applies fn to args from code depth calls deep.
+func applyFunction(fn object.Object, args []object.Object, depth int) object.Object {
+	return applyFunctionWithNamed(fn, args, nil, depth)
+}
+
+func applyFunctionWithNamed(fn object.Object, args []object.Object, named map[string]object.Object, depth int) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		return applyUserFunction(fn, args, named, depth+1)
+	case *object.Builtin:
+		if len(named) > 0 {
+			return newArgumentError("named arguments not supported by builtin functions")
+		}
+
+		if callback, ok := callbackBuiltins[fn]; ok {
+			return callback(depth, args...)
+		}
+		return fn.Fn(args...)
+	case *object.StructType:
+		return constructStruct(fn, args, named)
+	case *object.EnumVariant:
+		return constructEnumValue(fn, args, named)
+	default:
+		return newTypeError("not a function: %s", fn.Type())
+	}
+}
+
+// applyUserFunction calls function as the call at depth. A call in tail
+// position of its body is returned to here instead of being applied from
+// within the body, and applied in turn at the same depth, so tail calls run
+// in constant Go stack.
+func applyUserFunction(function *object.Function, args []object.Object, named map[string]object.Object, depth int) object.Object {
+	if max := MaxRecursionDepth(); depth > max {
+		return newError("maximum recursion depth %d exceeded", max)
+	}
+
+	var (
+		result    object.Object
+		tailCalls tailCallFrames
+	)
+
+	for {
+		extendedEnv, err := extendedFunctionEnv(function, args, named, depth)
+		if err != nil {
+			// A ? in a default value returns from the function being called
+			result = unwrapReturnValue(err)
+			break
+		}
+
+		if function.Generator {
+			return newGenerator(function.Body, extendedEnv)
+		}
+
+		evaluated := evalTail(function.Body, extendedEnv)
+
+		call, ok := evaluated.(*tailCall)
+		if !ok {
+			result = unwrapReturnValue(evaluated)
+			break
+		}
+
+		tailCalls.add(call.node)
+		function, args, named = call.function, call.args, call.named
+	}
+
+	if result == nil {
+		return NULL
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		err.Trace = tailCalls.appendTo(err.Trace)
+	}
+
+	return result
+}
+
+// extendedFunctionEnv binds the call arguments to the parameters of fn.
+// Positional arguments are bound first, then named ones, and any parameter
+// still missing falls back to its default value, evaluated in the new
+// environment so it can refer to earlier parameters.
+func extendedFunctionEnv(fn *object.Function, args []object.Object, named map[string]object.Object, depth int) (*object.Environment, object.Object) {
+	env := newScopeEnvironment(fn.Env, fn.Scope)
+	env.SetCallDepth(depth)
+
+	if len(args) > len(fn.Parameters) && fn.Rest == nil {
+		return nil, arityError(fn, len(args)+len(named))
+	}
+
+	for name := range named {
+		if !hasParameter(fn, name) {
+			return nil, newArgumentError("unknown named argument: %s", name)
+		}
+	}
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		var def ast.Expression
+		if dp, ok := param.(*ast.DefaultParameter); ok {
+			param, def = dp.Parameter, dp.Default
+		}
+
+		var name string
+		if ident, ok := param.(*ast.Identifier); ok {
+			name = ident.Value
+		}
+
+		namedArg, hasNamed := named[name]
+
+		var value object.Object
+
+		switch {
+		case paramIdx < len(args):
+			if hasNamed {
+				return nil, newArgumentError("argument %s given both positionally and by name", name)
+			}
+			value = args[paramIdx]
+		case hasNamed:
+			value = namedArg
+		case def != nil:
+			value = Eval(def, env)
+			if isUnwinding(value) {
+				return nil, value
+			}
+		default:
+			return nil, arityError(fn, len(args)+len(named))
+		}
+
+		if err := bindPattern(param, value, env); err != nil {
+			return nil, err
+		}
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		bind(fn.Rest, &object.Array{Elements: rest}, env)
+	}
+
+	return env, nil
+}
+
+// newScopeEnvironment returns the environment to evaluate a scope in, with a
+// frame for its variables when it has been resolved.
+func newScopeEnvironment(outer *object.Environment, scope *ast.Scope) *object.Environment {
+	if scope == nil {
+		return object.NewEnclosedEnvironment(outer)
+	}
+	return object.NewFrameEnvironment(outer, scope.Names)
+}
+
+// bind binds the variable declared by ident to val in env, in its slot when
+// it has been resolved, and returns val or the error binding it. A nil val
+// is bound as NULL, as an empty slot reads as unbound.
+func bind(ident *ast.Identifier, val object.Object, env *object.Environment) object.Object {
+	if val == nil {
+		val = NULL
+	}
+
+	if ident.Binding != nil {
+		return env.SetSlot(ident.Binding.Slot, val)
+	}
+	return env.Set(ident.Value, val)
+}
+
+func hasParameter(fn *object.Function, name string) bool {
+	for _, param := range fn.Parameters {
+		if dp, ok := param.(*ast.DefaultParameter); ok {
+			param = dp.Parameter
+		}
+
+		if ident, ok := param.(*ast.Identifier); ok && ident.Value == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// arityError reports a call of fn with got arguments. Arguments bind by
+// position, so every parameter up to the last without a default is required.
+func arityError(fn *object.Function, got int) *object.Error {
+	required := 0
+	for i, param := range fn.Parameters {
+		if _, ok := param.(*ast.DefaultParameter); !ok {
+			required = i + 1
+		}
+	}
+
+	var want string
+	switch {
+	case fn.Rest != nil:
+		want = fmt.Sprintf("at least %d", required)
+	case required == len(fn.Parameters):
+		want = fmt.Sprintf("%d", required)
+	default:
+		want = fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+	}
+
+	return newArgumentError("wrong number of arguments: want %s, got %d", want, got)
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -308,7 +1114,38 @@ func isTruthy(obj object.Object) bool {
 }
 
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.RUNTIME_ERROR}
+}
+
+func newTypeError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.TYPE_ERROR}
+}
+
+func newNameError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.NAME_ERROR}
+}
+
+func newImportError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.IMPORT_ERROR}
+}
+
+func newArgumentError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.ARGUMENT_ERROR}
+}
+
+func newPatternError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.PATTERN_ERROR}
+}
+
+// isUnwinding reports whether obj is an error or an in-flight return value,
+// either of which must be passed up instead of being used as a value.
+func isUnwinding(obj object.Object) bool {
+	if obj != nil {
+		rt := obj.Type()
+		return rt == object.ERROR_OBJ || rt == object.RETURN_VALUE_OBJ
+	}
+
+	return false
 }
 
 func isError(obj object.Object) bool {
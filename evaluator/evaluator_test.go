@@ -3,7 +3,9 @@ package evaluator
 import (
 	"arkham/lexer"
 	"arkham/object"
+	"arkham/optimizer"
 	"arkham/parser"
+	"arkham/resolver"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,7 +41,7 @@ func TestEvalIntegerExpression(t *testing.T) {
 }
 
 func TestEvalStringLiteral(t *testing.T) {
-	input := `"Hello world!"`
+	input := `"Hello World!"`
 
 	evaluated := testEval(input)
 
@@ -50,7 +52,7 @@ func TestEvalStringLiteral(t *testing.T) {
 }
 
 func TestStringConcatenation(t *testing.T) {
-	input := `Hello" + " " + "World!"`
+	input := `"Hello" + " " + "World!"`
 
 	evaluated := testEval(input)
 
@@ -60,6 +62,38 @@ func TestStringConcatenation(t *testing.T) {
 	assert.Equal(t, "Hello World!", str.Value)
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ada"; "Hello ${name}!"`, "Hello Ada!"},
+		{`let count = 2; "you have ${count + 1} items"`, "you have 3 items"},
+		{`"${1}${2}"`, "12"},
+		{`"list: ${[1, 2]}, ok: ${true}"`, "list: [1, 2], ok: true"},
+		{`let greet = fn(n) { "hi ${n}" }; "${greet("bob")}!"`, "hi bob!"},
+		{`"${ {"a": "nested"}["a"] }"`, "nested"},
+		{`"outer ${"inner ${1 + 1}"}"`, "outer inner 2"},
+		{`"escaped \${x} \"quote\""`, `escaped ${x} "quote"`},
+		{`"${map([1, 2], x => x * 2)}"`, "[2, 4]"},
+		{`let f = (x = "${1 + 1}") => "<${x}>"; f()`, "<2>"},
+		{`let f = fn() {}; "${f()}"`, "null"},
+		{`"[${match (1) { _ => {} }}]"`, "[null]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		assert.Equal(t, tt.expected, str.Value)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -92,6 +126,47 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`let a = "a"; let b = "a"; a == b`, true},
+		{`"a" != "b"`, true},
+		{`"1" == 1`, false},
+		{`1 != "1"`, true},
+		{"[1, 2, 3] == [1, 2, 3]", true},
+		{"[1, 2, 3] == [1, 2]", false},
+		{"[1, [2, [3]]] == [1, [2, [3]]]", true},
+		{"[1, [2, [3]]] == [1, [2, [4]]]", false},
+		{"[] == []", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{1: "a"} == {"1": "a"}`, false},
+		{"ok([1]) == ok([1])", true},
+		{"ok(1) == err(1)", false},
+		{"let f = fn(x) { x }; f == f", true},
+		{"fn(x) { x } == fn(x) { x }", false},
+		{"len == len", true},
+		{"len == first", false},
+		{"if (false) { 1 } == if (false) { 2 }", true},
+		{`try { error("x") } catch (a) { try { error("x") } catch (b) { a == b } }`, true},
+		{`try { error("x") } catch (a) { try { error("y") } catch (b) { a == b } }`, false},
+		{"struct P { v }; P([1]) == P([1])", true},
+		{"enum E { A(v) }; A({\"k\": [1]}) == A({\"k\": [1]})", true},
+		{"match ([1, 2]) { [1, 2] => true, _ => false }", true},
+		{`match ("ab") { "ab" => true, _ => false }`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.NotNil(t, evaluated, "input: %s", tt.input)
+		assert.Equal(t, nativeBoolToBooleanObject(tt.expected), evaluated, "input: %s", tt.input)
+	}
+}
+
 func TestEvalBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -123,6 +198,42 @@ func TestIfElesExpression(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else if (3 > 2) { 40 }", 40},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (1) { 1 => 10, 2 => 20 }", 10},
+		{"match (2) { 1 => 10, 2 => 20 }", 20},
+		{"match (3) { 1 => 10, 2 => 20 }", nil},
+		{"match (3) { 1 => 10, _ => 99 }", 99},
+		{"match (-1) { -1 => 10, _ => 99 }", 10},
+		{`match ("b") { "a" => 1, "b" => 2, }`, 2},
+		{"match (true) { false => 1, true => 2 }", 2},
+		{"match (1 == 1) { true => 1 }", 1},
+		{"match (5) { x => x * 2 }", 10},
+		{"match (5) { x if x > 10 => 1, x if x > 4 => 2, _ => 3 }", 2},
+		{"match (5) { x if x > 10 => 1, _ => 3 }", 3},
+		{"match (5) { n => { let y = n + 1; y * 2 } }", 12},
+		{"let x = 1; match (5) { x => x }; x", 1},
+		{"let f = fn(n) { match (n) { 0 => 1, _ => n * f(n - 1) } }; f(5)", 120},
 	}
 
 	for _, tt := range tests {
@@ -208,10 +319,118 @@ func TestErrorHandling(t *testing.T) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"match (foobar) { _ => 1 }",
+			"identifier not found: foobar",
+		},
+		{
+			"match (1) { x if x + true => 1 }",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
 		},
+		{
+			`{"name": "Monkey"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION",
+		},
+		{
+			`{fn(x) { x }: 1}`,
+			"unusable as hash key: FUNCTION",
+		},
+		{
+			"5[0]",
+			"index operator not supported: INTEGER",
+		},
+		{
+			"let [a, b] = [1];",
+			"cannot destructure ARRAY [1] with pattern [a, b]",
+		},
+		{
+			"let [a, b] = [1, 2, 3];",
+			"cannot destructure ARRAY [1, 2, 3] with pattern [a, b]",
+		},
+		{
+			"let [a, ...rest] = 5;",
+			"cannot destructure INTEGER 5 with pattern [a, ...rest]",
+		},
+		{
+			`let {name} = {"age": 1};`,
+			"cannot destructure HASH {age: 1} with pattern {name}",
+		},
+		{
+			`let {name} = [1];`,
+			"cannot destructure ARRAY [1] with pattern {name}",
+		},
+		{
+			"let f = fn([a, b]) { a }; f([1]);",
+			"cannot destructure ARRAY [1] with pattern [a, b]",
+		},
+		{
+			"let add = fn(x, y) { x + y }; add(1);",
+			"wrong number of arguments: want 2, got 1",
+		},
+		{
+			"let add = fn(x, y) { x + y }; add(1, 2, 3);",
+			"wrong number of arguments: want 2, got 3",
+		},
+		{
+			"let add = fn(x, y = 1) { x + y }; add();",
+			"wrong number of arguments: want 1 to 2, got 0",
+		},
+		{
+			"let f = fn(x = 1, y) { y }; f(2);",
+			"wrong number of arguments: want 2, got 1",
+		},
+		{
+			"let f = fn(x, y = 1, z, w = 2) { z }; f(1);",
+			"wrong number of arguments: want 3 to 4, got 1",
+		},
+		{
+			"let f = fn(x, ...rest) { x }; f();",
+			"wrong number of arguments: want at least 1, got 0",
+		},
+		{
+			"let f = fn(x, y) { x }; f(1, z: 2);",
+			"unknown named argument: z",
+		},
+		{
+			"let f = fn(x, y) { x }; f(1, x: 2);",
+			"argument x given both positionally and by name",
+		},
+		{
+			"let f = fn(x, y) { x }; f(x: 1, x: 2);",
+			"duplicate named argument: x",
+		},
+		{
+			"let f = fn(x) { x }; f(...5);",
+			"cannot spread INTEGER",
+		},
+		{
+			"let f = fn(x, y = x + true) { x }; f(1);",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"5(1)",
+			"not a function: INTEGER",
+		},
+		{
+			`"a ${missing} b"`,
+			"identifier not found: missing",
+		},
+		{
+			`throw "boom"; 5`,
+			"boom",
+		},
+		{
+			`try { 1 } catch (e) { throw e; }; error("x", 1)`,
+			"argument to `error` must be STRING, got INTEGER",
+		},
+		{
+			`try { throw 1; } catch (e) { 2 + e }`,
+			"type mismatch: INTEGER + ERROR_VALUE",
+		},
 	}
 
 	for _, tt := range tests {
@@ -229,6 +448,81 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestTryCatchFinally(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { 1 + true } catch (e) { 2 }", 2},
+		{"try { foobar } catch (e) { e[\"kind\"] }", "NameError"},
+		{"try { foobar } catch (e) { e[\"message\"] }", "identifier not found: foobar"},
+		{"try { 1 + true } catch (e) { e[\"kind\"] }", "TypeError"},
+		{"try { fn(x) { x }() } catch (e) { e[\"kind\"] }", "ArgumentError"},
+		{"try { let [a] = 1; } catch (e) { e[\"kind\"] }", "PatternError"},
+		{`try { throw "boom"; } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom"; } catch (e) { e["kind"] }`, "Error"},
+		{`try { throw {"code": 42}; } catch (e) { e["value"]["code"] }`, 42},
+		{`try { error("bad input") } catch (e) { e["message"] }`, "bad input"},
+		{`try { error("bad input", "ValueError") } catch (e) { e["kind"] }`, "ValueError"},
+		{`try { error("x") } catch (e) { e }`, "Error: x"},
+		{`try { error("x") } catch (e) { e["value"] }`, nil},
+		{`let f = fn() { try { return 1; } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1; } finally { return 2; } }; f()`, 2},
+		{`let f = fn() { try { error("x") } catch (e) { return 3; } finally { 4 } }; f()`, 3},
+		{`try { try { error("inner") } finally { 1 } } catch (e) { e["message"] }`, "inner"},
+		{`try { try { error("inner") } catch (e) { throw e; } } catch (e) { e["message"] }`, "inner"},
+		{`try { try { 1 + true } catch (e) { throw e; } } catch (e) { e["kind"] }`, "TypeError"},
+		{`try { try { error("a") } catch (e) { error("b") } } catch (e) { e["message"] }`, "b"},
+		{`try { 1 } finally { 2 }`, 1},
+		{`try { } catch (e) { 1 }`, nil},
+		{`let f = fn() { error("deep") }; let g = fn() { f() }; try { g() } catch (e) { len(e["trace"]) }`, 3},
+		{`let f = fn() { error("deep") };
+let g = fn() { f() };
+try { g() } catch (e) { e["trace"] }`, "[error at 1:21, f at 2:17, g at 3:8]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestFinallyRunsOnError(t *testing.T) {
+	input := `
+	let f = fn() {
+		try {
+			error("uncaught")
+		} finally {
+			1
+		}
+	};
+	f();
+	`
+
+	evaluated := testEval(input)
+
+	errObj, ok := evaluated.(*object.Error)
+	require.Truef(t, ok, "object is not Error. got=%T (%+v)", evaluated, evaluated)
+	assert.Equal(t, "uncaught", errObj.Message)
+	assert.Equal(t, object.USER_ERROR, errObj.Kind)
+
+	input = `let f = fn() { try { 1 } finally { 1 + true } }; f();`
+
+	errObj, ok = testEval(input).(*object.Error)
+	require.True(t, ok, "error in finally is not raised")
+	assert.Equal(t, "type mismatch: INTEGER + BOOLEAN", errObj.Message)
+}
+
 func TestLetStatement(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -278,6 +572,389 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestEmptyValues(t *testing.T) {
+	tests := []string{
+		"let f = fn() {}; f()",
+		"let f = fn() { let x = 1 }; f()",
+		"let f = fn(n) { if (n > 0) {} }; f(1)",
+		"let f = fn() { match (1) { _ => {} } }; f()",
+		"match (1) { _ => {} }",
+		"if (true) {}",
+		"let f = fn() {}; puts(f())",
+		"let f = fn() {}; unwrap(ok(f()))",
+		"let x = match (1) { _ => {} }; puts(x); x",
+		"let f = fn() {}; let c = chan(1); send(c, f()); recv(c)",
+	}
+
+	for _, input := range tests {
+		assert.Equal(t, NULL, testEval(input), "input: %s", input)
+	}
+
+	assert.Equal(t, "NULL", testEval("let f = fn() {}; type(f())").Inspect())
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(x, y = 10) { x + y }; f(1);", 11},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2);", 3},
+		{"let f = fn(x, y = x * 2) { x + y }; f(3);", 9},
+		{"let f = fn(x = 1, y = 2) { x * 10 + y }; f(y: 5);", 15},
+		{"let f = fn(x, y) { x - y }; f(y: 1, x: 5);", 4},
+		{"let f = fn(x, y = 2, z = 3) { x + y * z }; f(1, z: 10);", 21},
+		{"let f = fn(first, ...rest) { rest }; f(1, 2, 3);", "[2, 3]"},
+		{"let f = fn(first, ...rest) { rest }; f(1);", "[]"},
+		{"let f = fn(...all) { all }; f();", "[]"},
+		{"let f = fn(first, ...rest) { first }; f(1, 2, 3);", 1},
+		{"let add = fn(x, y) { x + y }; let xs = [1, 2]; add(...xs);", 3},
+		{"let add = fn(x, y, z) { x + y + z }; add(1, ...[2, 3]);", 6},
+		{"let add = fn(x, y, z) { x + y + z }; add(...[1], 2, ...[3]);", 6},
+		{"let f = fn(...all) { all }; f(...[1, 2], ...[3]);", "[1, 2, 3]"},
+		{"[0, ...[1, 2], 3]", "[0, 1, 2, 3]"},
+		{"let f = fn([a, b] = [1, 2]) { a + b }; f();", 3},
+		{"let f = fn(x = 1, y) { x + y }; f(y: 2);", 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestMemberAccessAndMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let p = {"name": "ada", "age": 36}; p.age`, 36},
+		{`let p = {"name": "ada"}; p.missing`, nil},
+		{`let p = {"inner": {"x": 5}}; p.inner.x`, 5},
+		{`let h = {"double": fn(x) { x * 2 }}; h.double(4)`, 8},
+		{`let h = {"a": 1}; h.len()`, 1},
+		{`try { error("boom") } catch (e) { e.message }`, "boom"},
+		{`"Hello".upper()`, "HELLO"},
+		{`"Hello".lower()`, "hello"},
+		{`"  hi  ".trim()`, "hi"},
+		{`"a,b,c".split(",")`, "[a, b, c]"},
+		{`"a,b,c".split(",").len()`, 3},
+		{`"team".contains("ea")`, "true"},
+		{`[1, 2, 3].join("-")`, "1-2-3"},
+		{`[1, 2, 3].map(fn(x) { x * 2 }).sum()`, 12},
+		{`let add = fn(a, b) { a + b }; 1.add(2)`, 3},
+		{`let len = fn(x) { 99 }; "abc".len()`, 99},
+		{`let upper = fn(x) { 99 }; "abc".upper()`, "ABC"},
+		{`1.nope()`, "undefined method nope for INTEGER"},
+		{`1.x`, "field access not supported: INTEGER.x"},
+		{`"a".split()`, "wrong number of arguments: want 1, got 0"},
+		{`"a".split(1)`, "argument to `split` must be STRING, got INTEGER"},
+		{`[1].join(1)`, "argument to `join` must be STRING, got INTEGER"},
+		{`let f = fn(x) { x.nope() }; try { f(1) } catch (e) { e.trace }`, "[f at 1:36]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"struct Point { x, y }; Point(1, 2)", "Point{x: 1, y: 2}"},
+		{"struct Point { x, y }; Point", "struct Point { x, y }"},
+		{"struct Point { x, y }; Point(y: 2, x: 1)", "Point{x: 1, y: 2}"},
+		{"struct Point { x, y }; Point(1, y: 2).y", 2},
+		{"struct P { x }; let f = fn() {}; P(f())", "P{x: null}"},
+		{"struct P { x }; let f = fn() {}; P(x: f())", "P{x: null}"},
+		{"struct Point { x, y }; let p = Point(3, 4); p.x * p.y", 12},
+		{"struct Point { x, y }; Point(1, 2) == Point(1, 2)", "true"},
+		{"struct Point { x, y }; Point(1, 2) == Point(2, 1)", "false"},
+		{"struct Point { x, y }; Point(1, 2) != Point(2, 1)", "true"},
+		{`struct Point { x, y }; Point("a", true) == Point("a", true)`, "true"},
+		{"struct Point { x, y }; struct Pair { x, y }; Point(1, 2) == Pair(1, 2)", "false"},
+		{"struct Box { v }; Box(Box(1)) == Box(Box(1))", "true"},
+		{"struct Point { x, y }; type(Point(1, 2))", "Point"},
+		{"type(1)", "INTEGER"},
+		{`type("a")`, "STRING"},
+		{"struct Point { x, y }; let norm = fn(p) { p.x + p.y }; Point(1, 2).norm()", 3},
+		{"struct Op { run }; Op(fn(x) { x + 1 }).run(1)", 2},
+		{`struct Point { x, y };
+let describe = fn(p) {
+	match (p) {
+		Point{x: 0, y: 0} => "origin",
+		Point{x: 0} => "on y axis",
+		Point{x, y} if x == y => "diagonal",
+		_ => "somewhere"
+	}
+};
+[describe(Point(0, 0)), describe(Point(0, 5)), describe(Point(2, 2)), describe(Point(1, 2)), describe(1)]`,
+			"[origin, on y axis, diagonal, somewhere, somewhere]"},
+		{"struct Point { x, y }; let f = fn(Point{x, y}) { x + y }; f(Point(4, 5))", 9},
+		{"struct Point { x, y }; Point(1)", "missing field y for Point"},
+		{"struct Point { x, y }; Point(1, 2, 3)", "wrong number of arguments: want 2, got 3"},
+		{"struct Point { x, y }; Point(1, 2, z: 3)", "unknown field z for Point"},
+		{"struct Point { x, y }; Point(1, 2, x: 3)", "field x for Point given twice"},
+		{"struct Point { x, y }; Point(1, 2).z", "Point has no field z"},
+		{"struct Point { x, y }; match (Point(1, 2)) { Point{z} => z }", "Point has no field z"},
+		{"match (1) { Nope{x} => x }", "identifier not found: Nope"},
+		{"struct Point { x, y }; Point(1, 2) + Point(1, 2)", "unknown operator: STRUCT_INSTANCE + STRUCT_INSTANCE"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestFieldValuesGivenNil(t *testing.T) {
+	values, err := fieldValues("P", []string{"x", "y"}, []object.Object{nil}, map[string]object.Object{"y": nil})
+	require.Nil(t, err)
+	assert.Equal(t, []object.Object{NULL, NULL}, values)
+
+	_, err = fieldValues("P", []string{"x", "y"}, []object.Object{nil}, nil)
+	require.NotNil(t, err)
+	assert.Equal(t, "missing field y for P", err.Message)
+}
+
+func TestEnums(t *testing.T) {
+	shape := "enum Shape { Circle(r), Rect(w, h), Empty }; "
+	area := shape + `let area = fn(s) {
+	match (s) {
+		Circle(r) => 3 * r * r,
+		Rect(w, h) => w * h,
+		Empty => 0
+	}
+}; `
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{shape + "Circle(2)", "Shape.Circle(2)"},
+		{shape + "Rect(2, 3)", "Shape.Rect(2, 3)"},
+		{shape + "Empty", "Shape.Empty"},
+		{shape + "Shape", "enum Shape { Circle(r), Rect(w, h), Empty }"},
+		{shape + "Circle", "Shape.Circle(r)"},
+		{shape + "Shape.Rect(h: 3, w: 2)", "Shape.Rect(2, 3)"},
+		{shape + "Shape.Empty", "Shape.Empty"},
+		{shape + "Rect(2, 3).h", 3},
+		{shape + "type(Empty)", "Shape"},
+		{shape + "Circle(1) == Circle(1)", "true"},
+		{shape + "Circle(1) == Circle(2)", "false"},
+		{shape + "Empty == Shape.Empty", "true"},
+		{shape + "Empty != Circle(1)", "true"},
+		{shape + "Rect(Circle(1), 2) == Rect(Circle(1), 2)", "true"},
+		{area + "[area(Circle(2)), area(Rect(2, 3)), area(Empty)]", "[12, 6, 0]"},
+		{area + "[Circle(1), Empty].map(area)", "[3, 0]"},
+		{shape + `match (Rect(2, 2)) { Rect(w, h) if w == h => "square", Rect(_, _) => "rect", _ => "other" }`, "square"},
+		{shape + `match (Rect(2, 5)) { Shape.Rect(2, h) => h, _ => 0 }`, 5},
+		{shape + `match (Empty) { Shape.Empty => 1, _ => 0 }`, 1},
+		{shape + `let s = Empty; match (Circle(1)) { s => s }`, "Shape.Circle(1)"},
+		{shape + "let f = fn(Rect(w, h)) { w + h }; f(Rect(1, 2))", 3},
+		{shape + "match (Circle(1)) { Circle(r) => r, Rect(w, h) => w }", "non-exhaustive match on Shape: missing Empty"},
+		{shape + "match (Circle(1)) { Circle(1) => 1, Rect(w, h) => w, Empty => 0 }", "non-exhaustive match on Shape: missing Circle"},
+		{shape + "match (Empty) { Circle(r) if r > 0 => r, Rect(w, h) => w, Empty => 0 }", "non-exhaustive match on Shape: missing Circle"},
+		{shape + "match (Circle(1)) { Circle(r) => r, Rect(w, h) => w, Empty => 0 }", 1},
+		{shape + "match (Circle(1)) { Circle(r, x) => r, _ => 0 }", "wrong number of fields in pattern Circle(r, x): want 1, got 2"},
+		{shape + "match (Circle(1)) { Shape.Square(r) => r, _ => 0 }", "Shape has no variant Square"},
+		{shape + "Circle(1, 2)", "wrong number of arguments: want 1, got 2"},
+		{shape + "Circle()", "missing field r for Circle"},
+		{shape + "Empty()", "not a function: ENUM_VALUE"},
+		{shape + "Shape.Square", "Shape has no variant Square"},
+		{shape + "Circle(1).w", "Circle has no field w"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestResultValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"ok(1)", "ok(1)"},
+		{`err("bad")`, "err(bad)"},
+		{"is_ok(ok(1))", "true"},
+		{`is_ok(err("bad"))`, "false"},
+		{`is_error(err("bad"))`, "true"},
+		{"is_error(ok(1))", "false"},
+		{"is_error(1)", "false"},
+		{`try { error("x") } catch (e) { is_error(e) }`, "true"},
+		{"unwrap(ok(5))", 5},
+		{`unwrap_or(err("bad"), 7)`, 7},
+		{"unwrap_or(ok(3), 7)", 3},
+		{`try { unwrap(err("bad")) } catch (e) { e["message"] }`, "bad"},
+		{"ok(2)? * 3", 6},
+		{`err("top")?`, "err(top)"},
+		{`let f = fn(x) { let y = x?; ok(y + 1) }; f(ok(1))`, "ok(2)"},
+		{`let f = fn(x) { let y = x?; ok(y + 1) }; f(err("no"))`, "err(no)"},
+		{`let f = fn(x) { if (true) { x?; } 99 }; f(err("no"))`, "err(no)"},
+		{`let f = fn(x, y = x?) { ok(y) }; f(err("no"))`, "err(no)"},
+		{`let check = fn(n) { if (n > 0) { ok(n) } else { err("not positive") } };
+let add = fn(a, b) { ok(check(a)? + check(b)?) };
+[add(1, 1), add(1, -1)]`, "[ok(2), err(not positive)]"},
+		{"1?", "operator ? not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestArrowFunctionsAndPipelines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let double = x => x * 2; double(4);", 8},
+		{"let add = (a, b) => a + b; add(1, 2);", 3},
+		{"let f = () => 7; f();", 7},
+		{"let adder = x => y => x + y; adder(1)(2);", 3},
+		{"let f = (x, y = 10) => { let z = x + y; z * 2 }; f(1);", 22},
+		{"[1, 2, 3] |> map(x => x * 2) |> sum()", 12},
+		{"[1, 2, 3, 4] |> filter(x => x > 2)", "[3, 4]"},
+		{"[1, 2, 3] |> reduce(0, (acc, x) => acc + x)", 6},
+		{"let inc = x => x + 1; 1 |> inc |> inc", 3},
+		{"let sub = (a, b) => a - b; 10 |> sub(3)", 7},
+		{"1 + 2 |> (x => x * 10)()", 30},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments: want 1, got 2"},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len({"a": 1})`, 1},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`map([1, 2], fn(x) { x + 1 })`, []int{2, 3}},
+		{`map([1, 2], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`map([1], 5)`, "not a function: INTEGER"},
+		{`filter([1, 2, 3], fn(x) { x != 2 })`, []int{1, 3}},
+		{`reduce([1, 2, 3], 10, fn(acc, x) { acc * x })`, 60},
+		{`sum([])`, 0},
+		{`sum([1, "a"])`, "argument to `sum` must contain only INTEGER, got STRING"},
+		{`len(x: 1)`, "named arguments not supported by builtin functions"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			assert.Equal(t, expected, errObj.Message)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			require.Len(t, array.Elements, len(expected), "wrong num of elements")
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 	let newAdder = fn(x) {
@@ -291,11 +968,245 @@ func TestClosures(t *testing.T) {
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	require.Truef(t, ok, "object is not Array. got=%T (%+v)", evaluated, evaluated)
+
+	require.Len(t, result.Elements, 3, "array has wrong num of elements")
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	require.Truef(t, ok, "Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+
+	expected := []struct {
+		key   object.HashKey
+		value int64
+	}{
+		{(&object.String{Value: "one"}).HashKey(), 1},
+		{(&object.String{Value: "two"}).HashKey(), 2},
+		{(&object.String{Value: "three"}).HashKey(), 3},
+		{(&object.Integer{Value: 4}).HashKey(), 4},
+		{TRUE.HashKey(), 5},
+		{FALSE.HashKey(), 6},
+	}
+
+	require.Len(t, result.Pairs, len(expected), "Hash has wrong num of pairs")
+	require.Len(t, result.Keys, len(expected), "Hash has wrong num of keys")
+
+	for i, tt := range expected {
+		assert.Equal(t, tt.key, result.Keys[i], "keys not in insertion order")
+
+		pair, ok := result.Pairs[tt.key]
+		require.True(t, ok, "no pair for given key in Pairs")
+
+		testIntegerObject(t, pair.Value, tt.value)
+	}
+
+	assert.Equal(t, "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}", result.Inspect())
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestDestructuringLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b;", 3},
+		{"let [a, _, c] = [1, 2, 3]; a + c;", 4},
+		{"let [a, ...rest] = [1, 2, 3]; rest;", "[2, 3]"},
+		{"let [a, b, ...rest] = [1, 2]; rest;", "[]"},
+		{"let [...all] = [1, 2]; all;", "[1, 2]"},
+		{"let [a, [b, c]] = [1, [2, 3]]; a + b + c;", 6},
+		{"let [1, x] = [1, 5]; x;", 5},
+		{`let {name, age: years} = {"name": "arkham", "age": 7}; name;`, "arkham"},
+		{`let {name, age: years} = {"name": "arkham", "age": 7}; years;`, 7},
+		{`let {pos: [x, y]} = {"pos": [3, 4]}; x * y;`, 12},
+		{`let {"first name": first, 1: one} = {"first name": "a", 1: "b"}; first + one;`, "ab"},
+		{`let [{id}, ...others] = [{"id": 1}, {"id": 2}]; id;`, 1},
+		{"let f = fn([a, b], c) { a + b + c }; f([1, 2], 3);", 6},
+		{`let f = fn({x, y}) { x * y }; f({"x": 2, "y": 5});`, 10},
+		{"match ([1, 2]) { [a] => a, [a, b] => a + b, _ => 0 }", 3},
+		{"match ([1, 2, 3]) { [1, ...rest] => rest, _ => 0 }", "[2, 3]"},
+		{`match ({"kind": "circle", "r": 2}) { {kind: "square", side} => side, {kind: "circle", r} => r * 3 }`, 6},
+		{`match (5) { [a] => a, {a} => a }`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestResolvedScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// A closure sees a variable bound after it
+		{"let f = fn() { let g = fn() { x }; let x = 2; g() }; f()", 2},
+		// A variable read before it is bound in its scope is the outer one
+		{"let x = 1; let f = fn() { let y = x; let x = 5; [y, x] }; f()", "[1, 5]"},
+		{"let f = fn(n) { if (n > 0) { let y = n }; y }; f(1)", 1},
+		{"let f = fn(n) { if (n > 0) { let y = n }; y }; f(0)", "identifier not found: y"},
+		{"let f = fn(x) { let x = x + 1; x }; f(1)", 2},
+		{"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", 6},
+		{"let f = fn() { let double = fn(x) { x * 2 }; 4.double() }; f()", 8},
+		{"let f = fn() { enum E { A, B }; match (B) { A => 1, B => 2 } }; f()", 2},
+		{"let f = fn(xs) { let total = 0; for (x in xs) { let total = total + x }; total }; f([1, 2])", 0},
+		{"let f = fn() { try { throw 1 } catch (e) { let v = e.value; v + 1 } }; f()", 2},
+		// A variable bound to the result of an empty function is bound
+		{"let g = fn() { let x = fn() {}(); x }; g()", "null"},
+		{"let g = fn(a) { a }; g(fn() {}())", "null"},
+		{"let f = fn() {}; fn* gen() { yield f() }; let g = fn() { for (x in gen()) { return [x] } }; g()", "[null]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+const benchmarkInput = `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let sum = fn(xs) {
+	let step = fn(i, acc) { if (i == len(xs)) { acc } else { step(i + 1, acc + xs[i]) } };
+	step(0, 0)
+};
+fib(15) + sum([1, 2, 3, 4, 5, 6, 7, 8, 9, 10])`
+
+// BenchmarkEval evaluates a program looking up every variable by name, as
+// without the resolver.
+func BenchmarkEval(b *testing.B) {
+	program := parser.New(lexer.New(benchmarkInput)).ParseProgram()
+
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
+// BenchmarkEvalResolved evaluates the same program resolved, looking up
+// variables in frames.
+func BenchmarkEvalResolved(b *testing.B) {
+	program := parser.New(lexer.New(benchmarkInput)).ParseProgram()
+	resolver.Resolve(program, Defined(object.NewEnvironment()))
+
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
 func testEval(input string) object.Object {
+	return testEvalEnv(input, object.NewEnvironment())
+}
+
+// optimizePasses are the optimizer passes testEvalEnv applies before
+// evaluating, none but while TestOptimizedPrograms runs.
+var optimizePasses optimizer.Pass
+
+func testEvalEnv(input string, env *object.Environment) object.Object {
 	lexer := lexer.New(input)
 	parser := parser.New(lexer)
 	program := parser.ParseProgram()
-	env := object.NewEnvironment()
+
+	macros := object.NewEnvironment()
+	DefineMacros(program, macros)
+	if err := ExpandMacros(program, macros); err != nil {
+		return err
+	}
+
+	optimizer.Optimize(program, optimizePasses)
+	resolver.Resolve(program, Defined(env))
 
 	return Eval(program, env)
 }
@@ -0,0 +1,264 @@
+package evaluator
+
+import (
+	"arkham/ast"
+	"arkham/object"
+	"runtime"
+	"sync"
+)
+
+// coroutine runs the body of a generator function on its own goroutine. The
+// goroutine calling next and the body hand control back and forth over
+// channels, so only one of them runs at a time. The goroutine is started by
+// the first call to next and exits when the body finishes or the generator
+// is closed.
+type coroutine struct {
+	body *ast.BlockStatement
+	env  *object.Environment
+
+	resume chan object.Object // values sent by next to the suspended body
+	yield  chan step          // values yielded by the body, then its result
+	cancel chan struct{}      // closed to unwind the suspended body
+	exited chan struct{}      // closed when the goroutine exits
+
+	mu       sync.Mutex // held while the body runs
+	started  bool
+	finished bool
+}
+
+type step struct {
+	value object.Object
+	done  bool
+}
+
+// newGenerator returns a generator evaluating body in env. A suspended body
+// keeps its goroutine alive, so the generator is closed once it becomes
+// unreachable. A generator reachable from its own body, e.g. bound in the
+// scope of its function, is only released by running it to the end or
+// closing it, which for-in does when it stops early.
+func newGenerator(body *ast.BlockStatement, env *object.Environment) *object.Generator {
+	co := &coroutine{
+		body:   body,
+		env:    env,
+		resume: make(chan object.Object),
+		yield:  make(chan step),
+		cancel: make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	env.SetYielder(co)
+
+	gen := &object.Generator{Coroutine: co}
+	runtime.SetFinalizer(gen, func(gen *object.Generator) {
+		// The body may run finally blocks while unwinding, which must not
+		// hold up the finalizer goroutine
+		go gen.Coroutine.Close()
+	})
+
+	return gen
+}
+
+func (co *coroutine) Resume(sent object.Object) (object.Object, bool) {
+	if !co.mu.TryLock() {
+		return newError("generator already running"), false
+	}
+	defer co.mu.Unlock()
+
+	if co.finished {
+		return NULL, true
+	}
+
+	if co.started {
+		co.resume <- sent
+	} else {
+		co.started = true
+		go co.run()
+	}
+
+	s := <-co.yield
+	co.finished = s.done
+
+	return s.value, s.done
+}
+
+func (co *coroutine) Close() *object.Error {
+	if !co.mu.TryLock() {
+		return newError("generator already running")
+	}
+	defer co.mu.Unlock()
+
+	if co.finished {
+		return nil
+	}
+	co.finished = true
+
+	close(co.cancel)
+	if co.started {
+		<-co.exited
+	}
+
+	return nil
+}
+
+// Yield runs on the goroutine of the body. Once the generator is closed it
+// returns a return value instead, which unwinds the body through its finally
+// blocks.
+func (co *coroutine) Yield(value object.Object) object.Object {
+	select {
+	case co.yield <- step{value: value}:
+	case <-co.cancel:
+		return &object.ReturnValue{Value: NULL}
+	}
+
+	select {
+	case sent := <-co.resume:
+		return sent
+	case <-co.cancel:
+		return &object.ReturnValue{Value: NULL}
+	}
+}
+
+func (co *coroutine) run() {
+	defer close(co.exited)
+
+	result := unwrapReturnValue(Eval(co.body, co.env))
+	if result == nil {
+		result = NULL
+	}
+
+	select {
+	case co.yield <- step{value: result, done: true}:
+	case <-co.cancel:
+	}
+}
+
+func evalYieldExpression(node *ast.YieldExpression, env *object.Environment) object.Object {
+	yielder := env.Yielder()
+	if yielder == nil {
+		return newError("yield outside of a generator function")
+	}
+
+	var value object.Object = NULL
+	if node.Value != nil {
+		value = Eval(node.Value, env)
+		if isUnwinding(value) {
+			return value
+		}
+	}
+
+	return yielder.Yield(value)
+}
+
+// evalForExpression evaluates the body of a for-in loop for each element of
+// an array, each value of a generator or each value received from a channel
+// until it is closed, in a new environment each time. A generator is closed
+// when the loop stops early.
+func evalForExpression(node *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isUnwinding(iterable) {
+		return iterable
+	}
+
+	body := func(element object.Object) object.Object {
+		bodyEnv := newScopeEnvironment(env, node.Scope)
+		if err := bindPattern(node.Pattern, element, bodyEnv); err != nil {
+			return err
+		}
+
+		return Eval(node.Body, bodyEnv)
+	}
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for _, element := range iterable.Elements {
+			if result := body(element); isUnwinding(result) {
+				return result
+			}
+		}
+	case *object.Generator:
+		defer iterable.Coroutine.Close()
+
+		for {
+			value, done := iterable.Coroutine.Resume(NULL)
+			if isError(value) {
+				return value
+			}
+
+			if done {
+				break
+			}
+
+			if result := body(value); isUnwinding(result) {
+				return result
+			}
+		}
+	case *object.Channel:
+		for element := range iterable.Ch {
+			if result := body(element); isUnwinding(result) {
+				return result
+			}
+		}
+	default:
+		return newTypeError("cannot iterate over %s", iterable.Type())
+	}
+
+	return NULL
+}
+
+// methodNext resumes a generator, optionally sending it a value, and returns
+// a hash holding the value it yielded, or its result once done.
+func methodNext(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newArgumentError("wrong number of arguments: want 0 to 1, got %d", len(args)-1)
+	}
+
+	gen, ok := args[0].(*object.Generator)
+	if !ok {
+		return newTypeError("receiver of `next` must be GENERATOR, got %s", args[0].Type())
+	}
+
+	var sent object.Object = NULL
+	if len(args) == 2 {
+		sent = args[1]
+	}
+
+	value, done := gen.Coroutine.Resume(sent)
+	if isError(value) {
+		return value
+	}
+
+	result := object.NewHash()
+	result.Set(&object.String{Value: "value"}, value)
+	result.Set(&object.String{Value: "done"}, nativeBoolToBooleanObject(done))
+
+	return result
+}
+
+// builtinNext resumes a generator like its next method.
+func builtinNext(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newArgumentError("wrong number of arguments: want 1 to 2, got %d", len(args))
+	}
+
+	if args[0].Type() != object.GENERATOR_OBJ {
+		return newTypeError("argument to `next` must be GENERATOR, got %s", args[0].Type())
+	}
+
+	return methodNext(args...)
+}
+
+func methodClose(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newArgumentError("wrong number of arguments: want 0, got %d", len(args)-1)
+	}
+
+	gen, ok := args[0].(*object.Generator)
+	if !ok {
+		return newTypeError("receiver of `close` must be GENERATOR, got %s", args[0].Type())
+	}
+
+	if err := gen.Coroutine.Close(); err != nil {
+		return err
+	}
+
+	return NULL
+}
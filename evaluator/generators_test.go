@@ -0,0 +1,147 @@
+package evaluator
+
+import (
+	"arkham/object"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn* gen() { yield 1; yield 2; }; let g = gen(); [g.next(), g.next(), g.next()]",
+			"[{value: 1, done: false}, {value: 2, done: false}, {value: null, done: true}]"},
+		{"let g = fn*() { yield 1; 42 }(); g.next(); g.next()", "{value: 42, done: true}"},
+		{"let g = fn*() { yield 1; return 7; yield 2 }(); g.next(); g.next()", "{value: 7, done: true}"},
+		{"let g = fn*() { yield 1 }(); g.next(); g.next(); g.next()", "{value: null, done: true}"},
+		{"let g = fn*() { yield; }(); g.next()", "{value: null, done: false}"},
+		{"fn* gen() {}; gen()", "<generator>"},
+		{"fn* gen() { yield 1 }; gen", "fn*() {\n(yield 1)\n}"},
+		{"let g = fn*() { let a = yield 1; yield a * 2 }(); g.next(5); g.next(10).value", 20},
+		{"let g = fn*(x, y = x + 1) { yield x; yield y }(1); [g.next().value, g.next().value]", "[1, 2]"},
+		{"fn* down(n) { if (n > 0) { yield n; for (x in down(n - 1)) { yield x } } }; let g = down(3); [g.next().value, g.next().value, g.next().value, g.next().done]",
+			"[3, 2, 1, true]"},
+		{"let g = fn*() { yield 1 }(); g == g", "true"},
+		{"fn* gen() { yield 1 }; gen() == gen()", "false"},
+		{"let log = fn*() { yield 1; throw \"boom\" }(); log.next(); log.next()", "boom"},
+		{"let g = fn*() { yield 1; throw \"boom\" }(); g.next(); try { g.next() } catch (e) { 0 }; g.next()", "{value: null, done: true}"},
+		{"fn* gen(x) { yield x }; gen()", "wrong number of arguments: want 1, got 0"},
+		{"let g = fn*() { g.next() }(); g.next()", "generator already running"},
+		{"let g = fn*() { yield 1 }(); g.next(1, 2)", "wrong number of arguments: want 0 to 1, got 2"},
+		{"let g = fn*() { let a = yield 1; yield a * 2 }(); next(g); [next(g, 10).value, next(g).done]", "[20, true]"},
+		{"let g = fn*() { yield 1 }(); g.close(); next(g)", "{value: null, done: true}"},
+		{"next(1)", "argument to `next` must be GENERATOR, got INTEGER"},
+		{"next()", "wrong number of arguments: want 1 to 2, got 0"},
+		{"1.next()", "argument to `next` must be GENERATOR, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"for (x in [1, 2]) { x }", nil},
+		{"for (x in []) { x }", nil},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10 } } }; f()", 20},
+		{"fn* nat() { yield 1; yield 2; yield 3 }; let f = fn() { for (x in nat()) { if (x > 1) { return x } } }; f()", 2},
+		{"let f = fn() { for ([a, b] in [[1, 2], [3, 4]]) { if (a == 3) { return a + b } } }; f()", 7},
+		{"let f = fn() { for ({id} in [{\"id\": 5}]) { return id } }; f()", 5},
+		{"fn* pairs() { yield [1, 2]; yield [3, 4] }; let f = fn() { for ([a, b] in pairs()) { if (a == 3) { return b } } }; f()", 4},
+		{"let f = fn() { for (x in [1, 2]) { return fn() { x } } }; f()()", 1},
+		{"for (x in [1]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"for ([a] in [1]) { a }", "cannot destructure INTEGER 1 with pattern [a]"},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"fn* bad() { yield 1; throw \"boom\" }; for (x in bad()) { x }", "boom"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestGeneratorCloseRunsFinally(t *testing.T) {
+	input := `
+	fn* gen() {
+		try { yield 1; yield 2; } finally { cleanup() }
+	}
+	let g = gen();
+	g.next();
+	[g.close(), g.next(), g.close()]`
+
+	cleanups := 0
+	env := object.NewEnvironment()
+	env.Set("cleanup", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		cleanups++
+		return NULL
+	}})
+
+	evaluated := testEvalEnv(input, env)
+
+	require.NotNil(t, evaluated)
+	assert.Equal(t, "[null, {value: null, done: true}, null]", evaluated.Inspect())
+	assert.Equal(t, 1, cleanups)
+}
+
+func TestGeneratorsDoNotLeakGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	tests := []string{
+		// Closed by the for loop returning early
+		"fn* nat() { yield 1; yield 2 }; let f = fn() { for (x in nat()) { return x } }; f()",
+		// Closed explicitly
+		"fn* nat() { yield 1; yield 2 }; let g = nat(); g.next(); g.close()",
+		// Never started
+		"fn* nat() { yield 1 }; nat()",
+		// Abandoned while suspended, closed once unreachable
+		"fn* nat() { yield 1; yield 2 }; let f = fn() { let g = nat(); g.next() }; f()",
+	}
+
+	for _, input := range tests {
+		testEval(input)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
@@ -0,0 +1,205 @@
+package evaluator
+
+import (
+	"arkham/ast"
+	"arkham/object"
+	"arkham/token"
+	"strconv"
+)
+
+// quote evaluates the call quote(x) to the source of x, with each
+// unquote(y) in x replaced by the source of the value of y.
+func quote(node *ast.CallExpression, env *object.Environment) object.Object {
+	if len(node.Arguments) != 1 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(node.Arguments))
+	}
+
+	var err object.Object
+	quoted := ast.Modify(ast.Clone(node.Arguments[0]), func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !call.IsCallTo("unquote") || err != nil {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			err = newArgumentError("wrong number of arguments: want 1, got %d", len(call.Arguments))
+			return node
+		}
+
+		value := Eval(call.Arguments[0], env)
+		if isUnwinding(value) {
+			err = value
+			return node
+		}
+
+		source, sourceErr := toSource(value, call.Token)
+		if sourceErr != nil {
+			err = sourceErr
+			return node
+		}
+		return source
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: quoted}
+}
+
+// toSource returns an expression evaluating to obj, positioned at tok, or
+// an error when obj or a value within it has no literal form.
+func toSource(obj object.Object, tok token.Token) (ast.Expression, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		literal := strconv.FormatInt(obj.Value, 10)
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal, Line: tok.Line, Column: tok.Column}, Value: obj.Value}, nil
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value, Line: tok.Line, Column: tok.Column}, Value: obj.Value}, nil
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Line: tok.Line, Column: tok.Column}
+		if obj.Value {
+			t.Type, t.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, nil
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "[", Line: tok.Line, Column: tok.Column}}
+		for _, element := range obj.Elements {
+			source, err := toSource(element, tok)
+			if err != nil {
+				return nil, err
+			}
+			array.Elements = append(array.Elements, source)
+		}
+		return array, nil
+	case *object.Hash:
+		hash := &ast.HashLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{", Line: tok.Line, Column: tok.Column}}
+		for _, key := range obj.Keys {
+			pair := obj.Pairs[key]
+			k, err := toSource(pair.Key, tok)
+			if err != nil {
+				return nil, err
+			}
+			v, err := toSource(pair.Value, tok)
+			if err != nil {
+				return nil, err
+			}
+			hash.Pairs = append(hash.Pairs, ast.HashPair{Key: k, Value: v})
+		}
+		return hash, nil
+	case *object.Quote:
+		// The same source may be spliced in several places
+		return ast.Clone(obj.Node).(ast.Expression), nil
+	default:
+		return nil, newTypeError("cannot unquote %s", obj.Type())
+	}
+}
+
+// DefineMacros removes the macro definitions, let statements binding a
+// macro literal, from the top level of program and binds the macros in env
+// for ExpandMacros.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	stmts := []ast.Statement{}
+
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Name != nil {
+			if lit, ok := let.Value.(*ast.MacroLiteral); ok {
+				env.Set(let.Name.Value, &object.Macro{Parameters: lit.Parameters, Body: lit.Body, Env: env})
+				continue
+			}
+		}
+
+		stmts = append(stmts, stmt)
+	}
+
+	program.Statements = stmts
+}
+
+// ExpandMacros replaces each call in program of a macro bound in env by the
+// source the macro returns for the source of the arguments. Macro calls in
+// that source are expanded in turn. ExpandMacros returns the first error a
+// macro raises, or nil.
+func ExpandMacros(program *ast.Program, env *object.Environment) *object.Error {
+	e := &macroExpander{env: env}
+	e.expand(program)
+	return e.err
+}
+
+type macroExpander struct {
+	env   *object.Environment
+	err   *object.Error
+	depth int // of macro calls in the expansions being expanded
+}
+
+func (e *macroExpander) expand(node ast.Node) ast.Node {
+	return ast.Modify(node, e.expandCall)
+}
+
+func (e *macroExpander) expandCall(node ast.Node) ast.Node {
+	call, ok := node.(*ast.CallExpression)
+	if !ok || e.err != nil {
+		return node
+	}
+
+	macro, ok := calledMacro(call, e.env)
+	if !ok {
+		return node
+	}
+
+	if len(call.Arguments) != len(macro.Parameters) {
+		e.fail(call, newArgumentError("wrong number of arguments: want %d, got %d", len(macro.Parameters), len(call.Arguments)))
+		return node
+	}
+
+	if max := MaxRecursionDepth(); e.depth >= max {
+		e.fail(call, newError("maximum recursion depth %d exceeded", max))
+		return node
+	}
+
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+
+	result := unwrapReturnValue(Eval(macro.Body, env))
+	if err, ok := result.(*object.Error); ok {
+		e.fail(call, err)
+		return node
+	}
+
+	quoted, ok := result.(*object.Quote)
+	if !ok {
+		if result == nil {
+			result = NULL
+		}
+		e.fail(call, newTypeError("macro %s returned %s, not a quote", call.Function, result.Type()))
+		return node
+	}
+
+	e.depth++
+	defer func() { e.depth-- }()
+
+	return e.expand(quoted.Node)
+}
+
+// fail records err raised expanding call, adding the call to its trace.
+func (e *macroExpander) fail(call *ast.CallExpression, err *object.Error) {
+	err.Trace = append(err.Trace, callFrame(call))
+	e.err = err
+}
+
+// calledMacro returns the macro bound in env that call calls by name.
+func calledMacro(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
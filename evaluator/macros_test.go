@@ -0,0 +1,235 @@
+package evaluator
+
+import (
+	"arkham/ast"
+	"arkham/lexer"
+	"arkham/object"
+	"arkham/parser"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`quote(5)`, "5"},
+		{`quote(5 + 8)`, "(5 + 8)"},
+		{`quote(foobar)`, "foobar"},
+		{`quote(foobar + barfoo)`, "(foobar + barfoo)"},
+		{`quote(1, 2)`, "wrong number of arguments: want 1, got 2"},
+		{`let q = quote(1); q == q`, true},
+		{`let f = fn() { quote(1) }; f()`, "1"},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, tt.input, tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`quote(unquote(4))`, "4"},
+		{`quote(unquote(4 + 4))`, "8"},
+		{`quote(8 + unquote(4 + 4))`, "(8 + 8)"},
+		{`quote(unquote(4 + 4) + 8)`, "(8 + 8)"},
+		{`let foobar = 8; quote(foobar)`, "foobar"},
+		{`let foobar = 8; quote(unquote(foobar))`, "8"},
+		{`quote(unquote(true))`, "true"},
+		{`quote(unquote(true == false))`, "false"},
+		{`quote(unquote("a" + "b") + 1)`, "(\"ab\" + 1)"},
+		{`quote(unquote(quote(4 + 4)))`, "(4 + 4)"},
+		{`let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))`, "(8 + (4 + 4))"},
+		{`let f = fn(x) { quote(x + unquote(x)) }; f(2)`, "(x + 2)"},
+		// The quoted source is copied, not spliced into
+		{`let f = fn(x) { quote(unquote(x)) }; [f(1), f(2)]`, "[QUOTE(1), QUOTE(2)]"},
+		{`quote(unquote([1, 2 + 3]))`, "[1, 5]"},
+		{`quote(unquote([]))`, "[]"},
+		{`let x = 2; quote(unquote({"a": [true], x: quote(x)}))`, `{"a": [true], 2: x}`},
+		{`quote(unquote([fn() { 1 }]))`, "cannot unquote FUNCTION"},
+		{`quote(unquote({"a": if (false) { 1 }}))`, "cannot unquote NULL"},
+		{`quote(unquote(1, 2))`, "wrong number of arguments: want 1, got 2"},
+		{`quote(unquote(1 + true))`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, tt.input, tt.expected)
+	}
+}
+
+func testQuoteObject(t *testing.T, input string, expected interface{}) {
+	evaluated := testEval(input)
+	require.NotNil(t, evaluated, "input: %s", input)
+
+	switch expected := expected.(type) {
+	case bool:
+		testBooleanObject(t, evaluated, expected)
+	case string:
+		switch obj := evaluated.(type) {
+		case *object.Error:
+			assert.Equal(t, expected, obj.Message, "input: %s", input)
+		case *object.Quote:
+			assert.Equal(t, expected, obj.Node.String(), "input: %s", input)
+		default:
+			assert.Equal(t, expected, obj.Inspect(), "input: %s", input)
+		}
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	require.Len(t, program.Statements, 2)
+
+	_, ok := env.Get("number")
+	assert.False(t, ok, "number should not be defined")
+	_, ok = env.Get("function")
+	assert.False(t, ok, "function should not be defined")
+
+	obj, ok := env.Get("mymacro")
+	require.True(t, ok, "macro not in environment")
+	macro, ok := obj.(*object.Macro)
+	require.True(t, ok, "object is not Macro. got=%T (%+v)", obj, obj)
+
+	require.Len(t, macro.Parameters, 2)
+	assert.Equal(t, "x", macro.Parameters[0].String())
+	assert.Equal(t, "y", macro.Parameters[1].String())
+	assert.Equal(t, "(x + y)", macro.Body.String())
+	assert.Equal(t, "macro(x, y) {\n(x + y)\n}", macro.Inspect())
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) { unquote(consequence); } else { unquote(alternative); });
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`let twice = macro(x) { quote(unquote(x) + unquote(x)) };
+			let quadruple = macro(x) { quote(twice(unquote(x)) * 2) };
+			quadruple(y)`,
+			`(y + y) * 2`,
+		},
+		{
+			`let m = macro(x) { quote(unquote(x) + 1) }; let f = fn(y) { m(y) * m(m(2)) }`,
+			`let f = fn(y) { (y + 1) * ((2 + 1) + 1) }`,
+		},
+		{
+			`let m = macro(x) { x }; m(1 + 2); m`,
+			`1 + 2; m`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		require.Nil(t, ExpandMacros(program, env), "input: %s", tt.input)
+
+		assert.Equal(t, expected.String(), program.String(), "input: %s", tt.input)
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedTrace []string
+	}{
+		{`let m = macro(x) { x }; m()`, "wrong number of arguments: want 1, got 0", []string{"m at 1:26"}},
+		{`let m = macro() { 1 }; m()`, "macro m returned INTEGER, not a quote", []string{"m at 1:25"}},
+		{`let m = macro() { }; m()`, "macro m returned NULL, not a quote", []string{"m at 1:23"}},
+		{`let m = macro() { 1 + true }; m()`, "type mismatch: INTEGER + BOOLEAN", []string{"m at 1:32"}},
+		{`let m = macro() { quote(unquote(1 + true)) }; m()`, "type mismatch: INTEGER + BOOLEAN", []string{"m at 1:48"}},
+		{`let m = macro() { quote(m()) }; m()`, "maximum recursion depth 100 exceeded", nil},
+	}
+
+	previous := SetMaxRecursionDepth(100)
+	defer SetMaxRecursionDepth(previous)
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		err := ExpandMacros(program, env)
+
+		require.NotNil(t, err, "input: %s", tt.input)
+		assert.Equal(t, tt.expected, err.Message, "input: %s", tt.input)
+		if tt.expectedTrace != nil {
+			assert.Equal(t, tt.expectedTrace, err.Trace, "input: %s", tt.input)
+		}
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let unless = macro(cond, then, otherwise) {
+			quote(if (!(unquote(cond))) { unquote(then) } else { unquote(otherwise) })
+		};
+		unless(1 > 2, "smaller", "greater")`, "smaller"},
+		// Arguments are evaluated only where the expansion evaluates them
+		{`let unless = macro(cond, then) { quote(if (!(unquote(cond))) { unquote(then) }) };
+		let x = 1;
+		unless(true, x + true);
+		x`, 1},
+		{`let square = macro(x) { quote(unquote(x) * unquote(x)) }; let f = fn(n) { square(n + 1) }; f(2)`, 9},
+		{`let table = macro() { quote(unquote({"a": [1, 2]})["a"][1]) }; table()`, 2},
+		{`let f = fn() { macro() { 1 } }; f()`, "a macro must be defined by a top-level let statement"},
+		{`let m = macro() { 1 }; m()`, "macro m returned INTEGER, not a quote"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
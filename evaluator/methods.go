@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"arkham/object"
+	"strings"
+)
+
+// methods holds the methods of each type, called as x.name(...) with the
+// receiver x as their first argument. A method takes precedence over a
+// function of the same name.
+var methods = map[object.ObjectType]map[string]*object.Builtin{
+	object.STRING_OBJ: {
+		"upper":    {Fn: methodUpper},
+		"lower":    {Fn: methodLower},
+		"trim":     {Fn: methodTrim},
+		"contains": {Fn: methodContains},
+		"split":    {Fn: methodSplit},
+	},
+	object.ARRAY_OBJ: {
+		"join": {Fn: methodJoin},
+	},
+	object.GENERATOR_OBJ: {
+		"next":  {Fn: methodNext},
+		"close": {Fn: methodClose},
+	},
+}
+
+func methodUpper(args ...object.Object) object.Object {
+	strs, err := stringArguments("upper", 0, args)
+	if err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.ToUpper(strs[0])}
+}
+
+func methodLower(args ...object.Object) object.Object {
+	strs, err := stringArguments("lower", 0, args)
+	if err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.ToLower(strs[0])}
+}
+
+func methodTrim(args ...object.Object) object.Object {
+	strs, err := stringArguments("trim", 0, args)
+	if err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.TrimSpace(strs[0])}
+}
+
+func methodContains(args ...object.Object) object.Object {
+	strs, err := stringArguments("contains", 1, args)
+	if err != nil {
+		return err
+	}
+
+	return nativeBoolToBooleanObject(strings.Contains(strs[0], strs[1]))
+}
+
+func methodSplit(args ...object.Object) object.Object {
+	strs, err := stringArguments("split", 1, args)
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, part := range strings.Split(strs[0], strs[1]) {
+		elements = append(elements, &object.String{Value: part})
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func methodJoin(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newArgumentError("wrong number of arguments: want 1, got %d", len(args)-1)
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newTypeError("receiver of `join` must be ARRAY, got %s", args[0].Type())
+	}
+
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return newTypeError("argument to `join` must be STRING, got %s", args[1].Type())
+	}
+
+	parts := make([]string, len(arr.Elements))
+	for i, element := range arr.Elements {
+		parts[i] = element.Inspect()
+	}
+
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+// stringArguments checks that the string method name got a string receiver
+// followed by want string arguments, and returns their values.
+func stringArguments(name string, want int, args []object.Object) ([]string, *object.Error) {
+	if len(args) != want+1 {
+		return nil, newArgumentError("wrong number of arguments: want %d, got %d", want, len(args)-1)
+	}
+
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newTypeError("argument to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+
+	return strs, nil
+}
@@ -0,0 +1,298 @@
+package evaluator
+
+import (
+	"arkham/ast"
+	"arkham/lexer"
+	"arkham/object"
+	"arkham/optimizer"
+	"arkham/parser"
+	"arkham/resolver"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ModuleExtension is appended to imported paths that have no extension.
+const ModuleExtension = ".ark"
+
+// ModuleLoader loads modules from a file system. Each module is evaluated
+// once in its own environment and cached by its resolved path, so every
+// import of a module shares its bindings. A ModuleLoader is safe for
+// concurrent use, so spawned tasks can import: a task importing a module
+// another is evaluating waits for it rather than evaluating it again.
+type ModuleLoader struct {
+	fsys       fs.FS
+	searchPath []string
+	warn       func(module string, warning resolver.Diagnostic)
+
+	mu      sync.Mutex // guards the fields below and calls of warn
+	modules map[string]*object.Module
+	loads   map[string]*load // modules being evaluated
+}
+
+// load is the evaluation of a module or script. It is the importer of the
+// code it evaluates, so each evaluation knows the chain of imports leading
+// to it.
+type load struct {
+	loader   *ModuleLoader
+	path     string
+	importer *load // the load importing it, nil for a script
+
+	// Guarded by loader.mu. blocking counts the loads the evaluation, or
+	// the tasks it spawned, wait for. result is set before done is closed.
+	blocking map[*load]int
+	done     chan struct{}
+	result   object.Object // the module, or the error evaluating it
+}
+
+func (ld *load) Import(name, from string) object.Object {
+	return ld.loader.importFrom(ld, name, from)
+}
+
+// NewModuleLoader returns a loader reading modules from fsys. Imports are
+// resolved relative to the importing module first, then against each
+// directory of searchPath in order. All paths are slash-separated fs.FS
+// paths.
+func NewModuleLoader(fsys fs.FS, searchPath ...string) *ModuleLoader {
+	return &ModuleLoader{
+		fsys:       fsys,
+		searchPath: searchPath,
+		modules:    make(map[string]*object.Module),
+		loads:      make(map[string]*load),
+	}
+}
+
+// SetWarningHandler makes the loader report the warnings found resolving
+// each module, such as unused variables, to warn before evaluating it.
+func (l *ModuleLoader) SetWarningHandler(warn func(module string, warning resolver.Diagnostic)) {
+	l.warn = warn
+}
+
+// Run evaluates the script name in env, resolving its imports relative to
+// it, and returns the result of the script.
+func (l *ModuleLoader) Run(name string, env *object.Environment) object.Object {
+	return l.evalModule(&load{loader: l, path: path.Clean(name)}, env)
+}
+
+// Import returns the module name imported from the module from, evaluating
+// it on first use.
+func (l *ModuleLoader) Import(name, from string) object.Object {
+	return l.importFrom(nil, name, from)
+}
+
+// importFrom imports name for the evaluation importer, nil outside any.
+func (l *ModuleLoader) importFrom(importer *load, name, from string) object.Object {
+	resolved, ok := l.resolve(name, from)
+	if !ok {
+		return newImportError("module not found: %s", name)
+	}
+
+	l.mu.Lock()
+
+	if module, ok := l.modules[resolved]; ok {
+		l.mu.Unlock()
+		return module
+	}
+
+	current, loading := l.loads[resolved]
+	if cycle := l.cycle(importer, resolved, current); cycle != nil {
+		l.mu.Unlock()
+		return newImportError("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	if loading {
+		importer.block(current, 1)
+		l.mu.Unlock()
+
+		<-current.done
+
+		l.mu.Lock()
+		importer.block(current, -1)
+		l.mu.Unlock()
+		return current.result
+	}
+
+	next := &load{loader: l, path: resolved, importer: importer, done: make(chan struct{})}
+	l.loads[resolved] = next
+	importer.block(next, 1)
+	l.mu.Unlock()
+
+	env := object.NewEnvironment()
+	result := l.evalModule(next, env)
+
+	l.mu.Lock()
+	importer.block(next, -1)
+	delete(l.loads, resolved)
+	if !isError(result) {
+		module := &object.Module{Path: resolved, Env: env}
+		l.modules[resolved] = module
+		result = module
+	}
+	next.result = result
+	l.mu.Unlock()
+
+	close(next.done)
+	return result
+}
+
+// block records that ld waits for the load other, or no longer does when
+// n is -1. It must be called with the loader's mu held.
+func (ld *load) block(other *load, n int) {
+	if ld == nil {
+		return
+	}
+
+	if ld.blocking == nil {
+		ld.blocking = map[*load]int{}
+	}
+	ld.blocking[other] += n
+	if ld.blocking[other] == 0 {
+		delete(ld.blocking, other)
+	}
+}
+
+// cycle returns the chain of imports from path back to itself when
+// importer importing path would wait for itself, or nil. That is when path
+// is being imported along the chain leading to importer, or its load,
+// current, waits for one of those imports, which another task may be
+// evaluating. It must be called with mu held.
+func (l *ModuleLoader) cycle(importer *load, path string, current *load) []string {
+	chain := map[*load]bool{}
+	for ld := importer; ld != nil; ld = ld.importer {
+		if ld.path == path {
+			return append(imports(importer, ld), path)
+		}
+		chain[ld] = true
+	}
+
+	if current == nil {
+		return nil
+	}
+
+	// Search the loads current waits for, depth first, for the chain
+	visited := map[*load]bool{}
+	var search func(ld *load, waits []string) []string
+	search = func(ld *load, waits []string) []string {
+		waits = append(waits, ld.path)
+		if chain[ld] {
+			return append(imports(importer, ld), waits...)
+		}
+		if visited[ld] {
+			return nil
+		}
+		visited[ld] = true
+
+		for next := range ld.blocking {
+			if found := search(next, waits); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return search(current, nil)
+}
+
+// imports returns the paths of the chain of imports from outer to inner,
+// which imports it, outermost first.
+func imports(inner, outer *load) []string {
+	paths := []string{}
+	for ld := inner; ; ld = ld.importer {
+		paths = append([]string{ld.path}, paths...)
+		if ld == outer {
+			return paths
+		}
+	}
+}
+
+func (l *ModuleLoader) evalModule(ld *load, env *object.Environment) object.Object {
+	name := ld.path
+
+	src, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		return newImportError("cannot read module %s: %s", name, err)
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 0 {
+		return newImportError("cannot parse module %s: %s", name, strings.Join(errors, "; "))
+	}
+
+	macros := object.NewEnvironment()
+	DefineMacros(program, macros)
+	if err := ExpandMacros(program, macros); err != nil {
+		return newImportError("cannot expand macros in module %s: %s", name, err.Message)
+	}
+
+	undefined := []string{}
+	for _, d := range resolver.Resolve(program, Defined(env)) {
+		if d.Severity == resolver.Error {
+			undefined = append(undefined, d.String())
+		} else if l.warn != nil {
+			l.mu.Lock()
+			l.warn(name, d)
+			l.mu.Unlock()
+		}
+	}
+
+	if len(undefined) != 0 {
+		return newImportError("cannot resolve module %s: %s", name, strings.Join(undefined, "; "))
+	}
+
+	// The problems are those of the module as written, but optimizing
+	// changes its scopes, so the optimized module is resolved again
+	optimizer.Optimize(program, optimizer.All)
+	resolver.Resolve(program, Defined(env))
+
+	env.SetImporter(ld, name)
+
+	return Eval(program, env)
+}
+
+// resolve returns the path of the first existing module file for name.
+func (l *ModuleLoader) resolve(name, from string) (string, bool) {
+	if path.Ext(name) == "" {
+		name += ModuleExtension
+	}
+
+	dirs := append([]string{path.Dir(from)}, l.searchPath...)
+	for _, dir := range dirs {
+		candidate := path.Join(dir, name)
+		if !fs.ValidPath(candidate) {
+			continue
+		}
+
+		if info, err := fs.Stat(l.fsys, candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	importer, from := env.Importer()
+	if importer == nil {
+		return newImportError("cannot import %s: imports are not enabled", node.Path.Value)
+	}
+
+	module := importer.Import(node.Path.Value, from)
+	if isError(module) {
+		return module
+	}
+
+	if bound := bind(node.Name, module, env); isError(bound) {
+		return bound
+	}
+
+	return nil
+}
+
+func evalModuleExport(module *object.Module, name string) object.Object {
+	if val, ok := module.Export(name); ok {
+		return val
+	}
+
+	return newNameError("module %s has no export %s", module.Path, name)
+}
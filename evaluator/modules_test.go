@@ -0,0 +1,214 @@
+package evaluator
+
+import (
+	"arkham/object"
+	"arkham/resolver"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/math.ark":       {Data: []byte(`let square = fn(x) { x * x }; let _secret = 42; let pi = 3;`)},
+		"lib/geometry.ark":   {Data: []byte(`import "math" as m; let area = fn(r) { m["pi"] * m["square"](r) };`)},
+		"vendor/strings.ark": {Data: []byte(`let shout = fn(s) { s + "!" };`)},
+		"lib/broken.ark":     {Data: []byte(`let x 1;`)},
+		"lib/failing.ark":    {Data: []byte(`let x = 1 + true;`)},
+		"lib/undefined.ark":  {Data: []byte(`let f = fn() { nope };`)},
+		"lib/macros.ark":     {Data: []byte(`let twice = macro(x) { quote(unquote(x) * 2) }; let four = twice(2);`)},
+		"lib/badmacro.ark":   {Data: []byte(`let m = macro() { 1 }; m();`)},
+		"cycle/a.ark":        {Data: []byte(`import "b" as b;`)},
+		"cycle/b.ark":        {Data: []byte(`import "c" as c;`)},
+		"cycle/c.ark":        {Data: []byte(`import "a" as a;`)},
+		"cycle/self.ark":     {Data: []byte(`import "self" as me;`)},
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "lib/math" as m; m["square"](4)`, 16},
+		{`import "lib/math.ark" as m; m["pi"]`, 3},
+		{`import "lib/geometry" as g; g["area"](2)`, 12},
+		{`import "strings" as s; s["shout"]("hi")`, "hi!"},
+		{`import "lib/math" as m; m.square(m.pi)`, 9},
+		{`import "lib/math" as m; m.cube(2)`, "module lib/math.ark has no export cube"},
+		{`import "lib/math" as m; m`, "<module lib/math.ark>"},
+		{`import "lib/math" as m; m["_secret"]`, "module lib/math.ark has no export _secret"},
+		{`import "lib/math" as m; m["cube"]`, "module lib/math.ark has no export cube"},
+		{`import "nope" as n;`, "module not found: nope"},
+		{`import "lib/broken" as b;`, "cannot parse module lib/broken.ark: expected next token to be =, got INT instead"},
+		{`import "lib/failing" as f;`, "type mismatch: INTEGER + BOOLEAN"},
+		{`import "lib/undefined" as u;`, "cannot resolve module lib/undefined.ark: 1:16: undefined variable nope"},
+		{`import "lib/macros" as m; m.four`, 4},
+		{`import "lib/macros" as m; m.twice`, "module lib/macros.ark has no export twice"},
+		{`import "lib/badmacro" as b;`, "cannot expand macros in module lib/badmacro.ark: macro m returned INTEGER, not a quote"},
+		{`import "cycle/a" as a;`, "import cycle: cycle/a.ark -> cycle/b.ark -> cycle/c.ark -> cycle/a.ark"},
+		{`import "cycle/self" as s;`, "import cycle: cycle/self.ark -> cycle/self.ark"},
+		{`try { import "nope" as n; } catch (e) { e["kind"] }`, "ImportError"},
+		{`let f = fn() { import "lib/math" as m; m["pi"] }; f()`, 3},
+	}
+
+	for _, tt := range tests {
+		loader := NewModuleLoader(fsys, "vendor")
+		env := object.NewEnvironment()
+		env.SetImporter(loader, "")
+
+		evaluated := testEvalEnv(tt.input, env)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestConcurrentImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/leaf.ark":   {Data: []byte(`let value = 21;`)},
+		"lib/shared.ark": {Data: []byte(`import "leaf" as leaf; let value = leaf.value * 2;`)},
+		"lib/other.ark":  {Data: []byte(`import "leaf" as leaf; let value = leaf.value;`)},
+		"cycle/a.ark":    {Data: []byte(`import "b" as b;`)},
+		"cycle/b.ark":    {Data: []byte(`import "a" as a;`)},
+	}
+
+	input := `
+let shared = fn() { import "lib/shared" as m; m.value };
+let other = fn() { import "lib/other" as m; m.value };
+let tasks = map([1, 2, 3, 4], fn(i) { [spawn shared(), spawn other()] });
+map(tasks, fn(ts) { map(ts, await) })`
+
+	loader := NewModuleLoader(fsys)
+	env := object.NewEnvironment()
+	env.SetImporter(loader, "")
+
+	evaluated := testEvalEnv(input, env)
+	require.NotNil(t, evaluated)
+	assert.Equal(t, "[[42, 21], [42, 21], [42, 21], [42, 21]]", evaluated.Inspect())
+	assert.Len(t, loader.modules, 3)
+	assert.Empty(t, loader.loads)
+
+	// Tasks importing either end of a cycle fail rather than wait forever
+	input = `
+let a = fn() { import "cycle/a" as m; m };
+let b = fn() { import "cycle/b" as m; m };
+let tasks = [spawn a(), spawn b(), spawn a(), spawn b()];
+map(tasks, fn(t) { try { await(t) } catch (e) { e.kind } })`
+
+	loader = NewModuleLoader(fsys)
+	env = object.NewEnvironment()
+	env.SetImporter(loader, "")
+
+	evaluated = testEvalEnv(input, env)
+	require.NotNil(t, evaluated)
+	assert.Equal(t, "[ImportError, ImportError, ImportError, ImportError]", evaluated.Inspect())
+}
+
+func TestImportCycleAcrossTasks(t *testing.T) {
+	loader := NewModuleLoader(fstest.MapFS{})
+
+	// One task evaluates a and waits for b, which another task evaluates
+	a := &load{loader: loader, path: "a.ark"}
+	b := &load{loader: loader, path: "b.ark"}
+	a.block(b, 1)
+	loader.loads["a.ark"] = a
+	loader.loads["b.ark"] = b
+
+	// b importing a would wait for itself
+	assert.Equal(t, []string{"b.ark", "a.ark", "b.ark"}, loader.cycle(b, "a.ark", a))
+
+	// A script importing a merely waits for it
+	script := &load{loader: loader, path: "main.ark"}
+	assert.Nil(t, loader.cycle(script, "a.ark", a))
+}
+
+func TestImportEvaluatesModuleOnce(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ark":      {Data: []byte(`import "lib/state" as a; import "lib/state.ark" as b; a["items"] == b["items"]`)},
+		"lib/state.ark": {Data: []byte(`let items = push([], 1);`)},
+	}
+
+	loader := NewModuleLoader(fsys)
+	result := loader.Run("main.ark", object.NewEnvironment())
+
+	assert.Equal(t, TRUE, result)
+}
+
+func TestImportsRelativeToImporter(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/main.ark":     {Data: []byte(`import "util/fmt" as f; f["name"]`)},
+		"app/util/fmt.ark": {Data: []byte(`import "../shared" as s; let name = s["name"];`)},
+		"app/shared.ark":   {Data: []byte(`let name = "shared";`)},
+		"util/fmt.ark":     {Data: []byte(`let name = "wrong";`)},
+	}
+
+	loader := NewModuleLoader(fsys)
+	result := loader.Run("app/main.ark", object.NewEnvironment())
+
+	require.NotNil(t, result)
+	assert.Equal(t, "shared", result.Inspect())
+}
+
+func TestModuleWarnings(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ark":    {Data: []byte(`import "lib.ark" as l; let f = fn() { let a = 1; l.g() }; f()`)},
+		"lib.ark":     {Data: []byte(`let g = fn() { let [b, c] = [1, 2]; c };`)},
+		"strict.ark":  {Data: []byte(`let x = 1; if (false) { y }`)},
+		"prelude.ark": {Data: []byte(`extra + 1`)},
+	}
+
+	warnings := []string{}
+	loader := NewModuleLoader(fsys)
+	loader.SetWarningHandler(func(module string, warning resolver.Diagnostic) {
+		warnings = append(warnings, module+":"+warning.String())
+	})
+
+	result := loader.Run("main.ark", object.NewEnvironment())
+	testIntegerObject(t, result, 2)
+	assert.Equal(t, []string{"main.ark:1:43: unused variable a", "lib.ark:1:21: unused variable b"}, warnings)
+
+	// Undefined names are reported before the module runs
+	errObj, ok := loader.Run("strict.ark", object.NewEnvironment()).(*object.Error)
+	require.True(t, ok)
+	assert.Equal(t, "cannot resolve module strict.ark: 1:25: undefined variable y", errObj.Message)
+
+	// Names bound in the environment a script runs in are defined
+	env := object.NewEnvironment()
+	env.Set("extra", &object.Integer{Value: 1})
+	testIntegerObject(t, loader.Run("prelude.ark", env), 2)
+}
+
+func TestModulesAreOptimized(t *testing.T) {
+	fsys := fstest.MapFS{
+		// Inlining moves g() * 2 out of the function it was called in
+		"main.ark": {Data: []byte(`let f = fn(a) { let g = fn() { a + 1 }; fn() { g() * 2 }() }; if (true) { f(1) } else { 0 }`)},
+	}
+
+	warnings := []string{}
+	loader := NewModuleLoader(fsys)
+	loader.SetWarningHandler(func(module string, warning resolver.Diagnostic) {
+		warnings = append(warnings, module+":"+warning.String())
+	})
+
+	testIntegerObject(t, loader.Run("main.ark", object.NewEnvironment()), 4)
+	assert.Empty(t, warnings)
+}
+
+func TestImportWithoutLoader(t *testing.T) {
+	evaluated := testEval(`import "lib/math" as m;`)
+
+	errObj, ok := evaluated.(*object.Error)
+	require.Truef(t, ok, "no error object returned. got=%T(%+v)", evaluated, evaluated)
+	assert.Equal(t, "cannot import lib/math: imports are not enabled", errObj.Message)
+	assert.Equal(t, object.IMPORT_ERROR, errObj.Kind)
+}
@@ -0,0 +1,61 @@
+package evaluator
+
+import (
+	"arkham/optimizer"
+	"testing"
+)
+
+// TestOptimizedPrograms evaluates the test corpus again with every pass of
+// the optimizer applied, which must not change a result.
+func TestOptimizedPrograms(t *testing.T) {
+	corpus := []struct {
+		name string
+		test func(*testing.T)
+	}{
+		{"IntegerExpression", TestEvalIntegerExpression},
+		{"StringLiteral", TestEvalStringLiteral},
+		{"StringConcatenation", TestStringConcatenation},
+		{"StringInterpolation", TestStringInterpolation},
+		{"BooleanExpression", TestEvalBooleanExpression},
+		{"StructuralEquality", TestStructuralEquality},
+		{"BangOperator", TestEvalBangOperator},
+		{"IfElseExpression", TestIfElesExpression},
+		{"MatchExpression", TestMatchExpression},
+		{"ReturnStatements", TestReturnStatements},
+		{"ErrorHandling", TestErrorHandling},
+		{"TryCatchFinally", TestTryCatchFinally},
+		{"FinallyRunsOnError", TestFinallyRunsOnError},
+		{"LetStatement", TestLetStatement},
+		{"FunctionApplication", TestFunctionApplication},
+		{"FunctionParameters", TestFunctionParameters},
+		{"MemberAccessAndMethods", TestMemberAccessAndMethods},
+		{"Structs", TestStructs},
+		{"Enums", TestEnums},
+		{"ResultValues", TestResultValues},
+		{"ArrowFunctionsAndPipelines", TestArrowFunctionsAndPipelines},
+		{"BuiltinFunctions", TestBuiltinFunctions},
+		{"Closures", TestClosures},
+		{"ArrayLiterals", TestArrayLiterals},
+		{"ArrayIndexExpressions", TestArrayIndexExpressions},
+		{"HashLiterals", TestHashLiterals},
+		{"HashIndexExpressions", TestHashIndexExpressions},
+		{"DestructuringLet", TestDestructuringLet},
+		{"ResolvedScopes", TestResolvedScopes},
+		{"Concurrency", TestConcurrency},
+		{"SelectExpression", TestSelectExpression},
+		{"Generators", TestGenerators},
+		{"ForExpression", TestForExpression},
+		{"TailCalls", TestTailCalls},
+		{"MaxRecursionDepth", TestMaxRecursionDepth},
+		{"Quote", TestQuote},
+		{"QuoteUnquote", TestQuoteUnquote},
+		{"Macros", TestMacros},
+	}
+
+	optimizePasses = optimizer.All
+	defer func() { optimizePasses = 0 }()
+
+	for _, tt := range corpus {
+		t.Run(tt.name, tt.test)
+	}
+}
@@ -0,0 +1,127 @@
+package evaluator
+
+import (
+	"arkham/ast"
+	"arkham/object"
+)
+
+func evalStructStatement(node *ast.StructStatement, env *object.Environment) object.Object {
+	fields := make([]string, len(node.Fields))
+	for i, field := range node.Fields {
+		fields[i] = field.Value
+	}
+
+	if bound := bind(node.Name, &object.StructType{Name: node.Name.Value, Fields: fields}, env); isError(bound) {
+		return bound
+	}
+
+	return nil
+}
+
+func constructStruct(st *object.StructType, args []object.Object, named map[string]object.Object) object.Object {
+	fields, err := fieldValues(st.Name, st.Fields, args, named)
+	if err != nil {
+		return err
+	}
+
+	return &object.StructInstance{Struct: st, Fields: fields}
+}
+
+// fieldValues orders the arguments of a call to the struct or enum variant
+// owner by fields: positional arguments in field order, then named
+// arguments. Every field must be given once, and a field given no value is
+// NULL.
+func fieldValues(owner string, fields []string, args []object.Object, named map[string]object.Object) ([]object.Object, *object.Error) {
+	if len(args) > len(fields) {
+		return nil, newArgumentError("wrong number of arguments: want %d, got %d", len(fields), len(args))
+	}
+
+	values := make([]object.Object, len(fields))
+	given := make([]bool, len(fields))
+	for i, arg := range args {
+		values[i], given[i] = arg, true
+	}
+
+	for name, value := range named {
+		i := indexOf(fields, name)
+		if i < 0 {
+			return nil, newArgumentError("unknown field %s for %s", name, owner)
+		}
+
+		if given[i] {
+			return nil, newArgumentError("field %s for %s given twice", name, owner)
+		}
+
+		values[i], given[i] = value, true
+	}
+
+	for i, value := range values {
+		if !given[i] {
+			return nil, newArgumentError("missing field %s for %s", fields[i], owner)
+		}
+
+		if value == nil {
+			values[i] = NULL
+		}
+	}
+
+	return values, nil
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func evalStructField(instance *object.StructInstance, name string) object.Object {
+	if val, ok := instance.Get(name); ok {
+		return val
+	}
+
+	return newNameError("%s has no field %s", instance.Struct.Name, name)
+}
+
+func matchStructPattern(pattern *ast.StructPattern, value object.Object, env *object.Environment) (bool, *object.Error) {
+	st, ok := env.Get(pattern.Name.Value)
+	if !ok {
+		return false, newNameError("identifier not found: %s", pattern.Name.Value)
+	}
+
+	structType, ok := st.(*object.StructType)
+	if !ok {
+		return false, newTypeError("not a struct: %s", pattern.Name.Value)
+	}
+
+	instance, ok := value.(*object.StructInstance)
+	if !ok || instance.Struct != structType {
+		return false, nil
+	}
+
+	for _, pair := range pattern.Fields.Pairs {
+		var name string
+		switch key := pair.Key.(type) {
+		case *ast.Identifier:
+			name = key.Value
+		case *ast.StringLiteral:
+			name = key.Value
+		default:
+			return false, newPatternError("invalid field %s in pattern %s", pair.Key.String(), pattern.String())
+		}
+
+		val, ok := instance.Get(name)
+		if !ok {
+			return false, newPatternError("%s has no field %s", structType.Name, name)
+		}
+
+		matched, err := matchPattern(pair.Value, val, env)
+		if err != nil || !matched {
+			return matched, err
+		}
+	}
+
+	return true, nil
+}
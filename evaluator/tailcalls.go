@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"arkham/ast"
+	"arkham/object"
+)
+
+// tailCall is a call to a user function in tail position, returned by
+// evalTail for the function being applied to apply next.
+type tailCall struct {
+	node     *ast.CallExpression
+	function *object.Function
+	args     []object.Object
+	named    map[string]object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType         { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string                 { return "<tail call>" }
+func (tc *tailCall) Equals(other object.Object) bool { return tc == other }
+
+// evalTail evaluates node in tail position of a function body: the last
+// statement of the body, and of if branches and match arms there, or the
+// value of a return statement there. A call to a user function in tail
+// position is not applied but returned as a *tailCall. A call inside a try
+// expression is not in tail position, as the try must see its result.
+func evalTail(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for i, stmt := range node.Statements {
+			if i == len(node.Statements)-1 {
+				return evalTail(stmt, env)
+			}
+
+			result := Eval(stmt, env)
+			if isUnwinding(result) {
+				return result
+			}
+		}
+
+		return nil
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, env)
+	case *ast.ReturnStatement:
+		val := evalTail(node.ReturnValue, env)
+		if _, ok := val.(*tailCall); ok || isUnwinding(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.IfExpression:
+		condition := Eval(node.Condition, env)
+		if isUnwinding(condition) {
+			return condition
+		}
+
+		if isTruthy(condition) {
+			return evalTail(node.Consequence, env)
+		} else if node.Alternative != nil {
+			return evalTail(node.Alternative, env)
+		}
+		return NULL
+	case *ast.MatchExpression:
+		arm, armEnv, result := matchArm(node, env)
+		if arm == nil {
+			return result
+		}
+		return evalTail(arm.Body, armEnv)
+	case *ast.CallExpression:
+		if node.IsCallTo("quote") {
+			return quote(node, env)
+		}
+
+		function, args, named, err := evalCall(node, env)
+		if err != nil {
+			return err
+		}
+
+		if fn, ok := function.(*object.Function); ok && !fn.Generator {
+			return &tailCall{node: node, function: fn, args: args, named: named}
+		}
+
+		return applyCall(node, env, function, args, named)
+	default:
+		return Eval(node, env)
+	}
+}
+
+// tailCallFrames records the calls made in tail position while applying a
+// function, for error traces. A call site is recorded once, so a function
+// looping by tail recursion adds one frame rather than one per iteration.
+type tailCallFrames []*ast.CallExpression
+
+func (frames *tailCallFrames) add(node *ast.CallExpression) {
+	for _, frame := range *frames {
+		if frame == node {
+			return
+		}
+	}
+
+	*frames = append(*frames, node)
+}
+
+// appendTo appends the frames to trace, innermost call first.
+func (frames tailCallFrames) appendTo(trace []string) []string {
+	for i := len(frames) - 1; i >= 0; i-- {
+		trace = append(trace, callFrame(frames[i]))
+	}
+	return trace
+}
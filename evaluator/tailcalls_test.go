@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"arkham/object"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// Deep enough to overflow the Go stack without tail calls
+		{"let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } }; countdown(1000000)", 0},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; }; return sum(n - 1, acc + n); }; sum(100000, 0)", 5000050000},
+		{"let count = fn(n) { match (n) { 0 => \"done\", _ => count(n - 1) } }; count(100000)", "done"},
+		{"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(100001)", "false"},
+		{"let loop = fn(n, acc = 0) { if (n == 0) { acc } else { loop(n - 1, acc: acc + 1) } }; loop(100000)", 100000},
+		{"let loop = fn(n) { if (n == 0) { len(\"done\") } else { loop(n - 1) } }; loop(10)", 4},
+		{"let gen = fn*() { yield 1 }; let f = fn() { gen() }; f()", "<generator>"},
+		{"let f = fn() { try { g() } finally { 1 } }; let g = fn() { 2 }; f()", 2},
+		{"let f = fn(n) { if (n == 0) { 1 + true } else { f(n - 1) } }; f(100000)", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(n) { if (n == 0) { error(\"bottom\") } else { f(n - 1) } }; try { f(100000) } catch (e) { e.trace }",
+			"[error at 1:36, f at 1:57, f at 1:77]"},
+		{"let f = fn() { g(1) }; let g = fn(x, y) { x }; try { f() } catch (e) { e.trace }", "[g at 1:17, f at 1:55]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestMaxRecursionDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", "maximum recursion depth 10000 exceeded"},
+		{"let f = fn(n) { 1 + f(n + 1) }; try { f(0) } catch (e) { e.kind }", "RuntimeError"},
+		{"let f = fn(n) { map([n], f) }; f(0)", "maximum recursion depth 10000 exceeded"},
+		{"let f = fn(n) { reduce([n], 0, fn(acc, x) { f(x) }) }; f(0)", "maximum recursion depth 10000 exceeded"},
+		{"let f = fn(n) { 1 + f(n + 1) }; await(spawn f(0))", "maximum recursion depth 10000 exceeded"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(9999)", 9999},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(20000)", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			require.NotNil(t, evaluated, "input: %s", tt.input)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				assert.Equal(t, expected, errObj.Message, "input: %s", tt.input)
+				continue
+			}
+			assert.Equal(t, expected, evaluated.Inspect(), "input: %s", tt.input)
+		}
+	}
+}
+
+func TestSetMaxRecursionDepth(t *testing.T) {
+	previous := SetMaxRecursionDepth(50)
+	defer SetMaxRecursionDepth(previous)
+
+	assert.Equal(t, DefaultMaxRecursionDepth, previous)
+	assert.Equal(t, 50, MaxRecursionDepth())
+
+	input := "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; [f(49), try { f(50) } catch (e) { e.message }]"
+
+	evaluated := testEval(input)
+	require.NotNil(t, evaluated)
+	assert.Equal(t, `[49, maximum recursion depth 50 exceeded]`, evaluated.Inspect())
+}
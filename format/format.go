@@ -0,0 +1,165 @@
+// Package format prints Arkham source in a canonical layout: one statement
+// per line, indented with tabs, single spaces around operators and only the
+// parentheses precedence requires. Comments are kept, as are single blank
+// lines between statements. Formatting formatted source changes nothing.
+package format
+
+import (
+	"arkham/lexer"
+	"arkham/parser"
+	"arkham/token"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Source formats the Arkham program src, or returns the errors parsing it.
+func Source(src []byte) ([]byte, error) {
+	input := string(src)
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	pr := &printer{source: scan(input)}
+	pr.program(program)
+
+	return []byte(pr.out.String()), nil
+}
+
+// pos is where a token starts in the source.
+type pos struct {
+	line, column int
+}
+
+func at(tok token.Token) pos {
+	return pos{tok.Line, tok.Column}
+}
+
+func (a pos) before(b pos) bool {
+	return a.line < b.line || a.line == b.line && a.column < b.column
+}
+
+type comment struct {
+	pos
+	text    string
+	printed bool
+}
+
+// source holds what the printer needs of the source besides the tree: where
+// the tokens are and the comments, which the tree leaves out.
+type source struct {
+	tokens   []token.Token // the tokens but comments, ending with EOF
+	index    map[pos]int   // of each token in tokens
+	closing  map[pos]pos   // the bracket closing each opening one
+	comments []*comment
+}
+
+func scan(input string) *source {
+	s := &source{index: map[pos]int{}, closing: map[pos]pos{}}
+	l := lexer.NewWithComments(input)
+	open := []pos{}
+
+	for {
+		tok := l.NextToken()
+
+		if tok.Type == token.COMMENT {
+			s.comments = append(s.comments, &comment{pos: at(tok), text: tok.Literal})
+			continue
+		}
+
+		s.index[at(tok)] = len(s.tokens)
+		s.tokens = append(s.tokens, tok)
+
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			open = append(open, at(tok))
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			if len(open) > 0 {
+				s.closing[open[len(open)-1]] = at(tok)
+				open = open[:len(open)-1]
+			}
+		case token.EOF:
+			return s
+		}
+	}
+}
+
+// next returns the position of the token following the one at p.
+func (s *source) next(p pos) pos {
+	i, ok := s.index[p]
+	if !ok || i+1 >= len(s.tokens) {
+		return p
+	}
+	return at(s.tokens[i+1])
+}
+
+// find returns the position of the first token of type typ from p on, or
+// the zero position when there is none.
+func (s *source) find(p pos, typ token.TokenType) pos {
+	i, ok := s.index[p]
+	if !ok {
+		return pos{}
+	}
+	for ; i < len(s.tokens); i++ {
+		if s.tokens[i].Type == typ {
+			return at(s.tokens[i])
+		}
+	}
+	return pos{}
+}
+
+// itemStarts returns where each item of the list separated by commas within
+// the brackets opening at open starts, or nil when open opens none.
+func (s *source) itemStarts(open pos) []pos {
+	close, ok := s.closing[open]
+	if !ok {
+		return nil
+	}
+
+	starts := []pos{}
+	item := true
+	for i := s.index[open] + 1; at(s.tokens[i]).before(close); i++ {
+		tok := s.tokens[i]
+		if item {
+			starts = append(starts, at(tok))
+			item = false
+		}
+
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			if c, ok := s.closing[at(tok)]; ok {
+				i = s.index[c]
+			}
+		case token.COMMA:
+			item = true
+		}
+	}
+	return starts
+}
+
+// end returns the position of the end of the source.
+func (s *source) end() pos {
+	return at(s.tokens[len(s.tokens)-1])
+}
+
+// lastLine returns the line of the last token before p.
+func (s *source) lastLine(p pos) int {
+	i := sort.Search(len(s.tokens), func(i int) bool { return !at(s.tokens[i]).before(p) })
+	if i == 0 {
+		return p.line
+	}
+	return s.tokens[i-1].Line
+}
+
+// hasComments reports whether there are comments between from and to.
+func (s *source) hasComments(from, to pos) bool {
+	for _, c := range s.comments {
+		if from.before(c.pos) && c.pos.before(to) {
+			return true
+		}
+	}
+	return false
+}
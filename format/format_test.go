@@ -0,0 +1,285 @@
+package format
+
+import (
+	"arkham/lexer"
+	"arkham/parser"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let   x=1;", "let x = 1\n"},
+		{"let x = 1; let y = 2;", "let x = 1\nlet y = 2\n"},
+		{"1+2*3", "1 + 2 * 3\n"},
+		{"(1+2)*3", "(1 + 2) * 3\n"},
+		{"((1 + 2))", "1 + 2\n"},
+		{"1 - (2 - 3)", "1 - (2 - 3)\n"},
+		{"(1 - 2) - 3", "1 - 2 - 3\n"},
+		{"-(1 + 2)", "-(1 + 2)\n"},
+		{"-(-x)", "--x\n"},
+		{"!(a == b)", "!(a == b)\n"},
+		{"(-a)[0]", "(-a)[0]\n"},
+		{"(a + b)(c)", "(a + b)(c)\n"},
+		{"a.b[0](c)?", "a.b[0](c)?\n"},
+		{"add(a,b ,...xs, y:1)", "add(a, b, ...xs, y: 1)\n"},
+		{"[1,2 , 3][0]", "[1, 2, 3][0]\n"},
+		{`{"a":1,2:[3]}`, "{\"a\": 1, 2: [3]}\n"},
+		{`"a\"b\\c\n\t${x}\${y}"`, "\"a\\\"b\\\\c\\n\\t${x}\\${y}\"\n"},
+		{`"${ a + "${b}" }!"`, "\"${a + \"${b}\"}!\"\n"},
+		{"xs |> map(f) |> sum", "xs |> map(f) |> sum()\n"},
+		{"(xs |> f)(1)", "(xs |> f())(1)\n"},
+		{"xs |> f()?", "xs |> f()?\n"},
+		{"(a |> f()) + 1", "(a |> f()) + 1\n"},
+		{"a == b |> f", "a == b |> f()\n"},
+		{"x=>x*2", "x => x * 2\n"},
+		{"(x) => x", "x => x\n"},
+		{"(a, b = 1, ...rest) => a", "(a, b = 1, ...rest) => a\n"},
+		{"() => { 1 }", "() => { 1 }\n"},
+		{"x => ({})", "x => ({})\n"},
+		{"f(x => x, y)", "f(x => x, y)\n"},
+		{"(x => x)(1)", "(x => x)(1)\n"},
+		{"let f = fn (a,b) { a + b };", "let f = fn(a, b) { a + b }\n"},
+		{"fn* gen(){ yield 1; yield }", "fn* gen() {\n\tyield 1\n\tyield\n}\n"},
+		{"fn* gen(){ yield; x }", "fn* gen() {\n\tyield;\n\tx\n}\n"},
+		{"fn(){}", "fn() {}\n"},
+		{"fn(){ let x = 1; x }", "fn() {\n\tlet x = 1\n\tx\n}\n"},
+		{"fn(){ return 1 }", "fn() { return 1 }\n"},
+		{"fn(){ let x = 1 }", "fn() {\n\tlet x = 1\n}\n"},
+		{"let m = macro(a,b) { quote(unquote(a)) };", "let m = macro(a, b) { quote(unquote(a)) }\n"},
+		{"if(x){1}", "if (x) { 1 }\n"},
+		{"if (x) { 1 } else { 2 }", "if (x) { 1 } else { 2 }\n"},
+		{"if (x) { 1 } else if (y) { 2 } else { 3 }", "if (x) { 1 } else if (y) { 2 } else { 3 }\n"},
+		{"if (x) { 1 } else { if (y) { 2 } }", "if (x) { 1 } else { if (y) { 2 } }\n"},
+		{"if (x) { 1 };\n(y)", "if (x) { 1 }\ny\n"},
+		{"if (x) { 1 };\n[1]", "if (x) { 1 };\n[1]\n"},
+		{"a;\n(b + c) * d", "a;\n(b + c) * d\n"},
+		{"let a = b;\n[1]", "let a = b;\n[1]\n"},
+		{"a;\n-1", "a;\n-1\n"},
+		{"a;\n(-1)", "a;\n-1\n"},
+		{"try { f() } catch (e) { 1 } finally { 2 }", "try { f() } catch (e) { 1 } finally { 2 }\n"},
+		{"for (x in xs) { puts(x) }", "for (x in xs) { puts(x) }\n"},
+		{"for ([k, v] in pairs) { k }", "for ([k, v] in pairs) { k }\n"},
+		{"spawn f(x)", "spawn f(x)\n"},
+		{"spawn (f)(x)", "spawn f(x)\n"},
+		{"return 1;", "return 1\n"},
+		{"throw error(\"x\");", "throw error(\"x\")\n"},
+		{`import "lib/a.ark" as a;`, "import \"lib/a.ark\" as a\n"},
+		{"struct Point{x,y}", "struct Point { x, y }\n"},
+		{"struct Empty {}", "struct Empty {}\n"},
+		{"enum Shape{Circle(r),Square(s),Dot,Unit()}", "enum Shape { Circle(r), Square(s), Dot, Unit() }\n"},
+		{"let [a, b, ...c] = xs", "let [a, b, ...c] = xs\n"},
+		{"let [...c] = xs", "let [...c] = xs\n"},
+		{`let {a, "b": b, c: [d]} = h`, "let {a, \"b\": b, c: [d]} = h\n"},
+		{
+			"match (x) { 1 => \"one\", -1 => \"minus\", Point{x, y} => x, Shape.Circle(r) => r, Dot => 0, n if n > 0 => { n }, _ => {} }",
+			"match (x) {\n\t1 => \"one\",\n\t-1 => \"minus\",\n\tPoint{x, y} => x,\n\tShape.Circle(r) => r,\n\tDot => 0,\n\tn if n > 0 => { n },\n\t_ => {},\n}\n",
+		},
+		{"match (x) { _ => ({a: 1}) }", "match (x) {\n\t_ => ({a: 1}),\n}\n"},
+		{"match (x) { n if (y => y) => n }", "match (x) {\n\tn if (y => y) => n,\n}\n"},
+		{"match (x) { n if any(xs, y => y) => n }", "match (x) {\n\tn if any(xs, y => y) => n,\n}\n"},
+		{"match (x) {}", "match (x) {}\n"},
+		{
+			"select { recv(c) as v => v, send(c, 1) => { 1 }, _ => 0 }",
+			"select {\n\trecv(c) as v => v,\n\tsend(c, 1) => { 1 },\n\t_ => 0,\n}\n",
+		},
+		{
+			"let f = fn(x) { let y = x * 2; if (y > 10) { return y }; y + 1 }",
+			"let f = fn(x) {\n\tlet y = x * 2\n\tif (y > 10) { return y }\n\ty + 1\n}\n",
+		},
+		{
+			"let a = 1\n\n\n\nlet b = 2\nlet c = 3\n",
+			"let a = 1\n\nlet b = 2\nlet c = 3\n",
+		},
+		{
+			"fn() {\n\n  let a = 1\n\n}",
+			"fn() {\n\tlet a = 1\n}\n",
+		},
+		{
+			"let long = fn(x) { someFunctionWithALongName(x, anotherArgumentWithALongName, yetAnotherArgument, andOneMore) }",
+			"let long = fn(x) {\n\tsomeFunctionWithALongName(x, anotherArgumentWithALongName, yetAnotherArgument, andOneMore)\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		require.NoError(t, err, tt.input)
+
+		assert.Equalf(t, tt.expected, string(formatted), "formatting %q", tt.input)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"// only a comment", "// only a comment\n"},
+		{"let x = 1 // one  ", "let x = 1 // one\n"},
+		{"let x = 1; // one", "let x = 1 // one\n"},
+		{
+			"// leading\nlet x = 1\n\n// detached\n\nlet y = 2\n// trailing",
+			"// leading\nlet x = 1\n\n// detached\n\nlet y = 2\n// trailing\n",
+		},
+		{
+			"let f = fn(x) {\n  // inside\n  x // last\n}",
+			"let f = fn(x) {\n\t// inside\n\tx // last\n}\n",
+		},
+		{
+			"let f = fn(x) { x // one line\n}",
+			"let f = fn(x) {\n\tx // one line\n}\n",
+		},
+		{
+			"fn() {\n  // nothing\n}",
+			"fn() {\n\t// nothing\n}\n",
+		},
+		{
+			"let h = {\n  \"a\": 1, // a\n  \"b\": 2, // b\n}",
+			"let h = {\n\t\"a\": 1, // a\n\t\"b\": 2 // b\n}\n",
+		},
+		{
+			"let h = {\n  // one\n  \"a\": 1,\n\n  // before b\n  \"b\": 2\n}",
+			"let h = {\n\t// one\n\t\"a\": 1,\n\n\t// before b\n\t\"b\": 2\n}\n",
+		},
+		{
+			"let f = fn(a, // first\n  b) { a + b }",
+			"let f = fn(\n\ta, // first\n\tb\n) { a + b }\n",
+		},
+		{
+			"let f = (a, // first\n  ...rest) => a",
+			"let f = (\n\ta, // first\n\t...rest\n) => a\n",
+		},
+		{
+			"let xs = [1, // uno\n  [2, 3], 4]",
+			"let xs = [\n\t1, // uno\n\t[2, 3],\n\t4\n]\n",
+		},
+		{
+			"f(1, // one\n  2)",
+			"f(\n\t1, // one\n\t2\n)\n",
+		},
+		{
+			"let [a, // head\n  ...rest] = xs",
+			"let [\n\ta, // head\n\t...rest\n] = xs\n",
+		},
+		{
+			"match (x) {\n  // ones\n  1 => 1, // one\n  _ => { // other\n    0\n  },\n}",
+			"match (x) {\n\t// ones\n\t1 => 1, // one\n\t_ => {\n\t\t// other\n\t\t0\n\t},\n}\n",
+		},
+		{
+			"if (x) {\n  1\n} // if\nelse {\n  2 // two\n}",
+			"if (x) { 1 } else {\n\t2 // two\n} // if\n",
+		},
+		{
+			"let s = \"// not a comment\"",
+			"let s = \"// not a comment\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		require.NoError(t, err, tt.input)
+
+		assert.Equalf(t, tt.expected, string(formatted), "formatting %q", tt.input)
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	_, err := Source([]byte("let = 1"))
+	assert.EqualError(t, err, "expected next token to be IDENT, got = instead\nno prefix parse function for = found")
+}
+
+// corpus holds programs formatting is checked to be idempotent on and to
+// keep the meaning of.
+var corpus = []string{
+	`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)`,
+	`let counter = fn() {
+    let count = 0   // starts at zero
+    fn() { count + 1 }
+}
+
+
+// make one
+let c = counter()
+c()`,
+	`struct Point { x, y }
+enum Shape { Circle(r), Rect(w, h) }
+let area = fn(s) {
+  match (s) {
+    Shape.Circle(r) => 3 * r * r,   // roughly
+    Shape.Rect(w, h) if w == h => w * w,
+    Rect(w, h) => { let a = w * h; a },
+  }
+};
+[Circle(1), Rect(2, 3)] |> map(area) |> sum()`,
+	`fn* naturals() { let n = 0; for (x in range(10)) { yield x }; yield }
+let xs = []
+for ([a, b] in zip(xs, xs)) { puts("${a}: ${b + 1}") }`,
+	`let ch = channel(); spawn fn() { send(ch, 1) }()
+select { recv(ch) as v => puts(v), _ => { puts("none") } }`,
+	`let parse = fn(s) {
+	// propagate errors
+	let n = toInt(s)?
+	try {
+		check(n)
+	} catch (e) {
+		throw e
+	} finally { cleanup() }
+}
+let g = x => y => x + y
+let h = (a, [b, c], {d}, e = 1, ...rest) => { a }
+h(1, ...[2], e: 3)`,
+	`let m = macro(a, b) { quote(unquote(b) - unquote(a)) }
+m(1, 2);
+-1;
+(1 + 2) * 3;
+[1, 2][0]
+if (true) { 1 } else if (false) { 2 }
+"${ {"a": 1}["a"] }"`,
+	`let add = fn(a, // first
+  b) { a + b }
+let h = {
+  // one
+  "a": 1,
+  // before b
+  "b": add(1, // uno
+    2),
+}
+let xs = [1, // uno
+  h["b"]]
+match (xs) { [x, // head
+  ...rest] => x }`,
+}
+
+func TestIdempotent(t *testing.T) {
+	for _, input := range corpus {
+		once, err := Source([]byte(input))
+		require.NoError(t, err, input)
+
+		twice, err := Source(once)
+		require.NoError(t, err, string(once))
+
+		assert.Equalf(t, string(once), string(twice), "formatting %q again", input)
+	}
+}
+
+func TestKeepsMeaning(t *testing.T) {
+	for _, input := range corpus {
+		formatted, err := Source([]byte(input))
+		require.NoError(t, err, input)
+
+		assert.Equalf(t, parse(t, input), parse(t, string(formatted)), "formatting %q", input)
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), input)
+	return program.String()
+}
@@ -0,0 +1,764 @@
+package format
+
+import (
+	"arkham/ast"
+	"arkham/parser"
+	"arkham/token"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxWidth is the column up to which a block of a single statement is
+	// kept on one line, counting a tab as tabWidth columns.
+	maxWidth = 100
+	tabWidth = 4
+
+	// atom is the precedence of literals and other expressions ending in a
+	// bracket or keyword, which never need parentheses.
+	atom = parser.POSTFIX + 1
+)
+
+var operators = map[string]int{
+	"==": parser.EQUALS,
+	"!=": parser.EQUALS,
+	"<":  parser.LESSGREATER,
+	">":  parser.LESSGREATER,
+	"+":  parser.SUM,
+	"-":  parser.SUM,
+	"*":  parser.PRODUCT,
+	"/":  parser.PRODUCT,
+}
+
+type printer struct {
+	*source
+	out     strings.Builder
+	indent  int
+	column  int  // where the next character goes, from 0
+	pending bool // the indentation of a new line is still to be written
+
+	// noArrow is set in a match guard, where an arrow function needs
+	// parentheses as the parser takes its => for the arm's.
+	noArrow bool
+
+	// quiet leaves the comments out when printing only to measure.
+	quiet bool
+}
+
+func (p *printer) write(s string) {
+	if p.pending {
+		p.out.WriteString(strings.Repeat("\t", p.indent))
+		p.column = p.indent * tabWidth
+		p.pending = false
+	}
+
+	p.out.WriteString(s)
+	p.column += utf8.RuneCountInString(s)
+}
+
+func (p *printer) newline() {
+	p.out.WriteByte('\n')
+	p.column = 0
+	p.pending = true
+}
+
+// render returns what print prints from where p is, leaving p unchanged.
+func (p *printer) render(print func(q *printer)) string {
+	q := &printer{source: p.source, indent: p.indent, column: p.column, noArrow: p.noArrow, quiet: true}
+	print(q)
+	return q.out.String()
+}
+
+// comments returns the comments between from and to not printed yet.
+func (p *printer) comments(from, to pos) []*comment {
+	if p.quiet {
+		return nil
+	}
+
+	comments := []*comment{}
+	for _, c := range p.source.comments {
+		if !c.printed && from.before(c.pos) && c.pos.before(to) {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+// list prints the items between open and close one per line, each after the
+// comments preceding it. starts holds where each item starts and print
+// prints one. A comment on the last line of an item follows it on its line,
+// other comments within it go on lines of their own after it. A blank line
+// between items or comments is kept, more than one become one.
+func (p *printer) list(open, close pos, starts []pos, print func(i int)) {
+	last := -1 // line in the source of what was printed last
+
+	line := func(l int) {
+		if last >= 0 {
+			p.newline()
+			if l > last+1 {
+				p.newline()
+			}
+		}
+		last = l
+	}
+
+	flush := func(to pos) {
+		for _, c := range p.comments(open, to) {
+			line(c.line)
+			p.write(c.text)
+			c.printed = true
+		}
+	}
+
+	for i, start := range starts {
+		end := close
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+
+		flush(start)
+		line(start.line)
+		print(i)
+
+		last = p.lastLine(end)
+		if comments := p.comments(start, end); len(comments) > 0 && comments[0].line <= last {
+			p.write(" " + comments[0].text)
+			comments[0].printed = true
+		}
+	}
+
+	flush(close)
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, pos{}, p.end())
+
+	if p.out.Len() > 0 {
+		p.newline()
+	}
+}
+
+// statements prints stmts, which are between open and close, one per line.
+func (p *printer) statements(stmts []ast.Statement, open, close pos) {
+	starts := make([]pos, len(stmts))
+	for i, stmt := range stmts {
+		starts[i] = start(stmt)
+	}
+
+	p.list(open, close, starts, func(i int) {
+		p.statement(stmts[i])
+
+		if i+1 < len(stmts) && p.runsOn(stmts[i], stmts[i+1]) {
+			p.write(";")
+		}
+	})
+}
+
+// runsOn reports whether the parser would take next, on a line of its own,
+// for part of stmt.
+func (p *printer) runsOn(stmt, next ast.Statement) bool {
+	switch stmt.(type) {
+	case *ast.ImportStatement, *ast.StructStatement, *ast.EnumStatement:
+		return false
+	}
+
+	if endsInBareYield(stmt) {
+		return true
+	}
+
+	// A call, index or subtraction continues the expression
+	s := p.render(func(q *printer) { q.statement(next) })
+	return strings.HasPrefix(s, "(") || strings.HasPrefix(s, "[") || strings.HasPrefix(s, "-")
+}
+
+// endsInBareYield reports whether stmt is printed ending in a yield without
+// a value, which would take the next statement for its value.
+func endsInBareYield(stmt ast.Statement) bool {
+	var last ast.Expression
+
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		last = stmt.Value
+	case *ast.ReturnStatement:
+		last = stmt.ReturnValue
+	case *ast.ThrowStatement:
+		last = stmt.Value
+	case *ast.ExpressionStatement:
+		last = stmt.Expression
+	}
+
+	for {
+		switch exp := last.(type) {
+		case *ast.YieldExpression:
+			if exp.Value == nil {
+				return true
+			}
+			last = exp.Value
+		case *ast.FunctionLiteral:
+			if !hasExpressionBody(exp) {
+				return false
+			}
+			last = exp.Body.Statements[0].(*ast.ExpressionStatement).Expression
+		default:
+			return false
+		}
+	}
+}
+
+func start(stmt ast.Statement) pos {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return at(stmt.Token)
+	case *ast.ReturnStatement:
+		return at(stmt.Token)
+	case *ast.ThrowStatement:
+		return at(stmt.Token)
+	case *ast.ImportStatement:
+		return at(stmt.Token)
+	case *ast.StructStatement:
+		return at(stmt.Token)
+	case *ast.EnumStatement:
+		return at(stmt.Token)
+	case *ast.ExpressionStatement:
+		return at(stmt.Token)
+	default:
+		return pos{}
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let ")
+		if stmt.Pattern != nil {
+			p.expression(stmt.Pattern, parser.LOWEST)
+		} else {
+			p.write(stmt.Name.Value)
+		}
+		p.write(" = ")
+		p.expression(stmt.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expression(stmt.Value, parser.LOWEST)
+	case *ast.ImportStatement:
+		p.write("import " + quote(stmt.Path.Value) + " as " + stmt.Name.Value)
+	case *ast.StructStatement:
+		p.write("struct " + stmt.Name.Value + " ")
+		p.names(stmt.Fields, "{ ", " }", "{}")
+	case *ast.EnumStatement:
+		p.write("enum " + stmt.Name.Value + " ")
+		if len(stmt.Variants) == 0 {
+			p.write("{}")
+			break
+		}
+		p.write("{ ")
+		for i, variant := range stmt.Variants {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(variant.Name.Value)
+			if variant.Fields != nil {
+				p.names(variant.Fields, "(", ")", "()")
+			}
+		}
+		p.write(" }")
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+	}
+}
+
+// names prints idents separated by commas between open and close, or empty
+// when there are none.
+func (p *printer) names(idents []*ast.Identifier, open, close, empty string) {
+	if len(idents) == 0 {
+		p.write(empty)
+		return
+	}
+
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+	}
+	p.write(open + strings.Join(names, ", ") + close)
+}
+
+// block prints a block on one line when it is a single expression, return
+// or throw fitting there, and one statement per line otherwise.
+func (p *printer) block(block *ast.BlockStatement) {
+	open := at(block.Token)
+	close := p.closing[open]
+	comments := p.hasComments(open, close)
+
+	if len(block.Statements) == 0 && !comments {
+		p.write("{}")
+		return
+	}
+
+	if len(block.Statements) == 1 && !comments {
+		s := p.render(func(q *printer) {
+			q.write("{ ")
+			q.statement(block.Statements[0])
+			q.write(" }")
+		})
+		if inline(block.Statements[0]) && !strings.Contains(s, "\n") && p.column+utf8.RuneCountInString(s) <= maxWidth {
+			p.write(s)
+			return
+		}
+	}
+
+	p.write("{")
+	p.indent++
+	p.newline()
+	p.statements(block.Statements, open, close)
+	p.indent--
+	p.newline()
+	p.write("}")
+}
+
+func inline(stmt ast.Statement) bool {
+	switch stmt.(type) {
+	case *ast.ExpressionStatement, *ast.ReturnStatement, *ast.ThrowStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// precedence returns the precedence an expression is parsed at, which its
+// operands need at least to go without parentheses.
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return operators[exp.Operator]
+	case *ast.PrefixExpression, *ast.SpawnExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		if exp.Pipe {
+			return parser.PIPELINE
+		}
+		return parser.CALL
+	case *ast.PropagateExpression:
+		if call, ok := exp.Value.(*ast.CallExpression); ok && call.Pipe {
+			return parser.PIPELINE
+		}
+		return parser.CALL
+	case *ast.IndexExpression, *ast.MemberExpression:
+		return parser.CALL
+	case *ast.YieldExpression:
+		return parser.LOWEST
+	case *ast.FunctionLiteral:
+		if exp.Arrow {
+			return parser.LOWEST
+		}
+		return atom
+	default:
+		return atom
+	}
+}
+
+// expression prints exp where it needs at least precedence prec, in
+// parentheses when it has less.
+func (p *printer) expression(exp ast.Expression, prec int) {
+	fn, isFunction := exp.(*ast.FunctionLiteral)
+
+	if precedence(exp) < prec || p.noArrow && isFunction && fn.Arrow {
+		noArrow := p.noArrow
+		p.noArrow = false
+		p.write("(")
+		p.print(exp)
+		p.write(")")
+		p.noArrow = noArrow
+		return
+	}
+
+	p.print(exp)
+}
+
+// bracketed prints what print prints where arrow functions go without
+// parentheses, as they do within brackets.
+func (p *printer) bracketed(print func()) {
+	noArrow := p.noArrow
+	p.noArrow = false
+	print()
+	p.noArrow = noArrow
+}
+
+// expressions prints exps separated by commas within the brackets opening
+// at open, which it leaves to the caller.
+func (p *printer) expressions(open pos, exps []ast.Expression) {
+	p.items(open, len(exps), func(i int) { p.expression(exps[i], parser.LOWEST) })
+}
+
+// items prints n items separated by commas within the brackets opening at
+// open, print printing each. They go on one line unless there are comments
+// within the brackets, which keep their place with one item per line then.
+func (p *printer) items(open pos, n int, print func(i int)) {
+	close, ok := p.closing[open]
+	starts := p.itemStarts(open)
+
+	p.bracketed(func() {
+		if !ok || len(starts) != n || !p.hasComments(open, close) {
+			for i := 0; i < n; i++ {
+				if i > 0 {
+					p.write(", ")
+				}
+				print(i)
+			}
+			return
+		}
+
+		p.indent++
+		p.newline()
+		p.list(open, close, starts, func(i int) {
+			print(i)
+			if i+1 < n {
+				p.write(",")
+			}
+		})
+		p.indent--
+		p.newline()
+	})
+}
+
+func (p *printer) print(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		p.write(exp.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(quote(exp.Value))
+	case *ast.Boolean:
+		if exp.Value {
+			p.write("true")
+		} else {
+			p.write("false")
+		}
+	case *ast.InterpolatedString:
+		p.write(`"`)
+		for i, part := range exp.Parts {
+			if i%2 == 0 {
+				p.write(ast.Escape(part.(*ast.StringLiteral).Value))
+				continue
+			}
+			p.write("${")
+			p.bracketed(func() { p.expression(part, parser.LOWEST) })
+			p.write("}")
+		}
+		p.write(`"`)
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		p.expression(exp.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := operators[exp.Operator]
+		p.expression(exp.Left, prec)
+		p.write(" " + exp.Operator + " ")
+		p.expression(exp.Right, prec+1)
+	case *ast.PropagateExpression:
+		if call, ok := exp.Value.(*ast.CallExpression); ok && call.Pipe {
+			p.print(call)
+		} else {
+			p.expression(exp.Value, parser.CALL)
+		}
+		p.write("?")
+	case *ast.CallExpression:
+		args := exp.Arguments
+		if exp.Pipe {
+			p.expression(args[0], parser.PIPELINE)
+			p.write(" |> ")
+			args = args[1:]
+		}
+		p.expression(exp.Function, parser.CALL)
+		p.write("(")
+		p.expressions(at(exp.Token), args)
+		p.write(")")
+	case *ast.SpreadExpression:
+		p.write("...")
+		p.expression(exp.Value, parser.LOWEST)
+	case *ast.NamedArgument:
+		p.write(exp.Name.Value + ": ")
+		p.expression(exp.Value, parser.LOWEST)
+	case *ast.IndexExpression:
+		p.expression(exp.Left, parser.CALL)
+		p.write("[")
+		p.bracketed(func() { p.expression(exp.Index, parser.LOWEST) })
+		p.write("]")
+	case *ast.MemberExpression:
+		p.expression(exp.Object, parser.CALL)
+		p.write("." + exp.Property.Value)
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.expressions(at(exp.Token), exp.Elements)
+		p.write("]")
+	case *ast.HashLiteral:
+		p.pairs(at(exp.Token), exp.Pairs)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.bracketed(func() { p.expression(exp.Condition, parser.LOWEST) })
+		p.write(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.write(" else ")
+			if elseIf := elseIf(exp.Alternative); elseIf != nil {
+				p.print(elseIf)
+			} else {
+				p.block(exp.Alternative)
+			}
+		}
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(exp.Block)
+		if exp.Catch != nil {
+			p.write(" catch (" + exp.Param.Value + ") ")
+			p.block(exp.Catch)
+		}
+		if exp.Finally != nil {
+			p.write(" finally ")
+			p.block(exp.Finally)
+		}
+	case *ast.ForExpression:
+		p.write("for (")
+		p.expression(exp.Pattern, parser.LOWEST)
+		p.write(" in ")
+		p.bracketed(func() { p.expression(exp.Iterable, parser.LOWEST) })
+		p.write(") ")
+		p.block(exp.Body)
+	case *ast.YieldExpression:
+		p.write("yield")
+		if exp.Value != nil {
+			p.write(" ")
+			p.expression(exp.Value, parser.LOWEST)
+		}
+	case *ast.SpawnExpression:
+		p.write("spawn ")
+		p.expression(exp.Call, parser.PREFIX)
+	case *ast.FunctionLiteral:
+		p.function(exp)
+	case *ast.MacroLiteral:
+		p.write("macro")
+		p.names(exp.Parameters, "(", ")", "()")
+		p.write(" ")
+		p.block(exp.Body)
+	case *ast.DefaultParameter:
+		p.expression(exp.Parameter, parser.LOWEST)
+		p.write(" = ")
+		p.expression(exp.Default, parser.LOWEST)
+	case *ast.MatchExpression:
+		p.match(exp)
+	case *ast.SelectExpression:
+		p.selectExpression(exp)
+	case *ast.BlockStatement:
+		p.block(exp)
+	case *ast.ArrayPattern:
+		n := len(exp.Elements)
+		if exp.Rest != nil {
+			n++
+		}
+		p.write("[")
+		p.items(at(exp.Token), n, func(i int) {
+			if i < len(exp.Elements) {
+				p.expression(exp.Elements[i], parser.LOWEST)
+			} else {
+				p.write("..." + exp.Rest.Value)
+			}
+		})
+		p.write("]")
+	case *ast.HashPattern:
+		p.pairs(at(exp.Token), exp.Pairs)
+	case *ast.StructPattern:
+		p.write(exp.Name.Value)
+		p.pairs(at(exp.Fields.Token), exp.Fields.Pairs)
+	case *ast.VariantPattern:
+		if exp.Enum != nil {
+			p.write(exp.Enum.Value + ".")
+		}
+		p.write(exp.Name.Value)
+		if exp.Arguments != nil {
+			p.write("(")
+			p.expressions(p.next(at(exp.Name.Token)), exp.Arguments)
+			p.write(")")
+		}
+	}
+}
+
+// pairs prints the pairs of a hash literal or pattern in the braces at open.
+// A pattern pair binding its key to the same name is printed as the name
+// alone.
+func (p *printer) pairs(open pos, pairs []ast.HashPair) {
+	p.write("{")
+	p.items(open, len(pairs), func(i int) {
+		pair := pairs[i]
+		p.expression(pair.Key, parser.LOWEST)
+		if pair.Value != pair.Key {
+			p.write(": ")
+			p.expression(pair.Value, parser.LOWEST)
+		}
+	})
+	p.write("}")
+}
+
+// elseIf returns the if expression an alternative written as else if holds,
+// or nil.
+func elseIf(alternative *ast.BlockStatement) *ast.IfExpression {
+	if alternative.Token.Type != token.IF || len(alternative.Statements) != 1 {
+		return nil
+	}
+
+	stmt, ok := alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil
+	}
+
+	ie, _ := stmt.Expression.(*ast.IfExpression)
+	return ie
+}
+
+func isIdentifier(exp ast.Expression) bool {
+	_, ok := exp.(*ast.Identifier)
+	return ok
+}
+
+// hasExpressionBody reports whether fn is an arrow function whose body is
+// an expression rather than a block.
+func hasExpressionBody(fn *ast.FunctionLiteral) bool {
+	return fn.Arrow && fn.Body.Token.Type != token.LBRACE && len(fn.Body.Statements) == 1
+}
+
+func (p *printer) function(fn *ast.FunctionLiteral) {
+	params := fn.Parameters
+	if fn.Rest != nil {
+		params = append(params[:len(params):len(params)], &ast.SpreadExpression{Value: fn.Rest})
+	}
+
+	if fn.Arrow {
+		if len(params) == 1 && isIdentifier(params[0]) {
+			p.print(params[0])
+		} else {
+			p.write("(")
+			p.expressions(p.find(at(fn.Token), token.LPAREN), params)
+			p.write(")")
+		}
+		p.write(" => ")
+
+		if hasExpressionBody(fn) {
+			p.body(fn.Body.Statements[0].(*ast.ExpressionStatement).Expression)
+		} else {
+			p.block(fn.Body)
+		}
+		return
+	}
+
+	p.write("fn")
+	if fn.Generator {
+		p.write("*")
+	}
+	if fn.Name != nil {
+		p.write(" " + fn.Name.Value)
+	}
+	p.write("(")
+	p.expressions(p.find(at(fn.Token), token.LPAREN), params)
+	p.write(") ")
+	p.block(fn.Body)
+}
+
+// body prints the body following the => of an arrow function, match arm or
+// select case. The parser takes a brace there for a block, so other
+// expressions starting with one go in parentheses.
+func (p *printer) body(exp ast.Expression) {
+	if block, ok := exp.(*ast.BlockStatement); ok {
+		p.block(block)
+		return
+	}
+
+	if strings.HasPrefix(p.render(func(q *printer) { q.expression(exp, parser.LOWEST) }), "{") {
+		p.write("(")
+		p.bracketed(func() { p.expression(exp, parser.LOWEST) })
+		p.write(")")
+		return
+	}
+
+	p.expression(exp, parser.LOWEST)
+}
+
+func (p *printer) match(exp *ast.MatchExpression) {
+	p.write("match (")
+	p.bracketed(func() { p.expression(exp.Subject, parser.LOWEST) })
+	p.write(") ")
+
+	open := p.next(p.closing[p.next(at(exp.Token))])
+
+	starts := make([]pos, len(exp.Arms))
+	for i, arm := range exp.Arms {
+		starts[i] = at(arm.Token)
+	}
+
+	p.arms(open, starts, func(i int) {
+		arm := exp.Arms[i]
+
+		p.expression(arm.Pattern, parser.LOWEST)
+		if arm.Guard != nil {
+			p.write(" if ")
+			noArrow := p.noArrow
+			p.noArrow = true
+			p.expression(arm.Guard, parser.LOWEST)
+			p.noArrow = noArrow
+		}
+		p.write(" => ")
+		p.bracketed(func() { p.body(arm.Body) })
+		p.write(",")
+	})
+}
+
+func (p *printer) selectExpression(exp *ast.SelectExpression) {
+	p.write("select ")
+
+	open := p.next(at(exp.Token))
+
+	starts := make([]pos, len(exp.Cases))
+	for i, c := range exp.Cases {
+		starts[i] = at(c.Token)
+	}
+
+	p.arms(open, starts, func(i int) {
+		c := exp.Cases[i]
+
+		if c.Operation == nil {
+			p.write("_")
+		} else {
+			p.print(c.Operation)
+		}
+		if c.Name != nil {
+			p.write(" as " + c.Name.Value)
+		}
+		p.write(" => ")
+		p.bracketed(func() { p.body(c.Body) })
+		p.write(",")
+	})
+}
+
+// arms prints the arms of a match or the cases of a select in the braces
+// at open, one per line.
+func (p *printer) arms(open pos, starts []pos, print func(i int)) {
+	close := p.closing[open]
+
+	if len(starts) == 0 && !p.hasComments(open, close) {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	p.newline()
+	p.list(open, close, starts, print)
+	p.indent--
+	p.newline()
+	p.write("}")
+}
+
+// quote returns s as a string literal.
+func quote(s string) string {
+	return `"` + ast.Escape(s) + `"`
+}
@@ -1,24 +1,71 @@
 package lexer
 
-import "arkham/token"
+import (
+	"arkham/token"
+	"strings"
+)
 
 type Lexer struct {
 	input        string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+
+	// While inside a string interpolation, braces counts the unmatched '{'
+	// so that the '}' closing the interpolation can be told apart.
+	// interpolations saves the count of each enclosing interpolation and is
+	// only ever grown by copying, so a Lexer can be copied to scan ahead.
+	braces         int
+	interpolations []int
+
+	comments bool // whether comments are returned as tokens
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// NewWithComments returns a Lexer that returns each // comment as a COMMENT
+// token rather than skipping it.
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.comments = true
+	return l
+}
+
 func (l *Lexer) NextToken() token.Token {
+	l.skipWhitespace()
+	for l.ch == '/' && l.peekChar() == '/' && !l.comments {
+		l.readComment()
+		l.skipWhitespace()
+	}
+
+	line, column := l.line, l.column
+
 	var tok token.Token
+	if l.ch == '/' && l.peekChar() == '/' {
+		tok = token.Token{Type: token.COMMENT, Literal: l.readComment()}
+	} else {
+		tok = l.readToken()
+	}
+	tok.Line = line
+	tok.Column = column
 
-	l.skipWhitespace()
+	return tok
+}
+
+// Position returns the line and column just past the last token returned,
+// where its span ends.
+func (l *Lexer) Position() (line, column int) {
+	return l.line, l.column
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -28,6 +75,12 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			// Checking for a =>
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -45,6 +98,18 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '|':
+		// Checking for a |>
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.PIPE, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '?':
+		tok = newToken(token.QUESTION, l.ch)
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
@@ -57,17 +122,44 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '.':
+		// Checking for a ...
+		if strings.HasPrefix(l.input[l.position:], "...") {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '{':
+		if len(l.interpolations) > 0 {
+			l.braces++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		if depth := len(l.interpolations); depth > 0 && l.braces == 0 {
+			// Closes an interpolation, the enclosing string continues
+			l.braces = l.interpolations[depth-1]
+			l.interpolations = l.interpolations[:depth-1]
+			tok = l.readStringToken(token.STRING_END, token.STRING_MIDDLE)
+		} else {
+			if depth > 0 {
+				l.braces--
+			}
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readStringToken(token.STRING, token.STRING_START)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -95,7 +187,23 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// readComment reads a comment up to the end of the line.
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	return strings.TrimRight(l.input[position:l.position], " \t\r")
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -106,17 +214,59 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
-func (l *Lexer) readString() string {
-	// TODO: add support for escaping characters \n or \"
-	position := l.position + 1
+// readStringToken reads the string contents following the current char. It
+// returns a token of type end when the string closes and of type interpolated
+// when a ${ starts an embedded expression.
+func (l *Lexer) readStringToken(end, interpolated token.TokenType) token.Token {
+	literal, isInterpolated := l.readString()
+
+	if isInterpolated {
+		depth := len(l.interpolations)
+		l.interpolations = append(l.interpolations[:depth:depth], l.braces)
+		l.braces = 0
+		return token.Token{Type: interpolated, Literal: literal}
+	}
+
+	return token.Token{Type: end, Literal: literal}
+}
+
+// readString reads up to the closing quote or the next ${, resolving escape
+// sequences, and reports whether it stopped at a ${.
+func (l *Lexer) readString() (string, bool) {
+	var out strings.Builder
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+
+		switch {
+		case l.ch == '"' || l.ch == 0:
+			return out.String(), false
+		case l.ch == '$' && l.peekChar() == '{':
+			l.readChar()
+			return out.String(), true
+		case l.ch == '\\':
+			l.readChar()
+			if l.ch == 0 {
+				return out.String(), false
+			}
+			out.WriteByte(unescape(l.ch))
+		default:
+			out.WriteByte(l.ch)
 		}
 	}
+}
 
-	return l.input[position:l.position]
+func unescape(ch byte) byte {
+	switch ch {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	default:
+		return ch
+	}
 }
 
 func (l *Lexer) peekChar() byte {
@@ -28,7 +28,16 @@ func TestNextToken(t *testing.T) {
 	10 == 10;
 	10 != 9;
 	"foobar"
-	"foo bar"`
+	"foo bar"
+	match (x) { _ => 1 }
+	[1, ...x];
+	{"foo": "bar"}
+	xs |> f
+	f()?
+	a.b
+	yield for in
+	spawn select
+	macro`
 
 	tests := []struct {
 		index           int
@@ -110,7 +119,44 @@ func TestNextToken(t *testing.T) {
 		{72, token.SEMICOLON, ";"},
 		{73, token.STRING, "foobar"},
 		{74, token.STRING, "foo bar"},
-		{75, token.EOF, ""},
+		{75, token.MATCH, "match"},
+		{76, token.LPAREN, "("},
+		{77, token.IDENT, "x"},
+		{78, token.RPAREN, ")"},
+		{79, token.LBRACE, "{"},
+		{80, token.IDENT, "_"},
+		{81, token.ARROW, "=>"},
+		{82, token.INT, "1"},
+		{83, token.RBRACE, "}"},
+		{84, token.LBRACKET, "["},
+		{85, token.INT, "1"},
+		{86, token.COMMA, ","},
+		{87, token.ELLIPSIS, "..."},
+		{88, token.IDENT, "x"},
+		{89, token.RBRACKET, "]"},
+		{90, token.SEMICOLON, ";"},
+		{91, token.LBRACE, "{"},
+		{92, token.STRING, "foo"},
+		{93, token.COLON, ":"},
+		{94, token.STRING, "bar"},
+		{95, token.RBRACE, "}"},
+		{96, token.IDENT, "xs"},
+		{97, token.PIPE, "|>"},
+		{98, token.IDENT, "f"},
+		{99, token.IDENT, "f"},
+		{100, token.LPAREN, "("},
+		{101, token.RPAREN, ")"},
+		{102, token.QUESTION, "?"},
+		{103, token.IDENT, "a"},
+		{104, token.DOT, "."},
+		{105, token.IDENT, "b"},
+		{106, token.YIELD, "yield"},
+		{107, token.FOR, "for"},
+		{108, token.IN, "in"},
+		{109, token.SPAWN, "spawn"},
+		{110, token.SELECT, "select"},
+		{111, token.MACRO, "macro"},
+		{112, token.EOF, ""},
 	}
 	l := New(input)
 	for i, tt := range tests {
@@ -120,6 +166,160 @@ func TestNextToken(t *testing.T) {
 	}
 }
 
+func TestStringInterpolation(t *testing.T) {
+	input := `"Hello ${name}, you have ${count + 1} items"
+	"${f("x${y}")}"
+	"${ {"a": 1}["a"] }!"
+	"a\"b\\c\n\${d}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_START, "Hello "},
+		{token.IDENT, "name"},
+		{token.STRING_MIDDLE, ", you have "},
+		{token.IDENT, "count"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.STRING_END, " items"},
+		{token.STRING_START, ""},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.STRING_START, "x"},
+		{token.IDENT, "y"},
+		{token.STRING_END, ""},
+		{token.RPAREN, ")"},
+		{token.STRING_END, ""},
+		{token.STRING_START, ""},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.STRING_END, "!"},
+		{token.STRING, "a\"b\\c\n${d}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		require.Equalf(t, tt.expectedType, tok.Type, "Test[%d] tokentype wrong", i)
+		require.Equalf(t, tt.expectedLiteral, tok.Literal, "Test[%d]", i)
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  try {
+	"a${b}c"
+}`
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.TRY, 2, 3},
+		{token.LBRACE, 2, 7},
+		{token.STRING_START, 3, 2},
+		{token.IDENT, 3, 6},
+		{token.STRING_END, 3, 7},
+		{token.RBRACE, 4, 1},
+		{token.EOF, 4, 2},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		require.Equalf(t, tt.expectedType, tok.Type, "Test[%d] tokentype wrong", i)
+		assert.Equalf(t, tt.expectedLine, tok.Line, "Test[%d] line wrong", i)
+		assert.Equalf(t, tt.expectedColumn, tok.Column, "Test[%d] column wrong", i)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	input := `let abc = "x\n${y}z"
+}`
+
+	tests := []struct {
+		expectedType      token.TokenType
+		expectedEndLine   int
+		expectedEndColumn int
+	}{
+		{token.LET, 1, 4},
+		{token.IDENT, 1, 8},
+		{token.ASSIGN, 1, 10},
+		{token.STRING_START, 1, 17},
+		{token.IDENT, 1, 18},
+		{token.STRING_END, 1, 21},
+		{token.RBRACE, 2, 2},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		require.Equalf(t, tt.expectedType, tok.Type, "Test[%d] tokentype wrong", i)
+
+		line, column := l.Position()
+		assert.Equalf(t, tt.expectedEndLine, line, "Test[%d] end line wrong", i)
+		assert.Equalf(t, tt.expectedEndColumn, column, "Test[%d] end column wrong", i)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 10 / 2; // trailing  
+// one
+// two
+"// not a comment"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.COMMENT, "// leading", 1, 1},
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "x", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.INT, "10", 2, 9},
+		{token.SLASH, "/", 2, 12},
+		{token.INT, "2", 2, 14},
+		{token.SEMICOLON, ";", 2, 15},
+		{token.COMMENT, "// trailing", 2, 17},
+		{token.COMMENT, "// one", 3, 1},
+		{token.COMMENT, "// two", 4, 1},
+		{token.STRING, "// not a comment", 5, 1},
+		{token.EOF, "", 5, 19},
+	}
+
+	withComments := NewWithComments(input)
+	withoutComments := New(input)
+
+	for i, tt := range tests {
+		tok := withComments.NextToken()
+		require.Equalf(t, tt.expectedType, tok.Type, "Test[%d] tokentype wrong", i)
+		assert.Equalf(t, tt.expectedLiteral, tok.Literal, "Test[%d] literal wrong", i)
+		assert.Equalf(t, tt.expectedLine, tok.Line, "Test[%d] line wrong", i)
+		assert.Equalf(t, tt.expectedColumn, tok.Column, "Test[%d] column wrong", i)
+
+		if tt.expectedType != token.COMMENT {
+			assert.Equalf(t, tok, withoutComments.NextToken(), "Test[%d] skipping comments", i)
+		}
+	}
+}
+
 func TestIsLetter(t *testing.T) {
 	assert.Equal(t, true, isLetter('a'))
 	assert.Equal(t, true, isLetter('z'))
@@ -0,0 +1,430 @@
+package lsp
+
+import (
+	"arkham/ast"
+	"arkham/astjson"
+	"arkham/evaluator"
+	"arkham/lexer"
+	"arkham/object"
+	"arkham/parser"
+	"arkham/resolver"
+	"arkham/token"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// document is a text the client has open.
+type document struct {
+	uri         string
+	version     int
+	text        string
+	diagnostics []diagnostic
+
+	// The analysis of the last text that parsed, used by the features that
+	// need a tree so they keep working while an edit is incomplete. Nil
+	// when no text has parsed yet.
+	analysis *analysis
+}
+
+// update replaces the text of d and analyzes it.
+func (d *document) update(text string, version int) {
+	d.text, d.version = text, version
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+
+	d.diagnostics = []diagnostic{}
+	if errs := p.ErrorList(); len(errs) > 0 {
+		for _, err := range errs {
+			d.diagnostics = append(d.diagnostics, diagnostic{
+				Range:    tokenRange(err.Token, max(len(err.Token.Literal), 1)),
+				Severity: severityError,
+				Source:   "arkham",
+				Message:  err.Message,
+			})
+		}
+		return
+	}
+
+	a := analyze(program, text)
+	for _, problem := range a.problems {
+		severity := severityError
+		if problem.Severity == resolver.Warning {
+			severity = severityWarning
+		}
+		d.diagnostics = append(d.diagnostics, diagnostic{
+			Range:    tokenRange(problem.Token, len(problem.Token.Literal)),
+			Severity: severity,
+			Source:   "arkham",
+			Message:  problem.Message,
+		})
+	}
+	d.analysis = a
+}
+
+// analysis is what the server knows of a program that parsed.
+type analysis struct {
+	text         string
+	lines        []int // the offset each line starts at
+	program      *ast.Program
+	problems     []resolver.Diagnostic
+	spans        map[ast.Node]astjson.Span
+	declarations map[*ast.Identifier]*ast.Identifier
+	declarers    map[*ast.Identifier]ast.Node // the node declaring each variable
+	identifiers  []*ast.Identifier            // in source order
+}
+
+func analyze(program *ast.Program, text string) *analysis {
+	a := &analysis{
+		text:      text,
+		lines:     []int{0},
+		program:   program,
+		spans:     astjson.Spans(program, text),
+		declarers: map[*ast.Identifier]ast.Node{},
+	}
+	for i, ch := range text {
+		if ch == '\n' {
+			a.lines = append(a.lines, i+1)
+		}
+	}
+
+	a.problems, a.declarations = resolver.ResolveDeclarations(program, evaluator.Defined(object.NewEnvironment()))
+
+	seen := map[*ast.Identifier]bool{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			if !seen[n] {
+				seen[n] = true
+				a.identifiers = append(a.identifiers, n)
+			}
+		case *ast.LetStatement, *ast.FunctionLiteral, *ast.MatchArm, *ast.ForExpression,
+			*ast.TryExpression, *ast.SelectCase, *ast.ImportStatement, *ast.StructStatement,
+			*ast.EnumStatement:
+			a.declare(n)
+		}
+		return true
+	})
+
+	sort.SliceStable(a.identifiers, func(i, j int) bool {
+		x, y := a.identifiers[i].Token, a.identifiers[j].Token
+		return x.Line < y.Line || x.Line == y.Line && x.Column < y.Column
+	})
+
+	return a
+}
+
+// declare records n as the declarer of the variables it declares, which
+// are the identifiers directly in it, not in nested nodes declaring their
+// own, that the resolver maps to themselves.
+func (a *analysis) declare(n ast.Node) {
+	var parts []ast.Node
+	identifier := func(ident *ast.Identifier) {
+		if ident != nil {
+			parts = append(parts, ident)
+		}
+	}
+
+	switch n := n.(type) {
+	case *ast.LetStatement:
+		if n.Pattern != nil {
+			parts = append(parts, n.Pattern)
+		}
+		identifier(n.Name)
+	case *ast.FunctionLiteral:
+		identifier(n.Name)
+		identifier(n.Rest)
+		for _, param := range n.Parameters {
+			if dp, ok := param.(*ast.DefaultParameter); ok {
+				param = dp.Parameter
+			}
+			parts = append(parts, param)
+		}
+	case *ast.MatchArm:
+		parts = append(parts, n.Pattern)
+	case *ast.ForExpression:
+		parts = append(parts, n.Pattern)
+	case *ast.TryExpression:
+		identifier(n.Param)
+	case *ast.SelectCase:
+		identifier(n.Name)
+	case *ast.ImportStatement:
+		identifier(n.Name)
+	case *ast.StructStatement:
+		identifier(n.Name)
+	case *ast.EnumStatement:
+		identifier(n.Name)
+		for _, variant := range n.Variants {
+			identifier(variant.Name)
+		}
+	}
+
+	for _, part := range parts {
+		ast.Inspect(part, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Identifier); ok && a.declarations[ident] == ident {
+				a.declarers[ident] = n
+			}
+			return true
+		})
+	}
+}
+
+// identifierAt returns the identifier at pos, or nil. A position just past
+// an identifier is at it, as is the cursor after typing it.
+func (a *analysis) identifierAt(pos position) *ast.Identifier {
+	line, column := pos.Line+1, pos.Character+1
+	for _, ident := range a.identifiers {
+		tok := ident.Token
+		if tok.Line == line && tok.Column <= column && column <= tok.Column+len(ident.Value) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// declarationAt returns the identifier first declaring the variable at pos,
+// or nil when there is none.
+func (a *analysis) declarationAt(pos position) *ast.Identifier {
+	ident := a.identifierAt(pos)
+	if ident == nil {
+		return nil
+	}
+	return a.declarations[ident]
+}
+
+// references returns the identifiers declaring or referring to the variable
+// decl first declares, in source order.
+func (a *analysis) references(decl *ast.Identifier) []*ast.Identifier {
+	refs := []*ast.Identifier{}
+	for _, ident := range a.identifiers {
+		if a.declarations[ident] == decl {
+			refs = append(refs, ident)
+		}
+	}
+	return refs
+}
+
+// source returns the text of span.
+func (a *analysis) source(span astjson.Span) string {
+	from, to := a.offset(span.Start), a.offset(span.End)
+	if from > to {
+		return ""
+	}
+	return a.text[from:to]
+}
+
+func (a *analysis) offset(p astjson.Position) int {
+	if p.Line < 1 || p.Line > len(a.lines) {
+		return len(a.text)
+	}
+	return min(a.lines[p.Line-1]+p.Column-1, len(a.text))
+}
+
+func (a *analysis) rangeOf(node ast.Node) textRange {
+	span := a.spans[node]
+	return textRange{
+		Start: position{span.Start.Line - 1, span.Start.Column - 1},
+		End:   position{span.End.Line - 1, span.End.Column - 1},
+	}
+}
+
+// describe returns the markdown describing the variable decl declares: the
+// source declaring it, without any function body, and what it is.
+func (a *analysis) describe(decl *ast.Identifier) string {
+	declarer := a.declarers[decl]
+
+	var text, kind string
+	switch n := declarer.(type) {
+	case *ast.LetStatement:
+		kind = "variable"
+		text = a.firstLine(a.spans[n])
+		if fn, ok := n.Value.(*ast.FunctionLiteral); ok && n.Name == decl {
+			kind = "function"
+			text = a.signature(n, fn)
+		}
+	case *ast.FunctionLiteral:
+		kind = "parameter"
+		if n.Name == decl {
+			kind = "function"
+		}
+		text = a.signature(n, n)
+	case *ast.MatchArm:
+		kind, text = "match binding", a.firstLine(a.spans[n])
+	case *ast.ForExpression:
+		kind, text = "loop variable", a.firstLine(a.spans[n])
+	case *ast.TryExpression:
+		kind, text = "catch parameter", a.firstLine(a.spans[n])
+	case *ast.SelectCase:
+		kind, text = "received value", a.firstLine(a.spans[n])
+	case *ast.ImportStatement:
+		kind, text = "module", a.firstLine(a.spans[n])
+	case *ast.StructStatement:
+		kind, text = "struct", a.firstLine(a.spans[n])
+	case *ast.EnumStatement:
+		kind = "variant"
+		if n.Name == decl {
+			kind = "enum"
+		}
+		text = a.firstLine(a.spans[n])
+	default:
+		kind, text = "variable", decl.Value
+	}
+
+	return fmt.Sprintf("```arkham\n%s\n```\n%s declared on line %d", text, kind, decl.Token.Line)
+}
+
+// signature returns the source of node up to the body of fn.
+func (a *analysis) signature(node ast.Node, fn *ast.FunctionLiteral) string {
+	span := a.spans[node]
+	span.End = a.spans[fn.Body].Start
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(a.source(span)), "=>"))
+}
+
+func (a *analysis) firstLine(span astjson.Span) string {
+	text, _, _ := strings.Cut(a.source(span), "\n")
+	return strings.TrimSpace(text)
+}
+
+// symbols returns the symbols declared by stmts, with those declared in
+// the bodies of the functions among them as their children.
+func (a *analysis) symbols(stmts []ast.Statement) []documentSymbol {
+	symbols := []documentSymbol{}
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if stmt.Pattern != nil {
+				for _, ident := range a.declared(stmt.Pattern) {
+					symbols = append(symbols, a.symbol(ident, symbolVariable, stmt))
+				}
+				continue
+			}
+
+			symbol := a.symbol(stmt.Name, symbolVariable, stmt)
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				symbol.Kind = symbolFunction
+				symbol.Detail = a.signature(fn, fn)
+				symbol.Children = a.symbols(fn.Body.Statements)
+			}
+			symbols = append(symbols, symbol)
+		case *ast.ExpressionStatement:
+			fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+			if !ok || fn.Name == nil {
+				continue
+			}
+
+			symbol := a.symbol(fn.Name, symbolFunction, stmt)
+			symbol.Detail = a.signature(fn, fn)
+			symbol.Children = a.symbols(fn.Body.Statements)
+			symbols = append(symbols, symbol)
+		case *ast.ImportStatement:
+			symbol := a.symbol(stmt.Name, symbolModule, stmt)
+			symbol.Detail = stmt.Path.Value
+			symbols = append(symbols, symbol)
+		case *ast.StructStatement:
+			symbol := a.symbol(stmt.Name, symbolStruct, stmt)
+			for _, field := range stmt.Fields {
+				symbol.Children = append(symbol.Children, a.symbol(field, symbolField, field))
+			}
+			symbols = append(symbols, symbol)
+		case *ast.EnumStatement:
+			symbol := a.symbol(stmt.Name, symbolEnum, stmt)
+			for _, variant := range stmt.Variants {
+				symbol.Children = append(symbol.Children, a.symbol(variant.Name, symbolEnumMember, variant.Name))
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols
+}
+
+func (a *analysis) symbol(name *ast.Identifier, kind int, node ast.Node) documentSymbol {
+	return documentSymbol{
+		Name:           name.Value,
+		Kind:           kind,
+		Range:          a.rangeOf(node),
+		SelectionRange: identifierRange(name),
+	}
+}
+
+// declared returns the identifiers declaring variables in pattern.
+func (a *analysis) declared(pattern ast.Node) []*ast.Identifier {
+	idents := []*ast.Identifier{}
+	ast.Inspect(pattern, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok && a.declarations[ident] == ident && !contains(idents, ident) {
+			idents = append(idents, ident)
+		}
+		return true
+	})
+	return idents
+}
+
+func contains(idents []*ast.Identifier, ident *ast.Identifier) bool {
+	for _, i := range idents {
+		if i == ident {
+			return true
+		}
+	}
+	return false
+}
+
+// inScope returns the names of the variables in scope at pos: those at the
+// top level and those of the functions, match arms, loops, catch blocks and
+// select cases around pos.
+func (a *analysis) inScope(pos position) []string {
+	names := []string{}
+	for _, ident := range a.identifiers {
+		if a.declarations[ident] == ident && ident.Binding == nil {
+			names = append(names, ident.Value)
+		}
+	}
+
+	around := func(node ast.Node) bool {
+		span, ok := a.spans[node]
+		if !ok {
+			return false
+		}
+		p := astjson.Position{Line: pos.Line + 1, Column: pos.Character + 1}
+		return !before(p, span.Start) && !before(span.End, p)
+	}
+
+	ast.Inspect(a.program, func(n ast.Node) bool {
+		var scope *ast.Scope
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			scope = n.Scope
+		case *ast.MatchArm:
+			scope = n.Scope
+		case *ast.ForExpression:
+			scope = n.Scope
+		case *ast.SelectCase:
+			scope = n.Scope
+		case *ast.TryExpression:
+			if n.Catch != nil && around(n.Catch) {
+				scope = n.CatchScope
+			}
+		}
+		if scope != nil && around(n) {
+			names = append(names, scope.Names...)
+		}
+		return true
+	})
+
+	return names
+}
+
+func before(a, b astjson.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// tokenRange returns the range of the length bytes starting at tok.
+func tokenRange(tok token.Token, length int) textRange {
+	start := position{tok.Line - 1, tok.Column - 1}
+	return textRange{Start: start, End: position{start.Line, start.Character + length}}
+}
+
+func identifierRange(ident *ast.Identifier) textRange {
+	return tokenRange(ident.Token, len(ident.Value))
+}
@@ -0,0 +1,337 @@
+// Package lsp implements a Language Server Protocol server for Arkham. It
+// keeps the documents the client opens, publishes the problems the parser
+// and resolver find in them on every change, and answers requests for
+// definitions, references, hovers, document symbols, completions and
+// formatting.
+//
+// Documents are synced whole. Positions count bytes rather than UTF-16
+// code units, which agree for the ASCII source the lexer reads.
+package lsp
+
+import (
+	"arkham/evaluator"
+	"arkham/format"
+	"arkham/token"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Serve runs a server reading messages from r and writing to w until the
+// client sends exit. It fails when the client exits without shutting the
+// server down first, or the connection fails.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{out: w, documents: map[string]*document{}}
+	in := bufio.NewReader(r)
+
+	for {
+		msg, err := readMessage(in)
+		var syntax *syntaxError
+		if errors.As(err, &syntax) {
+			if err := s.respond(json.RawMessage("null"), nil, &responseError{parseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return errors.New("lsp: connection closed before exit")
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("lsp: exit before shutdown")
+			}
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+type server struct {
+	out         io.Writer
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// handle answers a request or acts on a notification. It only fails when
+// writing does.
+func (s *server) handle(msg *message) (err error) {
+	if msg.ID == nil {
+		return s.notified(msg.Method, msg.Params)
+	}
+
+	defer func() {
+		// A bug answering one request should not end the session
+		if r := recover(); r != nil {
+			err = s.respond(msg.ID, nil, &responseError{internalError, fmt.Sprint(r)})
+		}
+	}()
+
+	result, rerr := s.request(msg.Method, msg.Params)
+	return s.respond(msg.ID, result, rerr)
+}
+
+func (s *server) respond(id json.RawMessage, result interface{}, rerr *responseError) error {
+	msg := &message{ID: id, Error: rerr}
+	if rerr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = data
+	}
+	return writeMessage(s.out, msg)
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: data})
+}
+
+// notified acts on a notification. Unknown ones are ignored, as the
+// protocol asks.
+func (s *server) notified(method string, params json.RawMessage) error {
+	if !s.initialized {
+		return nil
+	}
+
+	switch method {
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if json.Unmarshal(params, &p) != nil {
+			return nil
+		}
+
+		doc := &document{uri: p.TextDocument.URI}
+		doc.update(p.TextDocument.Text, p.TextDocument.Version)
+		s.documents[doc.uri] = doc
+		return s.publish(doc)
+	case "textDocument/didChange":
+		var p didChangeParams
+		if json.Unmarshal(params, &p) != nil || len(p.ContentChanges) == 0 {
+			return nil
+		}
+
+		doc, ok := s.documents[p.TextDocument.URI]
+		if !ok {
+			return nil
+		}
+		doc.update(p.ContentChanges[len(p.ContentChanges)-1].Text, p.TextDocument.Version)
+		return s.publish(doc)
+	case "textDocument/didClose":
+		var p didCloseParams
+		if json.Unmarshal(params, &p) != nil {
+			return nil
+		}
+
+		delete(s.documents, p.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+	}
+
+	return nil
+}
+
+func (s *server) publish(doc *document) error {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     doc.version,
+		Diagnostics: doc.diagnostics,
+	})
+}
+
+// request answers a request.
+func (s *server) request(method string, params json.RawMessage) (interface{}, *responseError) {
+	switch {
+	case method == "initialize":
+		s.initialized = true
+		return initialize(), nil
+	case !s.initialized:
+		return nil, &responseError{serverNotInitialized, "server not initialized"}
+	case s.shutdown:
+		return nil, &responseError{invalidRequest, "server shut down"}
+	case method == "shutdown":
+		s.shutdown = true
+		return nil, nil
+	}
+
+	var handle func(s *server, doc *document, params json.RawMessage) (interface{}, *responseError)
+	switch method {
+	case "textDocument/definition":
+		handle = (*server).definition
+	case "textDocument/references":
+		handle = (*server).references
+	case "textDocument/hover":
+		handle = (*server).hover
+	case "textDocument/documentSymbol":
+		handle = (*server).documentSymbol
+	case "textDocument/completion":
+		handle = (*server).completion
+	case "textDocument/formatting":
+		handle = (*server).formatting
+	default:
+		return nil, &responseError{methodNotFound, "method not found: " + method}
+	}
+
+	var p textDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &responseError{invalidParams, err.Error()}
+	}
+
+	doc, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{invalidParams, "unknown document " + p.TextDocument.URI}
+	}
+
+	return handle(s, doc, params)
+}
+
+func initialize() initializeResult {
+	var result initializeResult
+	result.ServerInfo.Name = "arkham"
+	result.Capabilities = serverCapabilities{
+		TextDocumentSync:           syncFull,
+		HoverProvider:              true,
+		DefinitionProvider:         true,
+		ReferencesProvider:         true,
+		DocumentSymbolProvider:     true,
+		DocumentFormattingProvider: true,
+		CompletionProvider:         completionOptions{TriggerCharacters: []string{"."}},
+	}
+	return result
+}
+
+func positionParams(params json.RawMessage) (*textDocumentPositionParams, *responseError) {
+	var p textDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &responseError{invalidParams, err.Error()}
+	}
+	return &p, nil
+}
+
+func (s *server) definition(doc *document, params json.RawMessage) (interface{}, *responseError) {
+	p, rerr := positionParams(params)
+	if rerr != nil || doc.analysis == nil {
+		return nil, rerr
+	}
+
+	decl := doc.analysis.declarationAt(p.Position)
+	if decl == nil {
+		return nil, nil
+	}
+	return location{URI: doc.uri, Range: identifierRange(decl)}, nil
+}
+
+func (s *server) references(doc *document, params json.RawMessage) (interface{}, *responseError) {
+	var p referenceParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &responseError{invalidParams, err.Error()}
+	}
+
+	locations := []location{}
+	a := doc.analysis
+	if a == nil {
+		return locations, nil
+	}
+
+	decl := a.declarationAt(p.Position)
+	if decl == nil {
+		return locations, nil
+	}
+
+	for _, ref := range a.references(decl) {
+		if ref != decl || p.Context.IncludeDeclaration {
+			locations = append(locations, location{URI: doc.uri, Range: identifierRange(ref)})
+		}
+	}
+	return locations, nil
+}
+
+func (s *server) hover(doc *document, params json.RawMessage) (interface{}, *responseError) {
+	p, rerr := positionParams(params)
+	if rerr != nil || doc.analysis == nil {
+		return nil, rerr
+	}
+
+	a := doc.analysis
+	decl := a.declarationAt(p.Position)
+	if decl == nil {
+		return nil, nil
+	}
+
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: a.describe(decl)},
+		Range:    identifierRange(a.identifierAt(p.Position)),
+	}, nil
+}
+
+func (s *server) documentSymbol(doc *document, params json.RawMessage) (interface{}, *responseError) {
+	if doc.analysis == nil {
+		return []documentSymbol{}, nil
+	}
+	return doc.analysis.symbols(doc.analysis.program.Statements), nil
+}
+
+// completion offers the variables in scope, then the builtins, then the
+// keywords. The client filters them by what is typed.
+func (s *server) completion(doc *document, params json.RawMessage) (interface{}, *responseError) {
+	p, rerr := positionParams(params)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	items := []completionItem{}
+	seen := map[string]bool{}
+	add := func(label string, kind int, detail string) {
+		if !seen[label] {
+			seen[label] = true
+			items = append(items, completionItem{Label: label, Kind: kind, Detail: detail})
+		}
+	}
+
+	if doc.analysis != nil {
+		for _, name := range doc.analysis.inScope(p.Position) {
+			add(name, completionVariable, "")
+		}
+	}
+	for _, name := range evaluator.Builtins() {
+		add(name, completionFunction, "builtin")
+	}
+	for _, word := range token.Keywords() {
+		add(word, completionKeyword, "keyword")
+	}
+
+	return items, nil
+}
+
+// formatting replaces the whole text with its canonical format, if it
+// differs.
+func (s *server) formatting(doc *document, params json.RawMessage) (interface{}, *responseError) {
+	formatted, err := format.Source([]byte(doc.text))
+	if err != nil {
+		return nil, &responseError{requestFailed, err.Error()}
+	}
+
+	edits := []textEdit{}
+	if string(formatted) != doc.text {
+		lines := strings.Split(doc.text, "\n")
+		end := position{len(lines) - 1, len(lines[len(lines)-1])}
+		edits = append(edits, textEdit{Range: textRange{End: end}, NewText: string(formatted)})
+	}
+	return edits, nil
+}
@@ -0,0 +1,516 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client is a scripted client talking to a server run in process.
+type client struct {
+	t             *testing.T
+	out           io.WriteCloser // to the server
+	messages      chan *message  // from the server
+	notifications []*message     // received while awaiting a response
+	done          chan error     // what Serve returns
+	nextID        int
+}
+
+const uri = "file:///test.ark"
+
+// newClient starts a server and initializes it.
+func newClient(t *testing.T) *client {
+	c := start(t)
+
+	var result initializeResult
+	require.Nil(t, c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result))
+	c.notify("initialized", struct{}{})
+	return c
+}
+
+// start starts a server without initializing it.
+func start(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{t: t, out: clientOut, messages: make(chan *message, 100), done: make(chan error, 1)}
+
+	go func() {
+		c.done <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	go func() {
+		in := bufio.NewReader(clientIn)
+		for {
+			msg, err := readMessage(in)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+func (c *client) send(msg *message) {
+	require.NoError(c.t, writeMessage(c.out, msg))
+}
+
+func (c *client) notify(method string, params interface{}) {
+	data, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	c.send(&message{Method: method, Params: data})
+}
+
+// request sends a request and decodes its result into result, returning
+// the error the server answers with instead, if any.
+func (c *client) request(method string, params interface{}, result interface{}) *responseError {
+	c.nextID++
+	id, _ := json.Marshal(c.nextID)
+
+	data, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	c.send(&message{ID: id, Method: method, Params: data})
+
+	for {
+		msg := c.receive()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+
+		require.JSONEq(c.t, string(id), string(msg.ID), "response to another request")
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			require.NoError(c.t, json.Unmarshal(msg.Result, result))
+		}
+		return nil
+	}
+}
+
+func (c *client) receive() *message {
+	select {
+	case msg, ok := <-c.messages:
+		require.True(c.t, ok, "server closed the connection")
+		return msg
+	case <-time.After(5 * time.Second):
+		require.FailNow(c.t, "no message from the server")
+		return nil
+	}
+}
+
+// diagnostics returns the next diagnostics published.
+func (c *client) diagnostics() publishDiagnosticsParams {
+	var msg *message
+	if len(c.notifications) > 0 {
+		msg, c.notifications = c.notifications[0], c.notifications[1:]
+	} else {
+		msg = c.receive()
+	}
+
+	require.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+	var params publishDiagnosticsParams
+	require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+	return params
+}
+
+func (c *client) open(text string) publishDiagnosticsParams {
+	c.notify("textDocument/didOpen", didOpenParams{textDocumentItem{URI: uri, LanguageID: "arkham", Version: 1, Text: text}})
+	return c.diagnostics()
+}
+
+func (c *client) change(version int, text string) publishDiagnosticsParams {
+	c.notify("textDocument/didChange", didChangeParams{
+		TextDocument:   versionedTextDocumentIdentifier{URI: uri, Version: version},
+		ContentChanges: []contentChange{{Text: text}},
+	})
+	return c.diagnostics()
+}
+
+// exit shuts the server down and returns what Serve returned.
+func (c *client) exit() error {
+	require.Nil(c.t, c.request("shutdown", nil, nil))
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func (c *client) at(pos position) textDocumentPositionParams {
+	return textDocumentPositionParams{TextDocument: textDocumentIdentifier{uri}, Position: pos}
+}
+
+// at returns the position of the nth occurrence, from 1, of needle in text.
+func at(text, needle string, n int) position {
+	return positionOf(text, offsetOf(text, needle, n))
+}
+
+// rangeAt returns the range of the nth occurrence of needle in text.
+func rangeAt(text, needle string, n int) textRange {
+	offset := offsetOf(text, needle, n)
+	return textRange{positionOf(text, offset), positionOf(text, offset+len(needle))}
+}
+
+func offsetOf(text, needle string, n int) int {
+	offset := -1
+	for i := 0; i < n; i++ {
+		next := strings.Index(text[offset+1:], needle)
+		if next < 0 {
+			panic("no occurrence of " + needle)
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+func positionOf(text string, offset int) position {
+	line := strings.Count(text[:offset], "\n")
+	return position{line, offset - strings.LastIndex(text[:offset], "\n") - 1}
+}
+
+func TestInitialize(t *testing.T) {
+	c := start(t)
+
+	var result initializeResult
+	require.Nil(t, c.request("initialize", map[string]interface{}{}, &result))
+
+	assert.Equal(t, "arkham", result.ServerInfo.Name)
+	assert.Equal(t, syncFull, result.Capabilities.TextDocumentSync)
+	assert.True(t, result.Capabilities.HoverProvider)
+	assert.True(t, result.Capabilities.DefinitionProvider)
+	assert.True(t, result.Capabilities.ReferencesProvider)
+	assert.True(t, result.Capabilities.DocumentSymbolProvider)
+	assert.True(t, result.Capabilities.DocumentFormattingProvider)
+
+	assert.NoError(t, c.exit())
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+
+	text := "let f = fn() { let unused = 1; missing }"
+	published := c.open(text)
+	assert.Equal(t, uri, published.URI)
+	assert.Equal(t, 1, published.Version)
+	assert.Equal(t, []diagnostic{
+		{Range: rangeAt(text, "unused", 1), Severity: severityWarning, Source: "arkham", Message: "unused variable unused"},
+		{Range: rangeAt(text, "missing", 1), Severity: severityError, Source: "arkham", Message: "undefined variable missing"},
+	}, published.Diagnostics)
+
+	text = "let x = 1;\nlet = 2"
+	published = c.change(2, text)
+	assert.Equal(t, 2, published.Version)
+	require.NotEmpty(t, published.Diagnostics)
+	assert.Equal(t, diagnostic{
+		Range:    rangeAt(text, "=", 2),
+		Severity: severityError,
+		Source:   "arkham",
+		Message:  "expected next token to be IDENT, got = instead",
+	}, published.Diagnostics[0])
+
+	published = c.change(3, "let x = len([1]); x")
+	assert.Empty(t, published.Diagnostics)
+	assert.NotNil(t, published.Diagnostics)
+
+	c.notify("textDocument/didClose", didCloseParams{textDocumentIdentifier{uri}})
+	published = c.diagnostics()
+	assert.Equal(t, uri, published.URI)
+	assert.Empty(t, published.Diagnostics)
+
+	assert.NoError(t, c.exit())
+}
+
+const program = `let total = 0
+let add = fn(a, b = 1) {
+  let acc = a + b
+  acc
+}
+let result = add(total, 2)
+match (result) {
+  [head, ...tail] => head,
+  n => add(n, n),
+}
+struct Point { x, y }
+enum Shape { Circle(r), Dot }
+`
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	tests := []struct {
+		at       position
+		expected *textRange
+	}{
+		{at(program, "total", 2), ptr(rangeAt(program, "total", 1))},
+		{at(program, "add", 2), ptr(rangeAt(program, "add", 1))},
+		{at(program, "add", 3), ptr(rangeAt(program, "add", 1))},
+		{at(program, "a + b", 1), ptr(first(rangeAt(program, "a,", 1)))},
+		{at(program, "b\n", 1), ptr(rangeAt(program, "b", 1))},
+		{at(program, "acc\n", 1), ptr(rangeAt(program, "acc", 1))},
+		{at(program, "head,", 1), ptr(rangeAt(program, "head", 1))},
+		{at(program, "n)", 1), ptr(first(rangeAt(program, "n =>", 1)))},
+		// The cursor just past an identifier
+		{position{3, 5}, ptr(rangeAt(program, "acc", 1))},
+		{at(program, "add", 1), ptr(rangeAt(program, "add", 1))},
+		{at(program, "Point", 1), ptr(rangeAt(program, "Point", 1))},
+		{at(program, "= 0", 1), nil},
+		{at(program, "2)", 1), nil},
+	}
+
+	for _, tt := range tests {
+		var result *location
+		require.Nil(t, c.request("textDocument/definition", c.at(tt.at), &result))
+
+		if tt.expected == nil {
+			assert.Nil(t, result, "definition at %v", tt.at)
+			continue
+		}
+		require.NotNil(t, result, "definition at %v", tt.at)
+		assert.Equal(t, uri, result.URI)
+		assert.Equal(t, *tt.expected, result.Range, "definition at %v", tt.at)
+	}
+
+	assert.NoError(t, c.exit())
+}
+
+func TestReferences(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	references := func(pos position, includeDeclaration bool) []textRange {
+		params := referenceParams{textDocumentPositionParams: c.at(pos)}
+		params.Context.IncludeDeclaration = includeDeclaration
+
+		var result []location
+		require.Nil(t, c.request("textDocument/references", params, &result))
+
+		ranges := []textRange{}
+		for _, loc := range result {
+			assert.Equal(t, uri, loc.URI)
+			ranges = append(ranges, loc.Range)
+		}
+		return ranges
+	}
+
+	add := []textRange{rangeAt(program, "add", 1), rangeAt(program, "add", 2), rangeAt(program, "add", 3)}
+	assert.Equal(t, add, references(at(program, "add", 3), true))
+	assert.Equal(t, add[1:], references(at(program, "add", 1), false))
+
+	n := []textRange{first(rangeAt(program, "n =>", 1)), first(rangeAt(program, "n,", 1)), first(rangeAt(program, "n)", 1))}
+	assert.Equal(t, n, references(at(program, "n,", 1), true))
+
+	assert.Equal(t, []textRange{}, references(at(program, "match", 1), true))
+
+	assert.NoError(t, c.exit())
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	tests := []struct {
+		at       position
+		expected string
+	}{
+		{at(program, "total", 2), "```arkham\nlet total = 0\n```\nvariable declared on line 1"},
+		{at(program, "add", 2), "```arkham\nlet add = fn(a, b = 1)\n```\nfunction declared on line 2"},
+		{at(program, "a + b", 1), "```arkham\nfn(a, b = 1)\n```\nparameter declared on line 2"},
+		{at(program, "acc\n", 1), "```arkham\nlet acc = a + b\n```\nvariable declared on line 3"},
+		{at(program, "head,", 1), "```arkham\n[head, ...tail] => head\n```\nmatch binding declared on line 8"},
+		{at(program, "Circle", 1), "```arkham\nenum Shape { Circle(r), Dot }\n```\nvariant declared on line 12"},
+	}
+
+	for _, tt := range tests {
+		var result *hover
+		require.Nil(t, c.request("textDocument/hover", c.at(tt.at), &result))
+		require.NotNil(t, result, "hover at %v", tt.at)
+
+		assert.Equal(t, "markdown", result.Contents.Kind)
+		assert.Equal(t, tt.expected, result.Contents.Value, "hover at %v", tt.at)
+		assert.Equal(t, tt.at.Line, result.Range.Start.Line)
+	}
+
+	var result *hover
+	require.Nil(t, c.request("textDocument/hover", c.at(at(program, "match", 1)), &result))
+	assert.Nil(t, result)
+
+	assert.NoError(t, c.exit())
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	var result []documentSymbol
+	require.Nil(t, c.request("textDocument/documentSymbol", textDocumentParams{textDocumentIdentifier{uri}}, &result))
+
+	whole := func(from, to string) textRange {
+		return textRange{at(program, from, 1), rangeAt(program, to, 1).End}
+	}
+
+	assert.Equal(t, []documentSymbol{
+		{Name: "total", Kind: symbolVariable, Range: whole("let total", "= 0"), SelectionRange: rangeAt(program, "total", 1)},
+		{
+			Name: "add", Detail: "fn(a, b = 1)", Kind: symbolFunction, Range: whole("let add", "acc\n}"),
+			SelectionRange: rangeAt(program, "add", 1),
+			Children: []documentSymbol{
+				{Name: "acc", Kind: symbolVariable, Range: whole("let acc", "a + b"), SelectionRange: rangeAt(program, "acc", 1)},
+			},
+		},
+		{Name: "result", Kind: symbolVariable, Range: whole("let result", "total, 2)"), SelectionRange: rangeAt(program, "result", 1)},
+		{
+			Name: "Point", Kind: symbolStruct, Range: whole("struct", "y }"), SelectionRange: rangeAt(program, "Point", 1),
+			Children: []documentSymbol{
+				{Name: "x", Kind: symbolField, Range: first(rangeAt(program, "x,", 1)), SelectionRange: first(rangeAt(program, "x,", 1))},
+				{Name: "y", Kind: symbolField, Range: first(rangeAt(program, "y }", 1)), SelectionRange: first(rangeAt(program, "y }", 1))},
+			},
+		},
+		{
+			Name: "Shape", Kind: symbolEnum, Range: whole("enum", "Dot }"), SelectionRange: rangeAt(program, "Shape", 1),
+			Children: []documentSymbol{
+				{Name: "Circle", Kind: symbolEnumMember, Range: rangeAt(program, "Circle", 1), SelectionRange: rangeAt(program, "Circle", 1)},
+				{Name: "Dot", Kind: symbolEnumMember, Range: rangeAt(program, "Dot", 1), SelectionRange: rangeAt(program, "Dot", 1)},
+			},
+		},
+	}, result)
+
+	assert.NoError(t, c.exit())
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	labels := func(pos position) map[string]int {
+		var result []completionItem
+		require.Nil(t, c.request("textDocument/completion", c.at(pos), &result))
+
+		kinds := map[string]int{}
+		for _, item := range result {
+			kinds[item.Label] = item.Kind
+		}
+		return kinds
+	}
+
+	inside := labels(at(program, "acc\n", 1))
+	assert.Equal(t, completionVariable, inside["total"])
+	assert.Equal(t, completionVariable, inside["a"])
+	assert.Equal(t, completionVariable, inside["b"])
+	assert.Equal(t, completionVariable, inside["acc"])
+	assert.Equal(t, completionFunction, inside["len"])
+	assert.Equal(t, completionKeyword, inside["match"])
+	assert.NotContains(t, inside, "head")
+
+	arm := labels(at(program, "head,", 1))
+	assert.Contains(t, arm, "head")
+	assert.Contains(t, arm, "tail")
+	assert.NotContains(t, arm, "acc")
+	assert.NotContains(t, arm, "n")
+
+	top := labels(at(program, "struct", 1))
+	assert.Contains(t, top, "result")
+	assert.Contains(t, top, "Circle")
+	assert.NotContains(t, top, "a")
+
+	assert.NoError(t, c.exit())
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	c.open("let x=1\nlet y =  x")
+
+	format := func() ([]textEdit, *responseError) {
+		var edits []textEdit
+		rerr := c.request("textDocument/formatting", textDocumentParams{textDocumentIdentifier{uri}}, &edits)
+		return edits, rerr
+	}
+
+	edits, rerr := format()
+	require.Nil(t, rerr)
+	assert.Equal(t, []textEdit{{Range: textRange{End: position{1, 10}}, NewText: "let x = 1\nlet y = x\n"}}, edits)
+
+	c.change(2, "let x = 1\nlet y = x\n")
+	edits, rerr = format()
+	require.Nil(t, rerr)
+	assert.Empty(t, edits)
+
+	c.change(3, "let = 1")
+	_, rerr = format()
+	require.NotNil(t, rerr)
+	assert.Equal(t, requestFailed, rerr.Code)
+
+	assert.NoError(t, c.exit())
+}
+
+func TestStaleAnalysis(t *testing.T) {
+	c := newClient(t)
+	c.open("let x = 1\nx")
+
+	// An edit that does not parse leaves the last tree to navigate
+	c.change(2, "let x = 1\nx +")
+
+	var result *location
+	require.Nil(t, c.request("textDocument/definition", c.at(position{1, 0}), &result))
+	require.NotNil(t, result)
+	assert.Equal(t, textRange{position{0, 4}, position{0, 5}}, result.Range)
+
+	assert.NoError(t, c.exit())
+}
+
+func TestProtocolErrors(t *testing.T) {
+	c := start(t)
+
+	rerr := c.request("textDocument/hover", c.at(position{}), nil)
+	require.NotNil(t, rerr)
+	assert.Equal(t, serverNotInitialized, rerr.Code)
+
+	require.Nil(t, c.request("initialize", struct{}{}, nil))
+
+	rerr = c.request("workspace/unknown", struct{}{}, nil)
+	require.NotNil(t, rerr)
+	assert.Equal(t, methodNotFound, rerr.Code)
+
+	rerr = c.request("textDocument/hover", c.at(position{}), nil)
+	require.NotNil(t, rerr)
+	assert.Equal(t, invalidParams, rerr.Code)
+
+	// Unknown notifications are ignored
+	c.notify("$/cancelRequest", map[string]int{"id": 1})
+
+	c.notify("exit", nil)
+	assert.EqualError(t, <-c.done, "lsp: exit before shutdown")
+}
+
+func TestReadMessage(t *testing.T) {
+	body := `{"jsonrpc":"2.0","id":1,"method":"shutdown"}`
+	input := "Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: 44\r\n\r\n" + body
+
+	msg, err := readMessage(bufio.NewReader(strings.NewReader(input)))
+	require.NoError(t, err)
+	assert.Equal(t, "shutdown", msg.Method)
+	assert.Equal(t, json.RawMessage("1"), msg.ID)
+
+	_, err = readMessage(bufio.NewReader(strings.NewReader("\r\n{}")))
+	assert.EqualError(t, err, "lsp: message without Content-Length")
+}
+
+// first returns the range of the first character of r.
+func first(r textRange) textRange {
+	r.End = position{r.Start.Line, r.Start.Character + 1}
+	return r
+}
+
+func ptr(r textRange) *textRange {
+	return &r
+}
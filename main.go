@@ -1,15 +1,48 @@
 package main
 
 import (
+	"arkham/astjson"
+	"arkham/evaluator"
+	"arkham/format"
+	"arkham/lexer"
+	"arkham/lsp"
+	"arkham/object"
+	"arkham/parser"
 	"arkham/repl"
+	"arkham/resolver"
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
 )
 
-// TODO: token add line number and column number for better errors
 // TODO: unicode
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(formatFiles(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "lsp" {
+		if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == "ast" || os.Args[1] == "tokens") {
+		os.Exit(dump(os.Args[1], os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 {
+		os.Exit(run(os.Args[1]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -18,3 +51,182 @@ func main() {
 	fmt.Printf("Hello %s! This is the Arkham programming language!\n", user.Username)
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// run evaluates the script at name. Imports are resolved relative to the
+// importing script, then against the directories in ARKHAM_PATH.
+func run(name string) int {
+	script, err := rootPath(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var searchPath []string
+	for _, dir := range filepath.SplitList(os.Getenv("ARKHAM_PATH")) {
+		if dir, err := rootPath(dir); err == nil {
+			searchPath = append(searchPath, dir)
+		}
+	}
+
+	loader := evaluator.NewModuleLoader(os.DirFS("/"), searchPath...)
+	loader.SetWarningHandler(func(module string, warning resolver.Diagnostic) {
+		fmt.Fprintf(os.Stderr, "warning: /%s:%s\n", module, warning)
+	})
+	result := loader.Run(script, object.NewEnvironment())
+	if result != nil && result.Type() == object.ERROR_OBJ {
+		fmt.Fprintln(os.Stderr, result.Inspect())
+		return 1
+	}
+
+	return 0
+}
+
+// rootPath returns name as a path in the file system rooted at /.
+func rootPath(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimPrefix(filepath.ToSlash(abs), "/"), nil
+}
+
+// formatFiles implements `arkham fmt [-check] [files]`, which rewrites each
+// file in its canonical format. With -check it lists the files that are not
+// formatted instead, failing when there are any. Without files it formats
+// the standard input to the standard output.
+func formatFiles(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list the files that are not formatted, failing if any")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		formatted, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>: %s\n", err)
+			return 1
+		}
+
+		if *check {
+			if !bytes.Equal(src, formatted) {
+				fmt.Println("<stdin>")
+				return 1
+			}
+			return 0
+		}
+
+		os.Stdout.Write(formatted)
+		return 0
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		formatted, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			status = 1
+			continue
+		}
+
+		if bytes.Equal(src, formatted) {
+			continue
+		}
+
+		if *check {
+			fmt.Println(name)
+			status = 1
+			continue
+		}
+
+		info, err := os.Stat(name)
+		if err == nil {
+			err = os.WriteFile(name, formatted, info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// dump implements `arkham ast [-json] [file]` and `arkham tokens [-json]
+// [file]`, which print the syntax tree or the tokens of file, or of the
+// standard input without one. The tree prints as source and the tokens one
+// per line, unless -json asks for the encoding of package astjson.
+func dump(command string, args []string) int {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print JSON, as documented in package astjson")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var src []byte
+	var err error
+	name := "<stdin>"
+	switch flags.NArg() {
+	case 0:
+		src, err = io.ReadAll(os.Stdin)
+	case 1:
+		name = flags.Arg(0)
+		src, err = os.ReadFile(name)
+	default:
+		fmt.Fprintf(os.Stderr, "usage: arkham %s [-json] [file]\n", command)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var out interface{}
+	if command == "tokens" {
+		tokens := astjson.Tokens(string(src))
+		if !*asJSON {
+			for _, tok := range tokens {
+				fmt.Printf("%d:%d\t%s\t%q\n", tok.Span.Start.Line, tok.Span.Start.Column, tok.Type, tok.Literal)
+			}
+			return 0
+		}
+		out = tokens
+	} else {
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", name, msg)
+			}
+			return 1
+		}
+
+		if !*asJSON {
+			fmt.Println(program.String())
+			return 0
+		}
+		out = astjson.Encode(program, string(src))
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s\n", data)
+	return 0
+}
@@ -1,8 +1,36 @@
 package object
 
+import (
+	"fmt"
+	"sync"
+)
+
+// Environment holds the bindings of a scope. It is safe for concurrent use,
+// so spawned tasks can share the environments their closures captured and
+// concurrent evaluations can share a frozen base environment.
+//
+// The environment of a resolved scope is a frame: it holds the variables the
+// resolver found in slots, indexed by their position in names, and any
+// other bindings by name in store.
 type Environment struct {
+	mu    sync.RWMutex // guards the fields below
 	store map[string]Object
 	outer *Environment
+
+	names []string // shared by the frames of a scope, never modified
+	slots []Object // nil until the variable is bound
+
+	depth int // the number of function calls in progress, never modified once shared
+
+	// shared is set while store is shared with a snapshot, so store must be
+	// copied before it is written. frozen rejects all writes.
+	shared bool
+	frozen bool
+
+	importer Importer
+	path     string // the module evaluated in this environment
+
+	yielder Yielder // the generator whose body is evaluated in this environment
 }
 
 func NewEnvironment() *Environment {
@@ -13,18 +41,256 @@ func NewEnvironment() *Environment {
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.depth = outer.depth
 	return env
 }
 
+// NewFrameEnvironment returns an environment enclosed by outer with a slot
+// for each of names.
+func NewFrameEnvironment(outer *Environment, names []string) *Environment {
+	return &Environment{outer: outer, names: names, slots: make([]Object, len(names)), depth: outer.depth}
+}
+
+// CallDepth returns the number of function calls in progress when code is
+// evaluated in e. Environments enclosed by e start with the same depth.
+func (e *Environment) CallDepth() int {
+	return e.depth
+}
+
+// SetCallDepth sets the call depth of e, which must not be shared yet, e.g.
+// the environment of a function being called.
+func (e *Environment) SetCallDepth(depth int) {
+	e.depth = depth
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
+	obj, ok := e.local(name)
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 
+// Set binds name to val in e and returns val. It returns an *Error instead,
+// binding nothing, when e is frozen.
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.frozen {
+		return &Error{Message: fmt.Sprintf("cannot bind %s in a frozen environment", name), Kind: RUNTIME_ERROR}
+	}
+
+	e.unshare()
+
+	if slot := e.slot(name); slot >= 0 {
+		e.slots[slot] = val
+		return val
+	}
+
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Lookup returns the variable in slot of the frame depth scopes out from e,
+// or the binding name of that environment and its enclosing ones when slot
+// is -1. A variable whose slot is not bound yet is looked up by name in the
+// enclosing scopes, as it would be without slots.
+func (e *Environment) Lookup(depth, slot int, name string) (Object, bool) {
+	env := e
+	for ; depth > 0 && env.outer != nil; depth-- {
+		env = env.outer
+	}
+
+	if slot < 0 || slot >= len(env.slots) {
+		return env.Get(name)
+	}
+
+	env.mu.RLock()
+	obj := env.slots[slot]
+	env.mu.RUnlock()
+
+	if obj != nil {
+		return obj, true
+	}
+
+	if env.outer != nil {
+		return env.outer.Get(name)
+	}
+	return nil, false
+}
+
+// SetSlot binds the variable in slot of e to val and returns val, or an
+// *Error when e is frozen.
+func (e *Environment) SetSlot(slot int, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.frozen {
+		return &Error{Message: fmt.Sprintf("cannot bind %s in a frozen environment", e.names[slot]), Kind: RUNTIME_ERROR}
+	}
+
+	e.unshare()
+	e.slots[slot] = val
+	return val
+}
+
+// unshare copies the bindings of e that it shares with a snapshot, so they
+// can be written. It must be called with e.mu held.
+func (e *Environment) unshare() {
+	if !e.shared {
+		return
+	}
+
+	if e.store != nil {
+		store := make(map[string]Object, len(e.store)+1)
+		for k, v := range e.store {
+			store[k] = v
+		}
+		e.store = store
+	}
+	e.slots = append([]Object(nil), e.slots...)
+	e.shared = false
+}
+
+// slot returns the slot of the variable name in e, or -1.
+func (e *Environment) slot(name string) int {
+	for i, n := range e.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// local returns the binding name of e itself, ignoring enclosing scopes.
+func (e *Environment) local(name string) (Object, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if slot := e.slot(name); slot >= 0 && e.slots[slot] != nil {
+		return e.slots[slot], true
+	}
+
+	obj, ok := e.store[name]
+	return obj, ok
+}
+
+// Freeze makes e read-only: binding a name in e fails from then on. The
+// environments enclosing e are not affected.
+func (e *Environment) Freeze() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.frozen = true
+}
+
+// Frozen reports whether e is read-only.
+func (e *Environment) Frozen() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.frozen
+}
+
+// Snapshot returns a frozen copy of e and its enclosing environments, with
+// the bindings they hold now. The copy shares its bindings with e until e
+// is next written, so taking a snapshot is cheap. A frozen environment
+// enclosed only by frozen ones is its own snapshot.
+//
+// Functions bound in e keep the environment they were defined in, so they
+// see later bindings in e. Snapshot a frozen environment to avoid that.
+func (e *Environment) Snapshot() *Environment {
+	if e.frozenChain() {
+		return e
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// A frozen e is never written, so sharing its bindings is harmless
+	e.shared = true
+
+	snapshot := &Environment{
+		store:    e.store,
+		names:    e.names,
+		slots:    e.slots,
+		depth:    e.depth,
+		frozen:   true,
+		importer: e.importer,
+		path:     e.path,
+		yielder:  e.yielder,
+	}
+
+	if e.outer != nil {
+		snapshot.outer = e.outer.Snapshot()
+	}
+
+	return snapshot
+}
+
+// frozenChain reports whether e and all the environments enclosing it are
+// frozen.
+func (e *Environment) frozenChain() bool {
+	for env := e; env != nil; env = env.outer {
+		if !env.Frozen() {
+			return false
+		}
+	}
+	return true
+}
+
+// Fork returns a new environment enclosed by a snapshot of e. Bindings made
+// in the fork are not visible in e and bindings made in e afterwards are not
+// visible in the fork, so each fork of a frozen base is isolated from the
+// others.
+func (e *Environment) Fork() *Environment {
+	return NewEnclosedEnvironment(e.Snapshot())
+}
+
+// SetImporter makes imports evaluated in e, and environments enclosed by it,
+// load modules with importer relative to the module at path.
+func (e *Environment) SetImporter(importer Importer, path string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.importer = importer
+	e.path = path
+}
+
+// Importer returns the importer set on e or its nearest enclosing environment
+// and the path of the module it was set for.
+func (e *Environment) Importer() (Importer, string) {
+	e.mu.RLock()
+	importer, path := e.importer, e.path
+	e.mu.RUnlock()
+
+	if importer == nil && e.outer != nil {
+		return e.outer.Importer()
+	}
+	return importer, path
+}
+
+// SetYielder makes yields evaluated in e, and environments enclosed by it,
+// suspend the generator yielder.
+func (e *Environment) SetYielder(yielder Yielder) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.yielder = yielder
+}
+
+// Yielder returns the yielder set on e or its nearest enclosing environment.
+func (e *Environment) Yielder() Yielder {
+	e.mu.RLock()
+	yielder := e.yielder
+	e.mu.RUnlock()
+
+	if yielder == nil && e.outer != nil {
+		return e.outer.Yielder()
+	}
+	return yielder
+}
@@ -0,0 +1,51 @@
+package object
+
+// visited records the pairs of containers being compared, so comparing
+// values that contain themselves terminates.
+type visited map[[2]Object]bool
+
+// deepEqualer is implemented by objects whose equality depends on the objects
+// they contain.
+type deepEqualer interface {
+	deepEquals(other Object, seen visited) bool
+}
+
+// equals compares a container with other, starting a new cycle check.
+func equals(a deepEqualer, other Object) bool {
+	return deepEquals(a.(Object), other, visited{})
+}
+
+// deepEquals compares a and b. A pair already being compared further up is
+// assumed equal: if it differs, the comparison in progress finds it.
+func deepEquals(a, b Object, seen visited) bool {
+	if a == b {
+		return true
+	}
+
+	d, ok := a.(deepEqualer)
+	if !ok {
+		return a.Equals(b)
+	}
+
+	key := [2]Object{a, b}
+	if seen[key] {
+		return true
+	}
+	seen[key] = true
+
+	return d.deepEquals(b, seen)
+}
+
+func deepEqualsAll(a, b []Object, seen visited) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !deepEquals(a[i], b[i], seen) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"arkham/ast"
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"strings"
 )
 
@@ -17,11 +18,45 @@ const (
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	BUILTIN_OBJ      = "BUILTIN"
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"
+	RESULT_OBJ       = "RESULT"
+	MODULE_OBJ       = "MODULE"
+
+	STRUCT_OBJ          = "STRUCT"
+	STRUCT_INSTANCE_OBJ = "STRUCT_INSTANCE"
+	ENUM_OBJ            = "ENUM"
+	ENUM_VARIANT_OBJ    = "ENUM_VARIANT"
+	ENUM_VALUE_OBJ      = "ENUM_VALUE"
+
+	GENERATOR_OBJ = "GENERATOR"
+	TASK_OBJ      = "TASK"
+	CHANNEL_OBJ   = "CHANNEL"
+
+	QUOTE_OBJ = "QUOTE"
+	MACRO_OBJ = "MACRO"
+)
+
+// Kinds of errors, exposed to scripts through the kind of a caught error.
+const (
+	RUNTIME_ERROR  = "RuntimeError"
+	TYPE_ERROR     = "TypeError"
+	NAME_ERROR     = "NameError"
+	ARGUMENT_ERROR = "ArgumentError"
+	PATTERN_ERROR  = "PatternError"
+	IMPORT_ERROR   = "ImportError"
+	USER_ERROR     = "Error"
 )
 
 type Object interface {
 	Type() ObjectType
 	Inspect() string
+	// Equals reports whether the object equals other: by value for scalars,
+	// element-wise for collections, structs and enum values, and by identity
+	// for functions, types and modules.
+	Equals(other Object) bool
 }
 
 type Integer struct {
@@ -30,6 +65,10 @@ type Integer struct {
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) Equals(other Object) bool {
+	o, ok := other.(*Integer)
+	return ok && i.Value == o.Value
+}
 
 type String struct {
 	Value string
@@ -37,6 +76,10 @@ type String struct {
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
+func (s *String) Equals(other Object) bool {
+	o, ok := other.(*String)
+	return ok && s.Value == o.Value
+}
 
 type Boolean struct {
 	Value bool
@@ -44,30 +87,261 @@ type Boolean struct {
 
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+func (b *Boolean) Equals(other Object) bool {
+	o, ok := other.(*Boolean)
+	return ok && b.Value == o.Value
+}
 
 type Null struct{}
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 func (n *Null) Inspect() string  { return "null" }
+func (n *Null) Equals(other Object) bool {
+	_, ok := other.(*Null)
+	return ok
+}
 
 type ReturnValue struct {
 	Value Object
 }
 
-func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
-func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+func (rv *ReturnValue) Type() ObjectType         { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string          { return rv.Value.Inspect() }
+func (rv *ReturnValue) Equals(other Object) bool { return equals(rv, other) }
+func (rv *ReturnValue) deepEquals(other Object, seen visited) bool {
+	o, ok := other.(*ReturnValue)
+	return ok && deepEquals(rv.Value, o.Value, seen)
+}
 
+// Error is a raised error. It unwinds evaluation until it is caught by a
+// try expression or reaches the top of the program.
 type Error struct {
 	Message string
+	Kind    string
+	Trace   []string // call frames the error unwound through, innermost first
+	Value   Object   // the thrown value, nil for errors not raised by throw
+}
+
+func (e *Error) Type() ObjectType         { return ERROR_OBJ }
+func (e *Error) Inspect() string          { return "ERROR: " + e.Message }
+func (e *Error) Equals(other Object) bool { return equals(e, other) }
+func (e *Error) deepEquals(other Object, seen visited) bool {
+	o, ok := other.(*Error)
+	if !ok || e.Message != o.Message || e.Kind != o.Kind {
+		return false
+	}
+
+	if e.Value == nil || o.Value == nil {
+		return e.Value == o.Value
+	}
+
+	return deepEquals(e.Value, o.Value, seen)
+}
+
+// ErrorValue is a caught error bound by a catch clause. Unlike Error it is an
+// ordinary value and does not unwind evaluation.
+type ErrorValue struct {
+	Error *Error
+}
+
+func (ev *ErrorValue) Type() ObjectType         { return ERROR_VALUE_OBJ }
+func (ev *ErrorValue) Inspect() string          { return ev.Error.Kind + ": " + ev.Error.Message }
+func (ev *ErrorValue) Equals(other Object) bool { return equals(ev, other) }
+func (ev *ErrorValue) deepEquals(other Object, seen visited) bool {
+	o, ok := other.(*ErrorValue)
+	return ok && deepEquals(ev.Error, o.Error, seen)
+}
+
+// Result is an ok or err value created by the ok and err builtins. Unlike
+// Error it is an ordinary value; the ? operator unwraps it.
+type Result struct {
+	Ok    bool
+	Value Object
+}
+
+func (r *Result) Type() ObjectType { return RESULT_OBJ }
+func (r *Result) Inspect() string {
+	if r.Ok {
+		return "ok(" + r.Value.Inspect() + ")"
+	}
+	return "err(" + r.Value.Inspect() + ")"
+}
+func (r *Result) Equals(other Object) bool { return equals(r, other) }
+func (r *Result) deepEquals(other Object, seen visited) bool {
+	o, ok := other.(*Result)
+	return ok && r.Ok == o.Ok && deepEquals(r.Value, o.Value, seen)
+}
+
+// Module is an evaluated module. Its exports are the top-level bindings of
+// Env whose names do not start with an underscore.
+type Module struct {
+	Path string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType         { return MODULE_OBJ }
+func (m *Module) Inspect() string          { return "<module " + m.Path + ">" }
+func (m *Module) Equals(other Object) bool { return m == other }
+
+// Export returns the exported binding name.
+func (m *Module) Export(name string) (Object, bool) {
+	if strings.HasPrefix(name, "_") {
+		return nil, false
+	}
+
+	return m.Env.local(name)
+}
+
+// StructType is a struct declared with struct Name { fields }. Calling it
+// constructs a StructInstance.
+type StructType struct {
+	Name   string
+	Fields []string
 }
 
-func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (st *StructType) Type() ObjectType { return STRUCT_OBJ }
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+func (st *StructType) Equals(other Object) bool { return st == other }
+
+// FieldIndex returns the position of the field name, or -1.
+func (st *StructType) FieldIndex(name string) int {
+	for i, field := range st.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// StructInstance is a value of a struct type. Fields holds its field values
+// in the order of Struct.Fields.
+type StructInstance struct {
+	Struct *StructType
+	Fields []Object
+}
+
+func (si *StructInstance) Type() ObjectType { return STRUCT_INSTANCE_OBJ }
+func (si *StructInstance) Inspect() string {
+	fields := []string{}
+	for i, name := range si.Struct.Fields {
+		fields = append(fields, name+": "+si.Fields[i].Inspect())
+	}
+
+	return si.Struct.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+func (si *StructInstance) Equals(other Object) bool { return equals(si, other) }
+func (si *StructInstance) deepEquals(other Object, seen visited) bool {
+	o, ok := other.(*StructInstance)
+	return ok && si.Struct == o.Struct && deepEqualsAll(si.Fields, o.Fields, seen)
+}
+
+// Get returns the value of the field name.
+func (si *StructInstance) Get(name string) (Object, bool) {
+	if i := si.Struct.FieldIndex(name); i >= 0 {
+		return si.Fields[i], true
+	}
+	return nil, false
+}
+
+// EnumType is an enum declared with enum Name { variants }.
+type EnumType struct {
+	Name     string
+	Variants []*EnumVariant
+}
+
+func (et *EnumType) Type() ObjectType { return ENUM_OBJ }
+func (et *EnumType) Inspect() string {
+	variants := []string{}
+	for _, variant := range et.Variants {
+		variants = append(variants, variant.declaration())
+	}
+
+	return "enum " + et.Name + " { " + strings.Join(variants, ", ") + " }"
+}
+func (et *EnumType) Equals(other Object) bool { return et == other }
+
+// Variant returns the variant name.
+func (et *EnumType) Variant(name string) (*EnumVariant, bool) {
+	for _, variant := range et.Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return nil, false
+}
+
+// EnumVariant is a variant of an enum. Calling a variant with fields
+// constructs an EnumValue; a unit variant has a single value.
+type EnumVariant struct {
+	Enum   *EnumType
+	Name   string
+	Fields []string
+	Unit   bool
+}
+
+func (ev *EnumVariant) Type() ObjectType         { return ENUM_VARIANT_OBJ }
+func (ev *EnumVariant) Inspect() string          { return ev.Enum.Name + "." + ev.declaration() }
+func (ev *EnumVariant) Equals(other Object) bool { return ev == other }
+
+func (ev *EnumVariant) declaration() string {
+	if ev.Unit {
+		return ev.Name
+	}
+	return ev.Name + "(" + strings.Join(ev.Fields, ", ") + ")"
+}
+
+// EnumValue is a value of an enum variant. Values holds its field values in
+// the order of Variant.Fields.
+type EnumValue struct {
+	Variant *EnumVariant
+	Values  []Object
+}
+
+func (ev *EnumValue) Type() ObjectType { return ENUM_VALUE_OBJ }
+func (ev *EnumValue) Inspect() string {
+	name := ev.Variant.Enum.Name + "." + ev.Variant.Name
+	if ev.Variant.Unit {
+		return name
+	}
+
+	values := []string{}
+	for _, value := range ev.Values {
+		values = append(values, value.Inspect())
+	}
+
+	return name + "(" + strings.Join(values, ", ") + ")"
+}
+func (ev *EnumValue) Equals(other Object) bool { return equals(ev, other) }
+func (ev *EnumValue) deepEquals(other Object, seen visited) bool {
+	o, ok := other.(*EnumValue)
+	return ok && ev.Variant == o.Variant && deepEqualsAll(ev.Values, o.Values, seen)
+}
+
+// Get returns the value of the field name.
+func (ev *EnumValue) Get(name string) (Object, bool) {
+	for i, field := range ev.Variant.Fields {
+		if field == name {
+			return ev.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// An Importer loads modules for import statements. Import returns the
+// *Module at path, imported from the module at from, or an *Error.
+type Importer interface {
+	Import(path, from string) Object
+}
 
 type Function struct {
-	Parameters []*ast.Identifier
+	Parameters []ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Generator  bool       // calling it returns a Generator running Body
+	Scope      *ast.Scope // the variables of Body, nil when not resolved
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -79,7 +353,14 @@ func (f *Function) Inspect() string {
 		params = append(params, p.String())
 	}
 
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
+
 	out.WriteString("fn")
+	if f.Generator {
+		out.WriteString("*")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -88,3 +369,232 @@ func (f *Function) Inspect() string {
 
 	return out.String()
 }
+func (f *Function) Equals(other Object) bool { return f == other }
+
+// Quote is unevaluated source, returned by quote(expression).
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType         { return QUOTE_OBJ }
+func (q *Quote) Inspect() string          { return "QUOTE(" + q.Node.String() + ")" }
+func (q *Quote) Equals(other Object) bool { return q == other }
+
+// Macro is a macro defined by a top-level let statement. Its Body evaluates
+// in an environment enclosed by Env binding Parameters to the quoted
+// arguments of a call.
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	return "macro(" + strings.Join(params, ", ") + ") {\n" + m.Body.String() + "\n}"
+}
+func (m *Macro) Equals(other Object) bool { return m == other }
+
+// A Coroutine runs the body of a generator function, suspending it at each
+// yield.
+type Coroutine interface {
+	// Resume runs the body until its next yield and returns the yielded
+	// value, or until it finishes and returns its result with done set.
+	// sent becomes the value of the yield the body is suspended at.
+	Resume(sent Object) (value Object, done bool)
+	// Close abandons the body, unwinding it from the yield it is suspended
+	// at. Closing a finished coroutine does nothing.
+	Close() *Error
+}
+
+// A Yielder suspends the generator evaluating a yield. Yield hands value to
+// the caller of next and returns the value sent back when the generator is
+// resumed.
+type Yielder interface {
+	Yield(value Object) Object
+}
+
+// Generator is the lazily evaluated sequence returned by calling a
+// generator function.
+type Generator struct {
+	Coroutine Coroutine
+}
+
+func (g *Generator) Type() ObjectType         { return GENERATOR_OBJ }
+func (g *Generator) Inspect() string          { return "<generator>" }
+func (g *Generator) Equals(other Object) bool { return g == other }
+
+// Task is a function call started by spawn on its own goroutine.
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+// NewTask returns a task that is running until Complete is called.
+func NewTask() *Task {
+	return &Task{done: make(chan struct{})}
+}
+
+func (t *Task) Type() ObjectType         { return TASK_OBJ }
+func (t *Task) Inspect() string          { return "<task>" }
+func (t *Task) Equals(other Object) bool { return t == other }
+
+// Complete finishes the task with result. It must be called exactly once.
+func (t *Task) Complete(result Object) {
+	t.result = result
+	close(t.done)
+}
+
+// Wait blocks until the task is complete and returns its result.
+func (t *Task) Wait() Object {
+	<-t.done
+	return t.result
+}
+
+// Channel passes values between tasks. Its capacity is that of Ch.
+type Channel struct {
+	Ch chan Object
+}
+
+func (c *Channel) Type() ObjectType         { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string          { return fmt.Sprintf("<channel %d/%d>", len(c.Ch), cap(c.Ch)) }
+func (c *Channel) Equals(other Object) bool { return c == other }
+
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType         { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string          { return "builtin function" }
+func (b *Builtin) Equals(other Object) bool { return b == other }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+func (a *Array) Equals(other Object) bool { return equals(a, other) }
+func (a *Array) deepEquals(other Object, seen visited) bool {
+	o, ok := other.(*Array)
+	return ok && deepEqualsAll(a.Elements, o.Elements, seen)
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by the objects that can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps hashable keys to values, remembering the order in which keys were
+// first inserted so that Inspect is stable.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+func (h *Hash) Equals(other Object) bool { return equals(h, other) }
+func (h *Hash) deepEquals(other Object, seen visited) bool {
+	o, ok := other.(*Hash)
+	if !ok || len(h.Pairs) != len(o.Pairs) {
+		return false
+	}
+
+	for key, pair := range h.Pairs {
+		otherPair, ok := o.Pairs[key]
+		if !ok || !deepEquals(pair.Value, otherPair.Value, seen) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Keys = append(h.Keys, hashKey)
+	}
+
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
@@ -0,0 +1,247 @@
+package object
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEquals(t *testing.T) {
+	one := &Integer{Value: 1}
+	fn := &Function{}
+
+	tests := []struct {
+		left     Object
+		right    Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&Boolean{Value: true}, &Boolean{Value: false}, false},
+		{&Null{}, &Null{}, true},
+		{&Array{Elements: []Object{one}}, &Array{Elements: []Object{&Integer{Value: 1}}}, true},
+		{&Array{Elements: []Object{one}}, &Array{Elements: []Object{}}, false},
+		{fn, fn, true},
+		{fn, &Function{}, false},
+		{&Result{Ok: true, Value: one}, &Result{Ok: false, Value: one}, false},
+		{&Error{Message: "x", Kind: USER_ERROR}, &Error{Message: "x", Kind: USER_ERROR}, true},
+		{&Error{Message: "x", Kind: USER_ERROR, Value: one}, &Error{Message: "x", Kind: USER_ERROR}, false},
+	}
+
+	for i, tt := range tests {
+		assert.Equalf(t, tt.expected, tt.left.Equals(tt.right), "test %d", i)
+		assert.Equalf(t, tt.expected, tt.right.Equals(tt.left), "test %d reversed", i)
+	}
+}
+
+func TestEqualsCycles(t *testing.T) {
+	// a = [1, a] and b = [1, b] unfold to the same infinite value
+	a := &Array{}
+	a.Elements = []Object{&Integer{Value: 1}, a}
+	b := &Array{}
+	b.Elements = []Object{&Integer{Value: 1}, b}
+
+	assert.True(t, a.Equals(b))
+	assert.True(t, a.Equals(a))
+
+	// c = [2, c] differs from a in every unfolding
+	c := &Array{}
+	c.Elements = []Object{&Integer{Value: 2}, c}
+
+	assert.False(t, a.Equals(c))
+
+	// h = {"self": h} and g = {"self": [g]} differ in shape
+	h := NewHash()
+	h.Set(&String{Value: "self"}, h)
+	g := NewHash()
+	g.Set(&String{Value: "self"}, &Array{Elements: []Object{g}})
+
+	assert.True(t, h.Equals(h))
+	assert.False(t, h.Equals(g))
+}
+
+func TestEnvironmentConcurrentAccess(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("shared", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("x%d", i)
+			for j := 0; j < 100; j++ {
+				env.Set(name, &Integer{Value: int64(j)})
+				env.Get(name)
+				env.Get("shared")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		val, ok := env.Get(fmt.Sprintf("x%d", i))
+		assert.True(t, ok)
+		assert.Equal(t, int64(99), val.(*Integer).Value)
+	}
+}
+
+func TestEnvironmentFreeze(t *testing.T) {
+	env := NewEnvironment()
+	env.Set("x", &Integer{Value: 1})
+	env.Freeze()
+
+	assert.True(t, env.Frozen())
+
+	err, ok := env.Set("y", &Integer{Value: 2}).(*Error)
+	if assert.True(t, ok) {
+		assert.Equal(t, "cannot bind y in a frozen environment", err.Message)
+	}
+
+	_, ok = env.Get("y")
+	assert.False(t, ok)
+
+	// Enclosed environments stay writable
+	child := NewEnclosedEnvironment(env)
+	assert.False(t, child.Frozen())
+	child.Set("y", &Integer{Value: 2})
+
+	_, ok = child.Get("x")
+	assert.True(t, ok)
+}
+
+func TestEnvironmentSnapshot(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+	env.Set("b", &Integer{Value: 2})
+
+	snapshot := env.Snapshot()
+	assert.True(t, snapshot.Frozen())
+
+	// Later writes to the original are not visible in the snapshot
+	env.Set("b", &Integer{Value: 20})
+	env.Set("c", &Integer{Value: 3})
+	outer.Set("a", &Integer{Value: 10})
+
+	b, _ := snapshot.Get("b")
+	assert.Equal(t, int64(2), b.(*Integer).Value)
+	a, _ := snapshot.Get("a")
+	assert.Equal(t, int64(1), a.(*Integer).Value)
+	_, ok := snapshot.Get("c")
+	assert.False(t, ok)
+
+	b, _ = env.Get("b")
+	assert.Equal(t, int64(20), b.(*Integer).Value)
+
+	// A frozen environment is its own snapshot
+	assert.Same(t, snapshot, snapshot.Snapshot())
+}
+
+func TestEnvironmentSnapshotFrozenInWritable(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Freeze()
+
+	snapshot := inner.Snapshot()
+	fork := inner.Fork()
+	outer.Set("a", &Integer{Value: 2})
+
+	// The enclosing environment is snapshotted though inner is frozen
+	a, _ := snapshot.Get("a")
+	assert.Equal(t, int64(1), a.(*Integer).Value)
+	a, _ = fork.Get("a")
+	assert.Equal(t, int64(1), a.(*Integer).Value)
+
+	a, _ = inner.Get("a")
+	assert.Equal(t, int64(2), a.(*Integer).Value)
+
+	// Its snapshot is frozen all the way out, so is its own snapshot
+	assert.Same(t, snapshot, snapshot.Snapshot())
+}
+
+func TestEnvironmentFork(t *testing.T) {
+	base := NewEnvironment()
+	base.Set("x", &Integer{Value: 1})
+	base.Freeze()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			fork := base.Fork()
+			fork.Set("x", &Integer{Value: int64(i)})
+
+			x, _ := fork.Get("x")
+			assert.Equal(t, int64(i), x.(*Integer).Value)
+		}(i)
+	}
+	wg.Wait()
+
+	x, _ := base.Get("x")
+	assert.Equal(t, int64(1), x.(*Integer).Value)
+
+	// A fork of a writable environment is isolated from it both ways
+	env := NewEnvironment()
+	fork := env.Fork()
+	env.Set("y", &Integer{Value: 1})
+	fork.Set("z", &Integer{Value: 2})
+
+	_, ok := fork.Get("y")
+	assert.False(t, ok)
+	_, ok = env.Get("z")
+	assert.False(t, ok)
+}
+
+func TestFrameEnvironment(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	outer.Set("g", &Integer{Value: 9})
+
+	frame := NewFrameEnvironment(outer, []string{"x", "y"})
+	inner := NewFrameEnvironment(frame, []string{"z"})
+
+	// An unbound slot falls back to the enclosing scopes by name
+	x, ok := inner.Lookup(1, 0, "x")
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(1), x.(*Integer).Value)
+	}
+
+	frame.SetSlot(0, &Integer{Value: 2})
+	x, _ = inner.Lookup(1, 0, "x")
+	assert.Equal(t, int64(2), x.(*Integer).Value)
+
+	// Slots and names refer to the same variables
+	frame.Set("y", &Integer{Value: 3})
+	y, _ := inner.Lookup(1, 1, "y")
+	assert.Equal(t, int64(3), y.(*Integer).Value)
+	y, _ = inner.Get("y")
+	assert.Equal(t, int64(3), y.(*Integer).Value)
+
+	g, ok := inner.Lookup(2, -1, "g")
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(9), g.(*Integer).Value)
+	}
+
+	_, ok = inner.Lookup(0, 0, "z")
+	assert.False(t, ok)
+
+	// Slots are copied on write once shared with a snapshot
+	snapshot := frame.Snapshot()
+	frame.SetSlot(1, &Integer{Value: 30})
+	y, _ = snapshot.Get("y")
+	assert.Equal(t, int64(3), y.(*Integer).Value)
+
+	err, ok := snapshot.SetSlot(1, &Integer{Value: 4}).(*Error)
+	if assert.True(t, ok) {
+		assert.Equal(t, "cannot bind y in a frozen environment", err.Message)
+	}
+}
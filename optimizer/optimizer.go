@@ -0,0 +1,315 @@
+// Package optimizer rewrites a program before it is evaluated, computing
+// what can be computed without running it. Every pass preserves the result
+// of the program. Optimize a program before resolving it, as the passes
+// change its scopes. The module loader and the REPL run all passes on what
+// they evaluate, after reporting the problems of the source as written.
+package optimizer
+
+import (
+	"arkham/ast"
+	"arkham/token"
+	"strconv"
+)
+
+// Pass is a set of optimization passes.
+type Pass uint
+
+const (
+	// FoldConstants replaces integer, string and boolean infix and prefix
+	// expressions of literals by their value, e.g. 2 * 3 by 6.
+	FoldConstants Pass = 1 << iota
+	// EliminateDeadBranches replaces if expressions with a literal condition
+	// by the branch taken.
+	EliminateDeadBranches
+	// InlineCalls replaces immediately invoked function literals without
+	// parameters whose body is a single expression by that expression.
+	InlineCalls
+	// DropUnreachable drops the statements following a return or throw.
+	DropUnreachable
+
+	All = FoldConstants | EliminateDeadBranches | InlineCalls | DropUnreachable
+)
+
+// Optimize rewrites program in place with passes and returns it.
+func Optimize(program *ast.Program, passes Pass) *ast.Program {
+	o := &optimizer{passes: passes, spawned: map[*ast.CallExpression]bool{}, quoted: map[ast.Node]bool{}}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SpawnExpression:
+			// spawn runs a call, so the call it is given stays one
+			if call, ok := node.Call.(*ast.CallExpression); ok {
+				o.spawned[call] = true
+			}
+		case *ast.CallExpression:
+			if node.IsCallTo("quote") {
+				o.quote(node)
+			}
+		}
+		return true
+	})
+
+	return ast.Modify(program, o.optimize).(*ast.Program)
+}
+
+type optimizer struct {
+	passes  Pass
+	spawned map[*ast.CallExpression]bool
+	quoted  map[ast.Node]bool // source quoted, which stays as written
+}
+
+// quote records the nodes of the source quoted by call, all but the
+// arguments of unquote calls there.
+func (o *optimizer) quote(call *ast.CallExpression) {
+	for _, arg := range call.Arguments {
+		ast.Inspect(arg, func(node ast.Node) bool {
+			if node == nil {
+				return false
+			}
+
+			o.quoted[node] = true
+
+			unquote, ok := node.(*ast.CallExpression)
+			return !ok || !unquote.IsCallTo("unquote")
+		})
+	}
+}
+
+func (o *optimizer) enabled(pass Pass) bool {
+	return o.passes&pass != 0
+}
+
+// optimize returns the node replacing node, whose children are optimized.
+func (o *optimizer) optimize(node ast.Node) ast.Node {
+	if o.quoted[node] {
+		return node
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		node.Statements = o.statements(node.Statements)
+	case *ast.BlockStatement:
+		node.Statements = o.statements(node.Statements)
+	case *ast.PrefixExpression:
+		if o.enabled(FoldConstants) {
+			if folded := foldPrefix(node); folded != nil {
+				return folded
+			}
+		}
+	case *ast.InfixExpression:
+		if o.enabled(FoldConstants) {
+			if folded := foldInfix(node); folded != nil {
+				return folded
+			}
+		}
+	case *ast.IfExpression:
+		if truthy, ok := constantTruth(node.Condition); ok && o.enabled(EliminateDeadBranches) {
+			// A block evaluates to its last statement, as the if would
+			if truthy {
+				return node.Consequence
+			}
+			if node.Alternative != nil {
+				return node.Alternative
+			}
+		}
+	case *ast.CallExpression:
+		if o.enabled(InlineCalls) && !o.spawned[node] {
+			if inlined := inline(node); inlined != nil {
+				return inlined
+			}
+		}
+	}
+
+	return node
+}
+
+func (o *optimizer) statements(stmts []ast.Statement) []ast.Statement {
+	result := make([]ast.Statement, 0, len(stmts))
+
+	for i, stmt := range stmts {
+		// An if without else whose condition is false does nothing, unless it
+		// is the last statement and so evaluates to null
+		if es, ok := stmt.(*ast.ExpressionStatement); ok && i < len(stmts)-1 && o.enabled(EliminateDeadBranches) {
+			if ie, ok := es.Expression.(*ast.IfExpression); ok && ie.Alternative == nil {
+				if truthy, ok := constantTruth(ie.Condition); ok && !truthy {
+					continue
+				}
+			}
+		}
+
+		result = append(result, stmt)
+
+		if o.enabled(DropUnreachable) {
+			switch stmt.(type) {
+			case *ast.ReturnStatement, *ast.ThrowStatement:
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+// constantTruth reports whether a literal condition is truthy, and whether
+// cond is a literal at all.
+func constantTruth(cond ast.Expression) (truthy bool, ok bool) {
+	switch cond := cond.(type) {
+	case *ast.Boolean:
+		return cond.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// foldPrefix returns the literal value of node, or nil when its operand is
+// not a literal or applying the operator fails.
+func foldPrefix(node *ast.PrefixExpression) ast.Expression {
+	switch right := node.Right.(type) {
+	case *ast.Boolean:
+		if node.Operator == "!" {
+			return booleanLiteral(node.Token, !right.Value)
+		}
+	case *ast.IntegerLiteral:
+		switch node.Operator {
+		case "!":
+			return booleanLiteral(node.Token, false)
+		case "-":
+			return integerLiteral(node.Token, -right.Value)
+		}
+	case *ast.StringLiteral:
+		if node.Operator == "!" {
+			return booleanLiteral(node.Token, false)
+		}
+	}
+
+	return nil
+}
+
+// foldInfix returns the literal value of node, or nil when an operand is not
+// a literal or applying the operator fails.
+func foldInfix(node *ast.InfixExpression) ast.Expression {
+	tok := node.Token
+
+	if left, ok := node.Left.(*ast.IntegerLiteral); ok {
+		if right, ok := node.Right.(*ast.IntegerLiteral); ok {
+			l, r := left.Value, right.Value
+
+			switch node.Operator {
+			case "+":
+				return integerLiteral(tok, l+r)
+			case "-":
+				return integerLiteral(tok, l-r)
+			case "*":
+				return integerLiteral(tok, l*r)
+			case "/":
+				if r != 0 {
+					return integerLiteral(tok, l/r)
+				}
+			case "<":
+				return booleanLiteral(tok, l < r)
+			case ">":
+				return booleanLiteral(tok, l > r)
+			}
+		}
+	}
+
+	left, ok := literalValue(node.Left)
+	if !ok {
+		return nil
+	}
+
+	right, ok := literalValue(node.Right)
+	if !ok {
+		return nil
+	}
+
+	switch node.Operator {
+	case "==":
+		return booleanLiteral(tok, left == right)
+	case "!=":
+		return booleanLiteral(tok, left != right)
+	case "+":
+		l, isString := left.(string)
+		r, bothStrings := right.(string)
+		if isString && bothStrings {
+			return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: l + r, Line: tok.Line, Column: tok.Column}, Value: l + r}
+		}
+	}
+
+	return nil
+}
+
+// literalValue returns the value of an integer, string or boolean literal,
+// which are equal exactly when the objects they evaluate to are.
+func literalValue(node ast.Expression) (interface{}, bool) {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return node.Value, true
+	case *ast.StringLiteral:
+		return node.Value, true
+	case *ast.Boolean:
+		return node.Value, true
+	default:
+		return nil, false
+	}
+}
+
+func integerLiteral(at token.Token, value int64) *ast.IntegerLiteral {
+	tok := token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10), Line: at.Line, Column: at.Column}
+	return &ast.IntegerLiteral{Token: tok, Value: value}
+}
+
+func booleanLiteral(at token.Token, value bool) *ast.Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Line: at.Line, Column: at.Column}
+	if value {
+		tok.Type, tok.Literal = token.TRUE, "true"
+	}
+	return &ast.Boolean{Token: tok, Value: value}
+}
+
+// inline returns the expression call evaluates to when call immediately
+// invokes a function literal without parameters whose body is a single
+// expression evaluating the same in the scope of the call, or nil.
+func inline(call *ast.CallExpression) ast.Expression {
+	fn, ok := call.Function.(*ast.FunctionLiteral)
+	if !ok || fn.Name != nil || fn.Generator || len(fn.Parameters) != 0 || fn.Rest != nil || len(call.Arguments) != 0 {
+		return nil
+	}
+
+	if len(fn.Body.Statements) != 1 {
+		return nil
+	}
+
+	stmt, ok := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok || !scopeIndependent(stmt.Expression) {
+		return nil
+	}
+
+	return stmt.Expression
+}
+
+// scopeIndependent reports whether node evaluates the same in any scope
+// enclosed by the one it was written in: it binds no names there and does
+// not return from the function it is in.
+func scopeIndependent(node ast.Expression) bool {
+	independent := true
+
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			// Its body has a scope of its own, but a name binds in this one
+			if node.Name != nil {
+				independent = false
+			}
+			return false
+		case *ast.BlockStatement, *ast.PropagateExpression, *ast.YieldExpression:
+			// Blocks may bind names and ? returns from the function
+			independent = false
+		}
+		return independent
+	})
+
+	return independent
+}
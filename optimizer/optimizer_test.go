@@ -0,0 +1,147 @@
+package optimizer
+
+import (
+	"arkham/ast"
+	"arkham/lexer"
+	"arkham/parser"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(10 - 4) / 3", "2"},
+		{"-(2 + 3)", "-5"},
+		{"1 / 0", "(1 / 0)"},
+		{"x + 1 * 2", "(x + 2)"},
+		{"1 < 2", "true"},
+		{"3 > 4", "false"},
+		{"1 == 1", "true"},
+		{"\"a\" + \"b\"", "\"ab\""},
+		{"\"a\" == \"a\"", "true"},
+		{"\"1\" == 1", "false"},
+		{"true != false", "true"},
+		{"!true", "false"},
+		{"!!5", "true"},
+		{"!\"\"", "false"},
+		{"-true", "(-true)"},
+		{"true + 1", "(true + 1)"},
+		{"\"a\" < \"b\"", "(\"a\" < \"b\")"},
+		{"\"a\" - \"b\"", "(\"a\" - \"b\")"},
+		{"[1 + 1, f(2 * 2)]", "[2, f(4)]"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input), FoldConstants)
+		assert.Equal(t, tt.expected, program.String(), "input: %s", tt.input)
+	}
+}
+
+func TestFoldedTokens(t *testing.T) {
+	program := Optimize(parse(t, "x;\n  1 + 2 == 3"), FoldConstants)
+
+	folded := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.Boolean)
+	assert.Equal(t, "true", folded.Token.Literal)
+	assert.Equal(t, 2, folded.Token.Line)
+}
+
+func TestEliminateDeadBranches(t *testing.T) {
+	tests := []struct {
+		input    string
+		passes   Pass
+		expected string
+	}{
+		{"let x = if (true) { 1 } else { 2 }", EliminateDeadBranches, "let x = 1"},
+		{"let x = if (false) { 1 } else { 2 }", EliminateDeadBranches, "let x = 2"},
+		{"let x = if (\"\") { 1 }", EliminateDeadBranches, "let x = 1"},
+		{"if (false) { f() }; 2", EliminateDeadBranches, "2"},
+		{"let x = if (1 > 2) { 1 } else { 2 }", EliminateDeadBranches, "let x = if ((1 > 2)) { 1 } else { 2 }"},
+		{"let x = if (1 > 2) { 1 } else { 2 }", EliminateDeadBranches | FoldConstants, "let x = 2"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input), tt.passes)
+		assert.Equal(t, tt.expected, program.String(), "input: %s", tt.input)
+	}
+
+	// A last if without else evaluates to null, so it stays
+	program := Optimize(parse(t, "if (false) { 1 }"), EliminateDeadBranches)
+	require.Len(t, program.Statements, 1)
+	assert.IsType(t, &ast.IfExpression{}, program.Statements[0].(*ast.ExpressionStatement).Expression)
+}
+
+func TestInlineCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() { x + 1 }()", "(x + 1)"},
+		{"fn() { fn() { 2 }() }()", "2"},
+		{"fn() { fn(y) { y } }()", "fn(y) { y }"},
+		{"fn(x) { x }(1)", "fn(x) { x }(1)"},
+		{"fn() { let y = 1; y }()", "fn() { let y = 1; y }()"},
+		{"fn() { f()? }()", "fn() { (f()?) }()"},
+		{"fn() { fn g() { 1 } }()", "fn() { fn g() { 1 } }()"},
+		{"fn*() { 1 }()", "fn*() { 1 }()"},
+		{"fn() { match (x) { n => n } }()", "match (x) { n => n }"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input), InlineCalls)
+		assert.Equal(t, tt.expected, program.String(), "input: %s", tt.input)
+	}
+
+	// spawn runs a call
+	program := Optimize(parse(t, "spawn fn() { 1 }()"), InlineCalls)
+	spawn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SpawnExpression)
+	assert.IsType(t, &ast.CallExpression{}, spawn.Call)
+}
+
+func TestDropUnreachable(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return 1; 2; 3", "return 1"},
+		{"throw 1; 2", "throw 1"},
+		{"fn() { return 1; 2 }", "fn() { return 1 }"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input), DropUnreachable)
+		assert.Equal(t, tt.expected, program.String(), "input: %s", tt.input)
+	}
+
+	program := Optimize(parse(t, "fn() { if (x) { return 1; f() }; 2 }"), DropUnreachable)
+	body := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral).Body
+	require.Len(t, body.Statements, 2)
+	consequence := body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression).Consequence
+	assert.Len(t, consequence.Statements, 1)
+}
+
+func TestQuotedSourceIsKept(t *testing.T) {
+	input := "quote(1 + unquote(2 * 3) + fn() { 4 }()); quote(if (true) { return 1; 2 })"
+
+	program := Optimize(parse(t, input), All)
+	assert.Equal(t, "quote(((1 + unquote(6)) + fn() { 4 }())); quote(if (true) { return 1; 2 })", program.String())
+}
+
+func TestPassesAreToggleable(t *testing.T) {
+	input := "if (true) { return fn() { 1 + 2 }(); f() }"
+
+	assert.Equal(t, parse(t, input).String(), Optimize(parse(t, input), 0).String())
+	assert.Equal(t, "return 3", Optimize(parse(t, input), All).String())
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), "input: %s", input)
+	return program
+}
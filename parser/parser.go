@@ -11,12 +11,15 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	PIPELINE    // x |> f()
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
+	INDEX       // array[index]
+	POSTFIX     // result?
 )
 
 type (
@@ -25,6 +28,7 @@ type (
 )
 
 var precedences = map[token.TokenType]int{
+	token.PIPE:     PIPELINE,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -34,19 +38,30 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
+	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+	token.QUESTION: POSTFIX,
 }
 
 type Parser struct {
 	lexer          *lexer.Lexer
 	curToken       token.Token
 	peekToken      token.Token
-	errors         []string
+	errors         []Error
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// noArrow disables arrow functions while parsing a match guard, where the
+	// => belongs to the arm. It is reset inside brackets.
+	noArrow bool
+
+	// generator is set while parsing the body of a generator function, the
+	// only place a yield is allowed.
+	generator bool
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{lexer: l, errors: []string{}}
+	p := &Parser{lexer: l, errors: []Error{}}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
@@ -59,6 +74,16 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_START, p.parseInterpolatedString)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.SPAWN, p.parseSpawnExpression)
+	p.registerPrefix(token.SELECT, p.parseSelectExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -70,6 +95,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.PIPE, p.parsePipelineExpression)
+	p.registerInfix(token.QUESTION, p.parsePropagateExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -133,6 +162,14 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.ENUM:
+		return p.parseEnumStatement()
 	default:
 		return p.parseExpressionStatment()
 	}
@@ -141,11 +178,20 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
-	}
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -176,6 +222,174 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Fields = []*ast.Identifier{}
+	seen := map[string]bool{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[field.Value] {
+			msg := fmt.Sprintf("duplicate field %s in struct %s", field.Value, stmt.Name.Value)
+			p.error(p.curToken, msg)
+			return nil
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseEnumStatement() *ast.EnumStatement {
+	stmt := &ast.EnumStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Variants = []*ast.EnumVariant{}
+	seen := map[string]bool{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		variant := &ast.EnumVariant{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		if seen[variant.Name.Value] {
+			msg := fmt.Sprintf("duplicate variant %s in enum %s", variant.Name.Value, stmt.Name.Value)
+			p.error(p.curToken, msg)
+			return nil
+		}
+		seen[variant.Name.Value] = true
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+
+			variant.Fields = p.parseVariantFields(variant.Name.Value)
+			if variant.Fields == nil {
+				return nil
+			}
+		}
+
+		stmt.Variants = append(stmt.Variants, variant)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseVariantFields parses the field names of an enum variant, returning
+// nil on error.
+func (p *Parser) parseVariantFields(variant string) []*ast.Identifier {
+	fields := []*ast.Identifier{}
+	seen := map[string]bool{}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		if seen[p.curToken.Literal] {
+			msg := fmt.Sprintf("duplicate field %s in variant %s", p.curToken.Literal, variant)
+			p.error(p.curToken, msg)
+			return nil
+		}
+		seen[p.curToken.Literal] = true
+
+		fields = append(fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	return fields
+}
+
 func (p *Parser) parseExpressionStatment() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -212,7 +426,14 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.ARROW) && !p.noArrow {
+		p.nextToken()
+		return p.parseArrowFunctionBody(ident.Token, []ast.Expression{ident}, nil)
+	}
+
+	return ident
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
@@ -221,7 +442,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.error(p.curToken, msg)
 		return nil
 	}
 
@@ -233,6 +454,37 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = []ast.Expression{p.parseStringLiteral()}
+
+	defer p.allowArrows()()
+
+	for {
+		p.nextToken()
+
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, exp)
+
+		if p.peekTokenIs(token.STRING_MIDDLE) {
+			p.nextToken()
+			str.Parts = append(str.Parts, p.parseStringLiteral())
+			continue
+		}
+
+		if !p.expectPeek(token.STRING_END) {
+			return nil
+		}
+
+		str.Parts = append(str.Parts, p.parseStringLiteral())
+
+		return str
+	}
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -255,11 +507,42 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parsePropagateExpression(left ast.Expression) ast.Expression {
+	return &ast.PropagateExpression{Token: p.curToken, Value: left}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	if !p.noArrow && p.isArrowParameterList() {
+		start := p.curToken
+
+		defer p.enterFunction(false)()
+
+		params, rest := p.parseFunctionParameters()
+		if params == nil || !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		return p.parseArrowFunctionBody(start, params, rest)
+	}
+
+	defer p.allowArrows()()
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -271,6 +554,105 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	return exp
 }
 
+// allowArrows re-enables arrow functions inside a bracketed expression. The
+// returned function restores the previous state.
+func (p *Parser) allowArrows() func() {
+	noArrow := p.noArrow
+	p.noArrow = false
+	return func() { p.noArrow = noArrow }
+}
+
+// isArrowParameterList reports whether the '(' at curToken opens the
+// parameter list of an arrow function. It scans a copy of the lexer up to
+// the matching ')' and checks whether a '=>' follows.
+func (p *Parser) isArrowParameterList() bool {
+	l := *p.lexer
+	depth := 1
+
+	for tok := p.peekToken; tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			depth--
+			if depth == 0 {
+				return l.NextToken().Type == token.ARROW
+			}
+		}
+	}
+
+	return false
+}
+
+// parseArrowFunctionBody desugars `params => body` into a function literal
+// positioned at start. curToken is the '=>'. A brace starts a block body,
+// anything else is a single expression.
+func (p *Parser) parseArrowFunctionBody(start token.Token, params []ast.Expression, rest *ast.Identifier) ast.Expression {
+	lit := &ast.FunctionLiteral{
+		Token:      token.Token{Type: token.FUNCTION, Literal: "fn", Line: start.Line, Column: start.Column},
+		Parameters: params,
+		Rest:       rest,
+		Arrow:      true,
+	}
+
+	defer p.enterFunction(false)()
+
+	p.nextToken()
+
+	if p.curTokenIs(token.LBRACE) {
+		lit.Body = p.parseBlockStatement()
+		return lit
+	}
+
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
+
+	lit.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+
+	return lit
+}
+
+// parsePipelineExpression desugars `left |> f(args)` into `f(left, args)`.
+// A right-hand side that is not a call is called with left alone.
+func (p *Parser) parsePipelineExpression(left ast.Expression) ast.Expression {
+	pipe := p.curToken
+
+	p.nextToken()
+	right := p.parseExpression(PIPELINE)
+	if right == nil {
+		return nil
+	}
+
+	// xs |> f()? pipes into the call, then propagates its result
+	if propagate, ok := right.(*ast.PropagateExpression); ok {
+		if _, ok := propagate.Value.(*ast.CallExpression); ok {
+			value := p.pipeInto(pipe, left, propagate.Value)
+			return &ast.PropagateExpression{Token: propagate.Token, Value: value}
+		}
+	}
+
+	return p.pipeInto(pipe, left, right)
+}
+
+// pipeInto inserts left as the first argument of the call right, or calls
+// right with left when it is not a call.
+func (p *Parser) pipeInto(pipe token.Token, left, right ast.Expression) ast.Expression {
+	if call, ok := right.(*ast.CallExpression); ok {
+		args := append([]ast.Expression{left}, call.Arguments...)
+		return &ast.CallExpression{Token: call.Token, Function: call.Function, Arguments: args, Pipe: true}
+	}
+
+	return &ast.CallExpression{
+		Token:     token.Token{Type: token.LPAREN, Literal: "(", Line: pipe.Line, Column: pipe.Column},
+		Function:  right,
+		Arguments: []ast.Expression{left},
+		Pipe:      true,
+	}
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
@@ -294,6 +676,23 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			// else if: the nested if expression becomes the only statement of
+			// the alternative block
+			p.nextToken()
+			stmt := &ast.ExpressionStatement{Token: p.curToken}
+			stmt.Expression = p.parseIfExpression()
+			if stmt.Expression == nil {
+				return nil
+			}
+
+			expression.Alternative = &ast.BlockStatement{
+				Token:      stmt.Token,
+				Statements: []ast.Statement{stmt},
+			}
+			return expression
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -304,66 +703,643 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
-func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{Token: p.curToken}
-	block.Statements = []ast.Statement{}
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
 
-	p.nextToken()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		if stmt := p.parseStatement(); stmt != nil {
-			block.Statements = append(block.Statements, stmt)
-		}
+	expression.Block = p.parseBlockStatement()
 
+	if p.peekTokenIs(token.CATCH) {
 		p.nextToken()
 
-	}
-	return block
-}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
 
-func (p *Parser) parseFunctionLiteral() ast.Expression {
-	lit := &ast.FunctionLiteral{Token: p.curToken}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 
-	if !p.expectPeek(token.LPAREN) {
-		return nil
+		expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
 
-	if !p.expectPeek(token.LBRACE) {
-		return nil
+		expression.Finally = p.parseBlockStatement()
 	}
 
-	lit.Body = p.parseBlockStatement()
+	if expression.Catch == nil && expression.Finally == nil {
+		msg := fmt.Sprintf("expected catch or finally after try block, got %s instead", p.peekToken.Type)
+		p.error(p.peekToken, msg)
+		return nil
+	}
 
-	return lit
+	return expression
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+	if !p.expectPeek(token.LPAREN) {
+		return nil
 	}
 
 	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
 
-	for p.peekTokenIs(token.COMMA) {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Arms = []*ast.MatchArm{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	return expression
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.curToken}
+
+	arm.Pattern = p.parsePattern()
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
 		p.nextToken()
 		p.nextToken()
+
+		p.noArrow = true
+		arm.Guard = p.parseExpression(LOWEST)
+		p.noArrow = false
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	arm.Body = p.parseArmBody()
+	if arm.Body == nil {
+		return nil
+	}
+
+	return arm
+}
+
+// parseArmBody parses the body following the => of a match arm or select
+// case.
+func (p *Parser) parseArmBody() ast.Expression {
+	p.nextToken()
+
+	// A brace after the arrow always starts a block body
+	if p.curTokenIs(token.LBRACE) {
+		return p.parseBlockStatement()
+	}
+
+	return p.parseExpression(LOWEST)
+}
+
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	expression := &ast.SpawnExpression{Token: p.curToken}
+
+	p.nextToken()
+
+	expression.Call = p.parseExpression(PREFIX)
+	if expression.Call == nil {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseSelectExpression() ast.Expression {
+	expression := &ast.SelectExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Cases = []*ast.SelectCase{}
+	hasDefault := false
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		c := p.parseSelectCase()
+		if c == nil {
+			return nil
+		}
+
+		if c.Operation == nil {
+			if hasDefault {
+				p.error(p.curToken, "duplicate default case in select")
+				return nil
+			}
+			hasDefault = true
+		}
+
+		expression.Cases = append(expression.Cases, c)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	return expression
+}
+
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	c := &ast.SelectCase{Token: p.curToken}
+
+	if !p.curTokenIs(token.IDENT) || p.curToken.Literal != "_" {
+		p.noArrow = true
+		operation := p.parseExpression(LOWEST)
+		p.noArrow = false
+
+		call, ok := operation.(*ast.CallExpression)
+		if !ok || !isChannelOperation(call) {
+			p.error(p.curToken, "select case must be recv(channel) or send(channel, value)")
+			return nil
+		}
+		c.Operation = call
+
+		if p.peekTokenIs(token.AS) {
+			if call.Function.(*ast.Identifier).Value != "recv" {
+				p.error(p.curToken, "only a recv case can bind a value")
+				return nil
+			}
+
+			p.nextToken()
+
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+
+			c.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	c.Body = p.parseArmBody()
+	if c.Body == nil {
+		return nil
+	}
+
+	return c
+}
+
+// isChannelOperation reports whether call is recv(channel) or send(channel,
+// value) with plain positional arguments.
+func isChannelOperation(call *ast.CallExpression) bool {
+	fn, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+
+	for _, arg := range call.Arguments {
+		switch arg.(type) {
+		case *ast.SpreadExpression, *ast.NamedArgument:
+			return false
+		}
+	}
+
+	switch fn.Value {
+	case "recv":
+		return len(call.Arguments) == 1
+	case "send":
+		return len(call.Arguments) == 2
+	default:
+		return false
+	}
+}
+
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+
+		switch p.peekToken.Type {
+		case token.LBRACE:
+			p.nextToken()
+
+			fields := p.parseHashPattern()
+			if fields == nil {
+				return nil
+			}
+
+			return &ast.StructPattern{Token: ident.Token, Name: ident, Fields: fields.(*ast.HashPattern)}
+		case token.LPAREN, token.DOT:
+			return p.parseVariantPattern(ident)
+		default:
+			return ident
+		}
+	case token.INT:
+		return p.parseIntegerLiteral()
+	case token.STRING:
+		return p.parseStringLiteral()
+	case token.TRUE, token.FALSE:
+		return p.parseBoolean()
+	case token.MINUS:
+		expression := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+		if !p.expectPeek(token.INT) {
+			return nil
+		}
+		expression.Right = p.parseIntegerLiteral()
+		return expression
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	default:
+		p.invalidPatternError(p.curToken.Type)
+		return nil
+	}
+}
+
+// parseBindingPattern parses the patterns allowed where a name is bound, i.e.
+// function parameters: an identifier or an array or hash pattern.
+func (p *Parser) parseBindingPattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT, token.LBRACKET, token.LBRACE:
+		return p.parsePattern()
+	default:
+		p.invalidPatternError(p.curToken.Type)
+		return nil
+	}
+}
+
+func (p *Parser) parseVariantPattern(name *ast.Identifier) ast.Expression {
+	pattern := &ast.VariantPattern{Token: name.Token, Name: name}
+
+	if p.peekTokenIs(token.DOT) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		pattern.Enum = name
+		pattern.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.peekTokenIs(token.LPAREN) {
+		return pattern
+	}
+
+	p.nextToken()
+
+	pattern.Arguments = []ast.Expression{}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+
+		arg := p.parsePattern()
+		if arg == nil {
+			return nil
+		}
+		pattern.Arguments = append(pattern.Arguments, arg)
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	return pattern
+}
+
+func (p *Parser) parseArrayPattern() ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	pattern.Elements = []ast.Expression{}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Expression {
+	pattern := &ast.HashPattern{Token: p.curToken}
+	pattern.Pairs = []ast.HashPair{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		var pair ast.HashPair
+
+		switch p.curToken.Type {
+		case token.IDENT:
+			// Shorthand {name} binds the key to an identifier of the same name
+			pair.Key = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			pair.Value = pair.Key
+		case token.STRING:
+			pair.Key = p.parseStringLiteral()
+		case token.INT:
+			pair.Key = p.parseIntegerLiteral()
+		default:
+			p.invalidPatternError(p.curToken.Type)
+			return nil
+		}
+
+		if pair.Value == nil || p.peekTokenIs(token.COLON) {
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+
+			p.nextToken()
+
+			pair.Value = p.parsePattern()
+			if pair.Value == nil {
+				return nil
+			}
+		}
+
+		pattern.Pairs = append(pattern.Pairs, pair)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
 	}
 
+	return pattern
+}
+
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	defer p.allowArrows()()
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if stmt := p.parseStatement(); stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+
+		p.nextToken()
+
+	}
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if p.peekTokenIs(token.ASTERISK) {
+		p.nextToken()
+		lit.Generator = true
+	}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		lit.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	// Default values are evaluated by the caller, so only the body may yield
+	defer p.enterFunction(false)()
+
+	lit.Parameters, lit.Rest = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.generator = lit.Generator
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken, Parameters: []*ast.Identifier{}}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		lit.Parameters = append(lit.Parameters, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	defer p.enterFunction(false)()
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+// enterFunction records whether the function being parsed is a generator.
+// The returned function restores the state of the enclosing function.
+func (p *Parser) enterFunction(generator bool) func() {
+	enclosing := p.generator
+	p.generator = generator
+	return func() { p.generator = enclosing }
+}
+
+func (p *Parser) parseYieldExpression() ast.Expression {
+	exp := &ast.YieldExpression{Token: p.curToken}
+
+	if !p.generator {
+		p.error(p.curToken, "yield outside of a generator function")
+		return nil
+	}
+
+	// A bare yield ends where the enclosing statement or list does
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.RBRACE, token.RPAREN, token.RBRACKET, token.COMMA, token.EOF:
+		return exp
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+
+	return exp
+}
+
+func (p *Parser) parseForExpression() ast.Expression {
+	exp := &ast.ForExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	exp.Pattern = p.parseBindingPattern()
+	if exp.Pattern == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	defer p.allowArrows()()
+
+	p.nextToken()
+	exp.Iterable = p.parseExpression(LOWEST)
+
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 
-	return identifiers
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Body = p.parseBlockStatement()
+
+	return exp
+}
+
+func (p *Parser) parseFunctionParameters() ([]ast.Expression, *ast.Identifier) {
+	params := []ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return params, nil
+	}
+
+	for {
+		p.nextToken()
+
+		// A rest parameter must be the last one
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil, nil
+			}
+
+			rest := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if !p.expectPeek(token.RPAREN) {
+				return nil, nil
+			}
+
+			return params, rest
+		}
+
+		param := p.parseBindingPattern()
+		if param == nil {
+			return nil, nil
+		}
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+
+			def := &ast.DefaultParameter{Token: p.curToken, Parameter: param}
+
+			p.nextToken()
+			def.Default = p.parseExpression(LOWEST)
+
+			param = def
+		}
+
+		params = append(params, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+
+	return params, nil
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -373,27 +1349,127 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+	args := p.parseExpressionList(token.RPAREN, p.parseCallArgument)
+
+	named := false
+	for _, arg := range args {
+		if _, ok := arg.(*ast.NamedArgument); ok {
+			named = true
+		} else if named {
+			p.error(p.curToken, "positional argument after named argument")
+			return nil
+		}
+	}
+
+	return args
+}
+
+func (p *Parser) parseCallArgument() ast.Expression {
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+		arg := &ast.NamedArgument{Token: p.curToken}
+		arg.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		p.nextToken()
+		p.nextToken()
+		arg.Value = p.parseExpression(LOWEST)
+
+		return arg
+	}
+
+	return p.parseListElement()
+}
+
+// parseListElement parses an element of an array literal or argument list,
+// which may be spread with `...`.
+func (p *Parser) parseListElement() ast.Expression {
+	if p.curTokenIs(token.ELLIPSIS) {
+		spread := &ast.SpreadExpression{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return args
+		spread.Value = p.parseExpression(LOWEST)
+
+		return spread
+	}
+
+	return p.parseExpression(LOWEST)
+}
+
+func (p *Parser) parseExpressionList(end token.TokenType, parseElement func() ast.Expression) []ast.Expression {
+	list := []ast.Expression{}
+
+	defer p.allowArrows()()
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, parseElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, parseElement())
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET, p.parseListElement)
+	return array
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	defer p.allowArrows()()
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = []ast.HashPair{}
+
+	defer p.allowArrows()()
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -404,16 +1480,40 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+// Error is a syntax error and the token it was found at.
+type Error struct {
+	Token   token.Token
+	Message string
+}
+
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Message
+	}
+	return msgs
+}
+
+// ErrorList returns the syntax errors with the tokens they were found at.
+func (p *Parser) ErrorList() []Error {
 	return p.errors
 }
 
+func (p *Parser) error(tok token.Token, msg string) {
+	p.errors = append(p.errors, Error{Token: tok, Message: msg})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.error(p.peekToken, msg)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.error(p.curToken, msg)
+}
+
+func (p *Parser) invalidPatternError(t token.TokenType) {
+	msg := fmt.Sprintf("invalid pattern starting with %s", t)
+	p.error(p.curToken, msg)
 }
@@ -3,7 +3,9 @@ package parser
 import (
 	"arkham/ast"
 	"arkham/lexer"
+	"arkham/token"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,6 +18,7 @@ func initProgramTest(t *testing.T, input string) *ast.Program {
 
 	program := parser.ParseProgram()
 	checkParserErrors(t, parser)
+	checkRoundTrip(t, input, program)
 
 	return program
 }
@@ -71,6 +74,310 @@ func TestReturnStatement(t *testing.T) {
 	}
 }
 
+func TestThrowStatement(t *testing.T) {
+	program := initProgramTest(t, `throw "boom";`)
+
+	require.Len(t, program.Statements, 1, "program.Statements")
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	require.Truef(t, ok, "stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+
+	literal, ok := stmt.Value.(*ast.StringLiteral)
+	require.Truef(t, ok, "value not *ast.StringLiteral. got=%T", stmt.Value)
+	assert.Equal(t, "boom", literal.Value)
+}
+
+func TestMemberExpression(t *testing.T) {
+	program := initProgramTest(t, "person.name")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MemberExpression)
+	require.Truef(t, ok, "exp not *ast.MemberExpression. got=%T", stmt.Expression)
+
+	testIdentifier(t, exp.Object, "person")
+	testIdentifier(t, exp.Property, "name")
+}
+
+func TestMemberExpressionErrors(t *testing.T) {
+	p := New(lexer.New("a.1"))
+	p.ParseProgram()
+
+	assert.Contains(t, p.Errors(), "expected next token to be IDENT, got INT instead")
+}
+
+func TestErrorList(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedLine   int
+		expectedColumn int
+	}{
+		{"let = 1", "expected next token to be IDENT, got = instead", 1, 5},
+		{"let x = 1;\n  )", "no prefix parse function for ) found", 2, 3},
+		{"struct P { x, x }", "duplicate field x in struct P", 1, 15},
+		{"try { 1 }; 2", "expected catch or finally after try block, got ; instead", 1, 10},
+		{"fn() { yield 1 }", "yield outside of a generator function", 1, 8},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.ErrorList()
+		require.NotEmpty(t, errs, "input: %s", tt.input)
+		assert.Equal(t, tt.expectedError, errs[0].Message, "input: %s", tt.input)
+		assert.Equal(t, tt.expectedLine, errs[0].Token.Line, "input: %s", tt.input)
+		assert.Equal(t, tt.expectedColumn, errs[0].Token.Column, "input: %s", tt.input)
+		assert.Equal(t, tt.expectedError, p.Errors()[0], "input: %s", tt.input)
+	}
+}
+
+func TestStructStatement(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedName   string
+		expectedFields []string
+	}{
+		{"struct Point { x, y }", "Point", []string{"x", "y"}},
+		{"struct Pair { first, second, };", "Pair", []string{"first", "second"}},
+		{"struct Unit {}", "Unit", []string{}},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		require.Len(t, program.Statements, 1)
+		stmt, ok := program.Statements[0].(*ast.StructStatement)
+		require.Truef(t, ok, "stmt not *ast.StructStatement. got=%T", program.Statements[0])
+
+		testIdentifier(t, stmt.Name, tt.expectedName)
+		require.Len(t, stmt.Fields, len(tt.expectedFields))
+		for i, field := range tt.expectedFields {
+			testIdentifier(t, stmt.Fields[i], field)
+		}
+	}
+}
+
+func TestStructStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"struct { x }", "expected next token to be IDENT, got { instead"},
+		{"struct Point { x, x }", "duplicate field x in struct Point"},
+		{"struct Point { x y }", "expected next token to be ,, got IDENT instead"},
+		{"struct Point { 1 }", "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
+func TestStructPattern(t *testing.T) {
+	program := initProgramTest(t, "match (p) { Point{x, y: 0} => x, _ => 0 }")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp := stmt.Expression.(*ast.MatchExpression)
+
+	pattern, ok := exp.Arms[0].Pattern.(*ast.StructPattern)
+	require.Truef(t, ok, "pattern not *ast.StructPattern. got=%T", exp.Arms[0].Pattern)
+
+	testIdentifier(t, pattern.Name, "Point")
+	require.Len(t, pattern.Fields.Pairs, 2)
+	assert.Equal(t, "Point{x, y: 0}", pattern.String())
+}
+
+func TestEnumStatement(t *testing.T) {
+	program := initProgramTest(t, "enum Shape { Circle(r), Rect(w, h), Empty, Point() }")
+
+	require.Len(t, program.Statements, 1)
+	stmt, ok := program.Statements[0].(*ast.EnumStatement)
+	require.Truef(t, ok, "stmt not *ast.EnumStatement. got=%T", program.Statements[0])
+
+	testIdentifier(t, stmt.Name, "Shape")
+	require.Len(t, stmt.Variants, 4)
+
+	tests := []struct {
+		name   string
+		fields []string
+	}{
+		{"Circle", []string{"r"}},
+		{"Rect", []string{"w", "h"}},
+		{"Empty", nil},
+		{"Point", []string{}},
+	}
+
+	for i, tt := range tests {
+		variant := stmt.Variants[i]
+		testIdentifier(t, variant.Name, tt.name)
+
+		if tt.fields == nil {
+			assert.Nil(t, variant.Fields)
+			continue
+		}
+
+		require.Len(t, variant.Fields, len(tt.fields))
+		for j, field := range tt.fields {
+			testIdentifier(t, variant.Fields[j], field)
+		}
+	}
+
+	assert.Equal(t, "enum Shape { Circle(r), Rect(w, h), Empty, Point() }", program.String())
+}
+
+func TestEnumStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"enum { A }", "expected next token to be IDENT, got { instead"},
+		{"enum Shape { A, A }", "duplicate variant A in enum Shape"},
+		{"enum Shape { A(x, x) }", "duplicate field x in variant A"},
+		{"enum Shape { A(1) }", "expected next token to be IDENT, got INT instead"},
+		{"enum Shape { A B }", "expected next token to be ,, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
+func TestVariantPatterns(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedEnum  string
+		expectedName  string
+		expectedArity int
+		expected      string
+	}{
+		{"match (s) { Circle(r) => r }", "", "Circle", 1, "Circle(r)"},
+		{"match (s) { Shape.Rect(w, [h, _]) => w }", "Shape", "Rect", 2, "Shape.Rect(w, [h, _])"},
+		{"match (s) { Shape.Empty => 0 }", "Shape", "Empty", -1, "Shape.Empty"},
+		{"match (s) { Point() => 0 }", "", "Point", 0, "Point()"},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp := stmt.Expression.(*ast.MatchExpression)
+
+		pattern, ok := exp.Arms[0].Pattern.(*ast.VariantPattern)
+		require.Truef(t, ok, "pattern not *ast.VariantPattern. got=%T", exp.Arms[0].Pattern)
+
+		if tt.expectedEnum == "" {
+			assert.Nil(t, pattern.Enum)
+		} else {
+			testIdentifier(t, pattern.Enum, tt.expectedEnum)
+		}
+		testIdentifier(t, pattern.Name, tt.expectedName)
+
+		if tt.expectedArity < 0 {
+			assert.Nil(t, pattern.Arguments)
+		} else {
+			assert.Len(t, pattern.Arguments, tt.expectedArity)
+		}
+
+		assert.Equal(t, tt.expected, pattern.String())
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	program := initProgramTest(t, `import "lib/math" as m;`)
+
+	require.Len(t, program.Statements, 1)
+	stmt, ok := program.Statements[0].(*ast.ImportStatement)
+	require.Truef(t, ok, "stmt not *ast.ImportStatement. got=%T", program.Statements[0])
+
+	assert.Equal(t, "lib/math", stmt.Path.Value)
+	testIdentifier(t, stmt.Name, "m")
+	assert.Equal(t, `import "lib/math" as m`, program.String())
+}
+
+func TestImportStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"import math as m;", "expected next token to be STRING, got IDENT instead"},
+		{`import "math";`, "expected next token to be AS, got ; instead"},
+		{`import "math" as "m";`, "expected next token to be IDENT, got STRING instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParam  string
+		expectedCatch  bool
+		expectFinally  bool
+		expectedString string
+	}{
+		{"try { a } catch (e) { b }", "e", true, false, "try { a } catch (e) { b }"},
+		{"try { a } finally { c }", "", false, true, "try { a } finally { c }"},
+		{"try { a } catch (err) { b } finally { c }", "err", true, true, "try { a } catch (err) { b } finally { c }"},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		require.Truef(t, ok, "exp not *ast.TryExpression. got=%T", stmt.Expression)
+
+		require.Len(t, exp.Block.Statements, 1)
+
+		if tt.expectedCatch {
+			testIdentifier(t, exp.Param, tt.expectedParam)
+			require.NotNil(t, exp.Catch)
+		} else {
+			assert.Nil(t, exp.Catch)
+			assert.Nil(t, exp.Param)
+		}
+
+		if tt.expectFinally {
+			require.NotNil(t, exp.Finally)
+		} else {
+			assert.Nil(t, exp.Finally)
+		}
+
+		assert.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"try { a }", "expected catch or finally after try block, got EOF instead"},
+		{"try { a } catch { b }", "expected next token to be (, got { instead"},
+		{"try { a } catch (1) { b }", "expected next token to be IDENT, got INT instead"},
+		{"try a", "expected next token to be {, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 
@@ -117,6 +424,47 @@ func TestStringLiteralExpression(t *testing.T) {
 	assert.Equal(t, "hello world", literal.Value)
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"Hello ${name}, you have ${count + 1} items"`
+
+	program := initProgramTest(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	require.Truef(t, ok, "exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+
+	require.Len(t, str.Parts, 5, "wrong number of parts")
+
+	expectedText := map[int]string{0: "Hello ", 2: ", you have ", 4: " items"}
+	for i, text := range expectedText {
+		literal, ok := str.Parts[i].(*ast.StringLiteral)
+		require.Truef(t, ok, "part %d not *ast.StringLiteral. got=%T", i, str.Parts[i])
+		assert.Equal(t, text, literal.Value)
+	}
+
+	testIdentifier(t, str.Parts[1], "name")
+	testInfixExpression(t, str.Parts[3], "count", "+", 1)
+
+	assert.Equal(t, `"Hello ${name}, you have ${(count + 1)} items"`, program.String())
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a ${} b"`, "no prefix parse function for STRING_END found"},
+		{`"a ${x y} b"`, "expected next token to be STRING_END, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
 func TestParsingPrefixExpression(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -228,7 +576,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		},
 		{
 			"3 + 4; -5 * 5",
-			"(3 + 4)((-5) * 5)",
+			"(3 + 4); ((-5) * 5)",
 		},
 		{
 			"5 > 4 == 3 < 4",
@@ -294,6 +642,54 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"a + b |> f(c) |> g()",
+			"(((a + b) |> f(c)) |> g())",
+		},
+		{
+			"xs |> f",
+			"(xs |> f())",
+		},
+		{
+			"a == b |> f()",
+			"((a == b) |> f())",
+		},
+		{
+			"-f(x)? + 1",
+			"((-(f(x)?)) + 1)",
+		},
+		{
+			"a[0]?",
+			"((a[0])?)",
+		},
+		{
+			"xs |> f()?",
+			"((xs |> f())?)",
+		},
+		{
+			"a.b.c",
+			"((a.b).c)",
+		},
+		{
+			"-a.b * c.d(e)",
+			"((-(a.b)) * (c.d)(e))",
+		},
+		{
+			"a.b[0].c",
+			"(((a.b)[0]).c)",
+		},
+		{
+			"xs |> s.join()",
+			"(xs |> (s.join)())",
+		},
 	}
 	for _, tt := range tests {
 		program := initProgramTest(t, tt.input)
@@ -302,6 +698,27 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+func TestPipeFlag(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"xs |> f", true},
+		{"xs |> f(1)", true},
+		{"f(xs)", false},
+		{"f(xs |> g)", false},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		call, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+		require.Truef(t, ok, "exp not *ast.CallExpression. got=%T", program.Statements[0])
+
+		assert.Equalf(t, tt.expected, call.Pipe, "Pipe of %s", tt.input)
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -356,6 +773,92 @@ func TestIfExpression(t *testing.T) {
 	assert.Nil(t, exp.Alternative, "exp.Alternative.Statements was not nil")
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { z }`
+
+	program := initProgramTest(t, input)
+
+	require.Len(t, program.Statements, 1, "program.Body does not contain correct number of statements")
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	require.Truef(t, ok, "program.Statements is not ast.ExpressionStatement. got=%T", program.Statements[0])
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	require.Truef(t, ok, "stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+
+	require.NotNil(t, exp.Alternative, "exp.Alternative is nil")
+	require.Len(t, exp.Alternative.Statements, 1, "alternative is not 1 statement")
+
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	require.Truef(t, ok, "Statements[0] is not ast.ExpressionStatement. got=%T", exp.Alternative.Statements[0])
+
+	elseIf, ok := alternative.Expression.(*ast.IfExpression)
+	require.Truef(t, ok, "alternative is not ast.IfExpression. got=%T", alternative.Expression)
+
+	if !testInfixExpression(t, elseIf.Condition, "x", ">", "y") {
+		return
+	}
+
+	require.NotNil(t, elseIf.Alternative, "elseIf.Alternative is nil")
+	require.Len(t, elseIf.Alternative.Statements, 1, "else is not 1 statement")
+
+	last := elseIf.Alternative.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(t, last.Expression, "z")
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	input := `match (x) { 1 => a, "two" => b, -3 => c, true => d, n if n > 5 => n, _ => { e } }`
+
+	program := initProgramTest(t, input)
+
+	require.Len(t, program.Statements, 1, "program.Statements does not contain enough statements")
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	require.Truef(t, ok, "stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	require.Truef(t, ok, "stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+
+	testIdentifier(t, exp.Subject, "x")
+	require.Len(t, exp.Arms, 6, "wrong number of arms")
+
+	testLiteralExpression(t, exp.Arms[0].Pattern, 1)
+	testIdentifier(t, exp.Arms[0].Body, "a")
+
+	str, ok := exp.Arms[1].Pattern.(*ast.StringLiteral)
+	require.Truef(t, ok, "pattern is not ast.StringLiteral. got=%T", exp.Arms[1].Pattern)
+	assert.Equal(t, "two", str.Value)
+
+	assert.Equal(t, "(-3)", exp.Arms[2].Pattern.String())
+	testLiteralExpression(t, exp.Arms[3].Pattern, true)
+
+	testIdentifier(t, exp.Arms[4].Pattern, "n")
+	testInfixExpression(t, exp.Arms[4].Guard, "n", ">", 5)
+
+	testIdentifier(t, exp.Arms[5].Pattern, "_")
+	assert.Nil(t, exp.Arms[5].Guard)
+	_, ok = exp.Arms[5].Body.(*ast.BlockStatement)
+	assert.Truef(t, ok, "body is not ast.BlockStatement. got=%T", exp.Arms[5].Body)
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"match (x) { 1 => a 2 => b }", "expected next token to be ,, got INT instead"},
+		{"match (x) { 1 a }", "expected next token to be =>, got IDENT instead"},
+		{"match (x) { (1) => a }", "invalid pattern starting with ("},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -380,6 +883,208 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestGeneratorFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedName      string
+		expectedGenerator bool
+		expectedString    string
+	}{
+		{"fn* () { yield 1; }", "", true, "fn*() { (yield 1) }"},
+		{"fn* gen(x) { yield x; yield; }", "gen", true, "fn* gen(x) { (yield x); (yield) }"},
+		{"fn add(x, y) { x + y }", "add", false, "fn add(x, y) { (x + y) }"},
+		{"fn*() { let y = yield 1 + 2; y }", "", true, "fn*() { let y = (yield (1 + 2)); y }"},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		require.Truef(t, ok, "exp not *ast.FunctionLiteral. got=%T", stmt.Expression)
+
+		if tt.expectedName != "" {
+			testIdentifier(t, function.Name, tt.expectedName)
+		} else {
+			assert.Nil(t, function.Name)
+		}
+
+		assert.Equal(t, tt.expectedGenerator, function.Generator)
+		assert.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedString string
+	}{
+		{"macro() { 1 }", []string{}, "macro() { 1 }"},
+		{"macro(x, y) { quote(unquote(x) + unquote(y)) }", []string{"x", "y"}, "macro(x, y) { quote((unquote(x) + unquote(y))) }"},
+		{"let unless = macro(cond) { cond }", []string{"cond"}, "let unless = macro(cond) { cond }"},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		var exp ast.Expression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.ExpressionStatement:
+			exp = stmt.Expression
+		case *ast.LetStatement:
+			exp = stmt.Value
+		}
+
+		macro, ok := exp.(*ast.MacroLiteral)
+		require.Truef(t, ok, "exp not *ast.MacroLiteral. got=%T", exp)
+		require.Len(t, macro.Parameters, len(tt.expectedParams))
+		for i, param := range tt.expectedParams {
+			testIdentifier(t, macro.Parameters[i], param)
+		}
+
+		assert.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestMacroLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"macro { 1 }", "expected next token to be (, got { instead"},
+		{"macro(1) { 1 }", "expected next token to be IDENT, got INT instead"},
+		{"macro(x y) { 1 }", "expected next token to be ,, got IDENT instead"},
+		{"macro(x) x", "expected next token to be {, got IDENT instead"},
+		{"macro() { yield 1 }", "yield outside of a generator function"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
+func TestYieldErrors(t *testing.T) {
+	tests := []struct {
+		input string
+	}{
+		{"yield 1"},
+		{"fn() { yield 1 }"},
+		{"fn*() { let f = fn() { yield 1 } }"},
+		{"fn*() { let f = x => yield x }"},
+		{"fn*(x = yield 1) { x }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), "yield outside of a generator function", "input: %s", tt.input)
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"for (x in xs) { puts(x) }", "for (x in xs) { puts(x) }"},
+		{"for ([k, v] in pairs()) { k + v }", "for ([k, v] in pairs()) { (k + v) }"},
+		{"for (x in map(xs, y => y * 2)) { x }", "for (x in map(xs, ((y) => (y * 2)))) { x }"},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		_, ok := stmt.Expression.(*ast.ForExpression)
+		require.Truef(t, ok, "exp not *ast.ForExpression. got=%T", stmt.Expression)
+
+		assert.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestForExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"for x in xs { x }", "expected next token to be (, got IDENT instead"},
+		{"for (x of xs) { x }", "expected next token to be IN, got IDENT instead"},
+		{"for (1 in xs) { x }", "invalid pattern starting with INT"},
+		{"for (x in xs) x", "expected next token to be {, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
+func TestSpawnExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"spawn worker(1, ch)", "(spawn worker(1, ch))"},
+		{"spawn fn() { 1 }", "(spawn fn() { 1 })"},
+		{"spawn xs.map(f)", "(spawn (xs.map)(f))"},
+		{"let t = spawn f(); await(t)", "let t = (spawn f()); await(t)"},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		assert.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestSelectExpression(t *testing.T) {
+	input := `select { recv(a) as v => v, send(b, x => x) => { 1 }, recv(c) => 2, _ => 3 }`
+
+	program := initProgramTest(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.SelectExpression)
+	require.Truef(t, ok, "exp not *ast.SelectExpression. got=%T", stmt.Expression)
+	require.Len(t, exp.Cases, 4)
+
+	testIdentifier(t, exp.Cases[0].Name, "v")
+	assert.Nil(t, exp.Cases[1].Name)
+	assert.Nil(t, exp.Cases[3].Operation)
+
+	assert.Equal(t, "select { recv(a) as v => v, send(b, ((x) => x)) => { 1 }, recv(c) => 2, _ => 3 }", program.String())
+}
+
+func TestSelectExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"select { f(a) => 1 }", "select case must be recv(channel) or send(channel, value)"},
+		{"select { recv(a, b) => 1 }", "select case must be recv(channel) or send(channel, value)"},
+		{"select { send(a) => 1 }", "select case must be recv(channel) or send(channel, value)"},
+		{"select { recv(...a) => 1 }", "select case must be recv(channel) or send(channel, value)"},
+		{"select { a => 1 }", "select case must be recv(channel) or send(channel, value)"},
+		{"select { send(a, 1) as v => 1 }", "only a recv case can bind a value"},
+		{"select { _ => 1, _ => 2 }", "duplicate default case in select"},
+		{"select { recv(a) 1 }", "expected next token to be =>, got INT instead"},
+		{"select recv(a) => 1", "expected next token to be {, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -388,6 +1093,8 @@ func TestFunctionParameterParsing(t *testing.T) {
 		{input: "fn() {};", expectedParams: []string{}},
 		{input: "fn(x) {};", expectedParams: []string{"x"}},
 		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+		{input: "fn(x, y = 10) {};", expectedParams: []string{"x", "y = 10"}},
+		{input: "fn(x = a + b) {};", expectedParams: []string{"x = (a + b)"}},
 	}
 
 	for _, tt := range tests {
@@ -398,12 +1105,265 @@ func TestFunctionParameterParsing(t *testing.T) {
 
 		assert.Len(t, function.Parameters, len(tt.expectedParams), "length parameters wrong")
 
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+		for i, param := range tt.expectedParams {
+			assert.Equal(t, param, function.Parameters[i].String())
 		}
 	}
 }
 
+func TestLetDestructuringParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = xs;", "let [a, b] = xs"},
+		{"let [a, b, ...rest] = xs;", "let [a, b, ...rest] = xs"},
+		{"let [...all] = xs;", "let [...all] = xs"},
+		{"let [] = xs;", "let [] = xs"},
+		{"let [a, [b, c]] = xs;", "let [a, [b, c]] = xs"},
+		{"let {name, age: years} = person;", "let {name, age: years} = person"},
+		{`let {"first name": first, 1: one} = h;`, `let {"first name": first, 1: one} = h`},
+		{"let {pos: [x, y]} = h;", "let {pos: [x, y]} = h"},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		require.Len(t, program.Statements, 1, "program.Statements does not contain 1 statement")
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		require.Truef(t, ok, "stmt is not ast.LetStatement. got=%T", program.Statements[0])
+		require.NotNil(t, stmt.Pattern, "stmt.Pattern is nil")
+		assert.Nil(t, stmt.Name, "stmt.Name is not nil")
+
+		assert.Equal(t, tt.expected, program.String())
+	}
+}
+
+func TestArrayPatternParsing(t *testing.T) {
+	program := initProgramTest(t, "let [a, _, ...rest] = xs;")
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	pattern, ok := stmt.Pattern.(*ast.ArrayPattern)
+	require.Truef(t, ok, "stmt.Pattern is not ast.ArrayPattern. got=%T", stmt.Pattern)
+
+	require.Len(t, pattern.Elements, 2, "wrong number of elements")
+	testIdentifier(t, pattern.Elements[0], "a")
+	testIdentifier(t, pattern.Elements[1], "_")
+	testIdentifier(t, pattern.Rest, "rest")
+	testIdentifier(t, stmt.Value, "xs")
+}
+
+func TestHashPatternParsing(t *testing.T) {
+	program := initProgramTest(t, "let {name, age: years} = person;")
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	pattern, ok := stmt.Pattern.(*ast.HashPattern)
+	require.Truef(t, ok, "stmt.Pattern is not ast.HashPattern. got=%T", stmt.Pattern)
+
+	require.Len(t, pattern.Pairs, 2, "wrong number of pairs")
+	testIdentifier(t, pattern.Pairs[0].Key, "name")
+	testIdentifier(t, pattern.Pairs[0].Value, "name")
+	testIdentifier(t, pattern.Pairs[1].Key, "age")
+	testIdentifier(t, pattern.Pairs[1].Value, "years")
+}
+
+func TestPatternParsingErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let [a, b = xs;", "expected next token to be ,, got = instead"},
+		{"let [...rest, a] = xs;", "expected next token to be ], got , instead"},
+		{"let [...1] = xs;", "expected next token to be IDENT, got INT instead"},
+		{`let {"a"} = h;`, "expected next token to be :, got } instead"},
+		{"let {(a)} = h;", "invalid pattern starting with ("},
+		{"fn(1) { 1 }", "invalid pattern starting with INT"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
+func TestFunctionPatternParameterParsing(t *testing.T) {
+	program := initProgramTest(t, "fn([a, b], {name}, c) { a };")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function := stmt.Expression.(*ast.FunctionLiteral)
+
+	require.Len(t, function.Parameters, 3, "length parameters wrong")
+
+	_, ok := function.Parameters[0].(*ast.ArrayPattern)
+	assert.Truef(t, ok, "Parameters[0] is not ast.ArrayPattern. got=%T", function.Parameters[0])
+
+	_, ok = function.Parameters[1].(*ast.HashPattern)
+	assert.Truef(t, ok, "Parameters[1] is not ast.HashPattern. got=%T", function.Parameters[1])
+
+	testIdentifier(t, function.Parameters[2], "c")
+}
+
+func TestRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams int
+		expectedRest   string
+	}{
+		{"fn(...rest) {};", 0, "rest"},
+		{"fn(first, ...rest) {};", 1, "rest"},
+		{"fn(a, b = 1, ...rest) {};", 2, "rest"},
+		{"fn(a, b) {};", 2, ""},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		assert.Len(t, function.Parameters, tt.expectedParams, "length parameters wrong")
+
+		if tt.expectedRest == "" {
+			assert.Nil(t, function.Rest)
+		} else {
+			testIdentifier(t, function.Rest, tt.expectedRest)
+		}
+	}
+}
+
+func TestCallArgumentParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...xs)", "f(...xs)"},
+		{"f(1, ...xs, 2)", "f(1, ...xs, 2)"},
+		{"f(x: 1, y: 2 + 3)", "f(x: 1, y: (2 + 3))"},
+		{"f(1, ...xs, y: 2)", "f(1, ...xs, y: 2)"},
+		{"[1, ...xs]", "[1, ...xs]"},
+	}
+
+	for _, tt := range tests {
+		program := initProgramTest(t, tt.input)
+
+		assert.Equal(t, tt.expected, program.String())
+	}
+
+	program := initProgramTest(t, "f(a, ...b, c: d)")
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+
+	require.Len(t, call.Arguments, 3, "wrong length of arguments.")
+	testIdentifier(t, call.Arguments[0], "a")
+
+	spread, ok := call.Arguments[1].(*ast.SpreadExpression)
+	require.Truef(t, ok, "Arguments[1] is not ast.SpreadExpression. got=%T", call.Arguments[1])
+	testIdentifier(t, spread.Value, "b")
+
+	named, ok := call.Arguments[2].(*ast.NamedArgument)
+	require.Truef(t, ok, "Arguments[2] is not ast.NamedArgument. got=%T", call.Arguments[2])
+	testIdentifier(t, named.Name, "c")
+	testIdentifier(t, named.Value, "d")
+}
+
+func TestParameterParsingErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"fn(...rest, a) {};", "expected next token to be ), got , instead"},
+		{"fn(...) {};", "expected next token to be IDENT, got ) instead"},
+		{"f(x: 1, 2)", "positional argument after named argument"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Contains(t, p.Errors(), tt.expectedError, "input: %s", tt.input)
+	}
+}
+
+func TestArrowFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedRest   string
+		expectedBody   string
+	}{
+		{"x => x * 2", []string{"x"}, "", "(x * 2)"},
+		{"(a, b) => a + b", []string{"a", "b"}, "", "(a + b)"},
+		{"() => 1", []string{}, "", "1"},
+		{"(x, y = 1, ...rest) => x", []string{"x", "y = 1"}, "rest", "x"},
+		{"([a, b]) => a", []string{"[a, b]"}, "", "a"},
+		{"x => { let y = x; y }", []string{"x"}, "", "let y = x; y"},
+		{"x => y => x + y", []string{"x"}, "", "((y) => (x + y))"},
+		{"((x)) => x", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if tt.expectedParams == nil {
+			assert.NotEmpty(t, p.Errors(), "expected parser errors for %s", tt.input)
+			continue
+		}
+
+		checkParserErrors(t, p)
+		checkRoundTrip(t, tt.input, program)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		require.Truef(t, ok, "stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+
+		require.Len(t, function.Parameters, len(tt.expectedParams), "length parameters wrong")
+		for i, param := range tt.expectedParams {
+			assert.Equal(t, param, function.Parameters[i].String())
+		}
+
+		if tt.expectedRest != "" {
+			testIdentifier(t, function.Rest, tt.expectedRest)
+		}
+
+		assert.Equal(t, tt.expectedBody, function.Body.String())
+		assert.True(t, function.Arrow, "function.Arrow not set")
+	}
+}
+
+func TestArrowFunctionInCallArguments(t *testing.T) {
+	program := initProgramTest(t, "map(xs, x => x + 1, (a, b) => a)")
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	require.Len(t, call.Arguments, 3, "wrong length of arguments.")
+
+	_, ok := call.Arguments[1].(*ast.FunctionLiteral)
+	assert.Truef(t, ok, "Arguments[1] is not ast.FunctionLiteral. got=%T", call.Arguments[1])
+
+	_, ok = call.Arguments[2].(*ast.FunctionLiteral)
+	assert.Truef(t, ok, "Arguments[2] is not ast.FunctionLiteral. got=%T", call.Arguments[2])
+}
+
+func TestMatchGuardDoesNotParseArrow(t *testing.T) {
+	program := initProgramTest(t, "match (x) { n if n == m => n, n if any(xs, y => y) => 1, _ if (m) => 2 }")
+
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	require.Len(t, exp.Arms, 3, "wrong number of arms")
+
+	testInfixExpression(t, exp.Arms[0].Guard, "n", "==", "m")
+	testIdentifier(t, exp.Arms[0].Body, "n")
+
+	call, ok := exp.Arms[1].Guard.(*ast.CallExpression)
+	require.Truef(t, ok, "guard is not ast.CallExpression. got=%T", exp.Arms[1].Guard)
+	_, ok = call.Arguments[1].(*ast.FunctionLiteral)
+	assert.Truef(t, ok, "guard argument is not ast.FunctionLiteral. got=%T", call.Arguments[1])
+
+	testIdentifier(t, exp.Arms[2].Guard, "m")
+	testIntegerLiteral(t, exp.Arms[2].Body, 2)
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	program := initProgramTest(t, input)
@@ -426,6 +1386,99 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	program := initProgramTest(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	require.Truef(t, ok, "exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+
+	require.Len(t, array.Elements, 3, "len(array.Elements) not 3")
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	program := initProgramTest(t, "[]")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	require.Truef(t, ok, "exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+
+	assert.Len(t, array.Elements, 0)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	program := initProgramTest(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	require.Truef(t, ok, "exp not *ast.IndexExpression. got=%T", stmt.Expression)
+
+	testIdentifier(t, indexExp.Left, "myArray")
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	program := initProgramTest(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	require.Truef(t, ok, "exp is not ast.HashLiteral. got=%T", stmt.Expression)
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	require.Len(t, hash.Pairs, len(expected), "hash.Pairs has wrong length")
+
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		require.Truef(t, ok, "key is not ast.StringLiteral. got=%T", pair.Key)
+
+		assert.Equal(t, expected[i].key, literal.Value)
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	program := initProgramTest(t, "{}")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	require.Truef(t, ok, "exp is not ast.HashLiteral. got=%T", stmt.Expression)
+
+	assert.Len(t, hash.Pairs, 0)
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
+
+	program := initProgramTest(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	require.Truef(t, ok, "exp is not ast.HashLiteral. got=%T", stmt.Expression)
+
+	require.Len(t, hash.Pairs, 3, "hash.Pairs has wrong length")
+
+	testInfixExpression(t, hash.Pairs[0].Value, 0, "+", 1)
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+	testInfixExpression(t, hash.Pairs[2].Value, 15, "/", 5)
+}
+
 func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{}) bool {
 	switch v := expected.(type) {
 	case int:
@@ -544,6 +1597,94 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 	return true
 }
 
+// checkRoundTrip checks that the source program.String() returns for the
+// program parsed from input parses back into the same tree. Every test
+// parsing a program successfully checks it.
+func checkRoundTrip(t *testing.T, input string, program *ast.Program) {
+	t.Helper()
+
+	source := program.String()
+
+	p := New(lexer.New(source))
+	reparsed := p.ParseProgram()
+	require.Emptyf(t, p.Errors(), "parsing %q, the source of %q", source, input)
+
+	assert.Equalf(t, withoutTokens(program), withoutTokens(reparsed), "parsing %q, the source of %q", source, input)
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// withoutTokens returns a copy of node with every token zeroed, as where
+// the tokens are and which surround an expression depends on the source.
+func withoutTokens(node ast.Node) ast.Node {
+	node = ast.Clone(node)
+	zeroTokens(reflect.ValueOf(node), map[uintptr]bool{})
+	return node
+}
+
+func zeroTokens(v reflect.Value, seen map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		zeroTokens(v.Elem(), seen)
+	case reflect.Interface:
+		zeroTokens(v.Elem(), seen)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			zeroTokens(v.Index(i), seen)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if field.Type() == tokenType {
+				field.Set(reflect.Zero(tokenType))
+			} else {
+				zeroTokens(field, seen)
+			}
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	tests := []string{
+		`"a\"b\\c\n\t\r\${d}$"`,
+		`"${"a${b}"} and ${ {"k": 1}["k"] }"`,
+		`"a ${"q"}"`,
+		`"${"q"}${1}${"r"}"`,
+		`let {"first name": first, 1: [-1]} = h`,
+		`match (x) { -1 => 1, [-2, ...r] => 2, {a: -3} => 3, S.V(-4) => 4, _ => ({}) }`,
+		`select { recv(c) as v => ({"v": v}), _ => {} }`,
+		`if (a) { 1 } else if (b) { 2 } else { 3 }`,
+		`if (a) { 1 } else { if (b) { 2 } }`,
+		`(if (a) { f } else { g })(1)`,
+		`(spawn f)(1)`,
+		`spawn (f(1))(2)`,
+		`xs |> f(1)()`,
+		`xs |> fn(x) { x }()`,
+		`(xs |> f)(1)`,
+		`(xs |> f())?`,
+		`x => ({})`,
+		`x => {}`,
+		`(x = -1, [y], {z}, ...rest) => z`,
+		`match (x) { n if (y => y)(n) => n }`,
+		`fn* g() { yield; let x = yield; x }`,
+		`fn*() { f(yield) + (yield 1) }`,
+		`struct Empty {}; enum E {}; E.A`,
+		`let m = macro() { quote(unquote(1)) }`,
+		`try { 1 } catch (e) {} finally {}`,
+		`for ([k, -1] in xs) {}`,
+		`-(-1)`,
+		`!(-a)[0]`,
+	}
+
+	for _, input := range tests {
+		initProgramTest(t, input)
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 
@@ -4,10 +4,13 @@ import (
 	"arkham/evaluator"
 	"arkham/lexer"
 	"arkham/object"
+	"arkham/optimizer"
 	"arkham/parser"
+	"arkham/resolver"
 	"bufio"
 	"fmt"
 	"io"
+	"os"
 )
 
 const PROMPT = ">> "
@@ -15,6 +18,8 @@ const PROMPT = ">> "
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	env.SetImporter(evaluator.NewModuleLoader(os.DirFS(".")), "")
+	macros := object.NewEnvironment()
 
 	for {
 		fmt.Print(PROMPT)
@@ -33,6 +38,19 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		evaluator.DefineMacros(program, macros)
+		if err := evaluator.ExpandMacros(program, macros); err != nil {
+			io.WriteString(out, err.Inspect()+"\n")
+			continue
+		}
+
+		if !printResolverDiagnostics(out, resolver.ResolveLine(program, evaluator.Defined(env))) {
+			continue
+		}
+
+		optimizer.Optimize(program, optimizer.All)
+		resolver.ResolveLine(program, evaluator.Defined(env))
+
 		evaluated := evaluator.Eval(program, env)
 
 		if evaluated != nil {
@@ -44,6 +62,22 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
+// printResolverDiagnostics prints the problems found resolving a line and
+// reports whether it can be evaluated, i.e. there are only warnings.
+func printResolverDiagnostics(out io.Writer, diagnostics []resolver.Diagnostic) bool {
+	ok := true
+	for _, d := range diagnostics {
+		if d.Severity == resolver.Warning {
+			io.WriteString(out, "\twarning: "+d.String()+"\n")
+			continue
+		}
+
+		io.WriteString(out, "\t"+d.String()+"\n")
+		ok = false
+	}
+	return ok
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
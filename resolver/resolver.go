@@ -0,0 +1,464 @@
+// Package resolver binds the identifiers of a program to the variables they
+// refer to before it is evaluated.
+//
+// Every function, match arm, catch block, for loop body and select case is
+// evaluated in a new environment, and the resolver mirrors those scopes: the
+// variables declared in each are given slots in a frame, and each identifier
+// referring to one is given the number of scopes out to its frame and its
+// slot, so the evaluator can find it without looking up names. Variables at
+// the top level of a program stay bound by name, as a REPL or importer reads
+// them back by name.
+//
+// A variable belongs to the scope that declares it anywhere, not only before
+// the identifier, as closures may refer to variables bound after them.
+package resolver
+
+import (
+	"arkham/ast"
+	"arkham/token"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+// Diagnostic is a problem found in a program: an undefined variable, which
+// is an error, or an unused one, which is a warning.
+type Diagnostic struct {
+	Token    token.Token // the identifier at fault
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Token.Line, d.Token.Column, d.Message)
+}
+
+// Resolve binds the identifiers of program and returns the problems found,
+// in source order. Names not declared by program are defined when global
+// reports so, e.g. builtins and bindings of the environment program is
+// evaluated in. Variables whose name starts with _, parameters and
+// top-level variables are never reported unused.
+func Resolve(program *ast.Program, global func(name string) bool) []Diagnostic {
+	diagnostics, _ := ResolveDeclarations(program, global)
+	return diagnostics
+}
+
+// ResolveLine resolves a line entered in a session, such as the REPL's, as
+// Resolve does, except that undefined variables referred to in function
+// bodies are warnings: a later line may define them before the function is
+// called.
+func ResolveLine(program *ast.Program, global func(name string) bool) []Diagnostic {
+	r := &resolver{global: global, declarations: map[*ast.Identifier]*ast.Identifier{}, line: true}
+	return r.run(program)
+}
+
+// ResolveDeclarations resolves program as Resolve does and also maps each
+// identifier declaring or referring to a variable to the identifier first
+// declaring it, so an identifier declaring a variable maps to itself.
+func ResolveDeclarations(program *ast.Program, global func(name string) bool) ([]Diagnostic, map[*ast.Identifier]*ast.Identifier) {
+	r := &resolver{global: global, declarations: map[*ast.Identifier]*ast.Identifier{}}
+	return r.run(program), r.declarations
+}
+
+func (r *resolver) run(program *ast.Program) []Diagnostic {
+	top := &scope{variables: map[string]*variable{}}
+	for _, stmt := range program.Statements {
+		r.resolve(stmt, top)
+	}
+
+	return r.finish()
+}
+
+type scope struct {
+	outer     *scope
+	frame     *ast.Scope // nil for the top level
+	function  bool       // the scope of a function body
+	variables map[string]*variable
+	order     []*variable // in order of declaration
+	variants  map[string]bool
+}
+
+type variable struct {
+	ident  *ast.Identifier // the first declaration
+	slot   int
+	used   bool
+	silent bool // never reported unused
+}
+
+type reference struct {
+	ident  *ast.Identifier
+	scope  *scope
+	method bool // the f of x.f(), which need not be a variable
+}
+
+type resolver struct {
+	global       func(name string) bool
+	references   []reference
+	scopes       []*scope
+	declarations map[*ast.Identifier]*ast.Identifier
+	line         bool // resolving a line of a session
+}
+
+func (r *resolver) enter(outer *scope) *scope {
+	s := &scope{outer: outer, frame: &ast.Scope{}, variables: map[string]*variable{}}
+	r.scopes = append(r.scopes, s)
+	return s
+}
+
+// declare binds ident in s. A variable declared twice in a scope keeps its
+// slot, as binding it again replaces its value.
+func (r *resolver) declare(ident *ast.Identifier, s *scope, silent bool) {
+	if ident.Value == "_" {
+		return
+	}
+
+	v, ok := s.variables[ident.Value]
+	if !ok {
+		v = &variable{ident: ident, slot: -1, silent: silent}
+		if s.frame != nil {
+			v.slot = len(s.frame.Names)
+			s.frame.Names = append(s.frame.Names, ident.Value)
+		}
+		s.variables[ident.Value] = v
+		s.order = append(s.order, v)
+	}
+	r.declarations[ident] = v.ident
+
+	if s.frame != nil {
+		ident.Binding = &ast.Binding{Depth: 0, Slot: v.slot}
+	}
+}
+
+// refer records a use of ident in s, resolved once all scopes are known.
+func (r *resolver) refer(ident *ast.Identifier, s *scope) {
+	r.references = append(r.references, reference{ident: ident, scope: s})
+}
+
+func (r *resolver) resolve(node ast.Node, s *scope) {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression, s)
+	case *ast.LetStatement:
+		r.resolve(node.Value, s)
+		if node.Pattern != nil {
+			r.declarePattern(node.Pattern, s, false)
+		} else {
+			r.declare(node.Name, s, false)
+		}
+	case *ast.ReturnStatement:
+		r.resolve(node.ReturnValue, s)
+	case *ast.ThrowStatement:
+		r.resolve(node.Value, s)
+	case *ast.ImportStatement:
+		r.declare(node.Name, s, true)
+	case *ast.StructStatement:
+		r.declare(node.Name, s, true)
+	case *ast.EnumStatement:
+		r.declare(node.Name, s, true)
+		for _, variant := range node.Variants {
+			r.declare(variant.Name, s, true)
+			if variant.Fields == nil {
+				if s.variants == nil {
+					s.variants = map[string]bool{}
+				}
+				s.variants[variant.Name.Value] = true
+			}
+		}
+	case *ast.BlockStatement:
+		// Blocks share the environment they are evaluated in
+		for _, stmt := range node.Statements {
+			r.resolve(stmt, s)
+		}
+	case *ast.Identifier:
+		r.refer(node, s)
+	case *ast.PrefixExpression:
+		r.resolve(node.Right, s)
+	case *ast.InfixExpression:
+		r.resolve(node.Left, s)
+		r.resolve(node.Right, s)
+	case *ast.PropagateExpression:
+		r.resolve(node.Value, s)
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			r.resolve(part, s)
+		}
+	case *ast.IfExpression:
+		r.resolve(node.Condition, s)
+		r.resolve(node.Consequence, s)
+		if node.Alternative != nil {
+			r.resolve(node.Alternative, s)
+		}
+	case *ast.TryExpression:
+		r.resolve(node.Block, s)
+		if node.Catch != nil {
+			catch := r.enter(s)
+			node.CatchScope = catch.frame
+			r.declare(node.Param, catch, true)
+			r.resolve(node.Catch, catch)
+		}
+		if node.Finally != nil {
+			r.resolve(node.Finally, s)
+		}
+	case *ast.FunctionLiteral:
+		r.resolveFunction(node, s)
+	case *ast.MacroLiteral:
+		// Macros are defined and expanded before resolving, and any left
+		// fail to evaluate
+	case *ast.CallExpression:
+		if node.IsCallTo("quote") {
+			r.resolveQuoted(node, s)
+			return
+		}
+
+		if member, ok := node.Function.(*ast.MemberExpression); ok {
+			// x.f() may call the function f in scope
+			r.resolve(member.Object, s)
+			r.references = append(r.references, reference{ident: member.Property, scope: s, method: true})
+		} else {
+			r.resolve(node.Function, s)
+		}
+		for _, arg := range node.Arguments {
+			r.resolve(arg, s)
+		}
+	case *ast.NamedArgument:
+		r.resolve(node.Value, s)
+	case *ast.SpreadExpression:
+		r.resolve(node.Value, s)
+	case *ast.MemberExpression:
+		r.resolve(node.Object, s)
+	case *ast.IndexExpression:
+		r.resolve(node.Left, s)
+		r.resolve(node.Index, s)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.resolve(el, s)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			r.resolve(pair.Key, s)
+			r.resolve(pair.Value, s)
+		}
+	case *ast.MatchExpression:
+		r.resolve(node.Subject, s)
+		for _, arm := range node.Arms {
+			armScope := r.enter(s)
+			arm.Scope = armScope.frame
+			r.declarePattern(arm.Pattern, armScope, false)
+			if arm.Guard != nil {
+				r.resolve(arm.Guard, armScope)
+			}
+			r.resolve(arm.Body, armScope)
+		}
+	case *ast.YieldExpression:
+		if node.Value != nil {
+			r.resolve(node.Value, s)
+		}
+	case *ast.ForExpression:
+		r.resolve(node.Iterable, s)
+		body := r.enter(s)
+		node.Scope = body.frame
+		r.declarePattern(node.Pattern, body, false)
+		r.resolve(node.Body, body)
+	case *ast.SpawnExpression:
+		r.resolve(node.Call, s)
+	case *ast.SelectExpression:
+		for _, c := range node.Cases {
+			// The operation names recv or send, not a variable
+			if c.Operation != nil {
+				for _, arg := range c.Operation.Arguments {
+					r.resolve(arg, s)
+				}
+			}
+
+			caseScope := r.enter(s)
+			c.Scope = caseScope.frame
+			if c.Name != nil {
+				r.declare(c.Name, caseScope, false)
+			}
+			r.resolve(c.Body, caseScope)
+		}
+	}
+}
+
+// resolveFunction declares the parameters of fn in a new scope. Default
+// values are resolved in that scope too, as they are evaluated in it.
+func (r *resolver) resolveFunction(fn *ast.FunctionLiteral, s *scope) {
+	if fn.Name != nil {
+		r.declare(fn.Name, s, true)
+	}
+
+	body := r.enter(s)
+	body.function = true
+	fn.Scope = body.frame
+
+	for _, param := range fn.Parameters {
+		if dp, ok := param.(*ast.DefaultParameter); ok {
+			r.resolve(dp.Default, body)
+			param = dp.Parameter
+		}
+		r.declarePattern(param, body, true)
+	}
+
+	if fn.Rest != nil {
+		r.declare(fn.Rest, body, true)
+	}
+
+	r.resolve(fn.Body, body)
+}
+
+// resolveQuoted resolves the arguments of the unquote calls in the source
+// quoted by call, the only expressions there evaluated in s.
+func (r *resolver) resolveQuoted(call *ast.CallExpression, s *scope) {
+	for _, arg := range call.Arguments {
+		ast.Inspect(arg, func(node ast.Node) bool {
+			unquote, ok := node.(*ast.CallExpression)
+			if !ok || !unquote.IsCallTo("unquote") {
+				return true
+			}
+
+			for _, arg := range unquote.Arguments {
+				r.resolve(arg, s)
+			}
+			return false
+		})
+	}
+}
+
+// declarePattern declares the variables bound by pattern in s. Struct and
+// variant names in the pattern are uses of those names.
+func (r *resolver) declarePattern(pattern ast.Expression, s *scope, silent bool) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		// A unit variant matches itself rather than binding a variable
+		if isVariant(pattern.Value, s) {
+			r.refer(pattern, s)
+			return
+		}
+		r.declare(pattern, s, silent)
+	case *ast.ArrayPattern:
+		for _, el := range pattern.Elements {
+			r.declarePattern(el, s, silent)
+		}
+		if pattern.Rest != nil {
+			r.declare(pattern.Rest, s, silent)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			r.declarePattern(pair.Value, s, silent)
+		}
+	case *ast.StructPattern:
+		r.refer(pattern.Name, s)
+		r.declarePattern(pattern.Fields, s, silent)
+	case *ast.VariantPattern:
+		if pattern.Enum != nil {
+			r.refer(pattern.Enum, s)
+		} else {
+			r.refer(pattern.Name, s)
+		}
+		for _, arg := range pattern.Arguments {
+			r.declarePattern(arg, s, silent)
+		}
+	}
+}
+
+// isVariant reports whether name is a unit variant of an enum declared in s
+// or its enclosing scopes so far.
+func isVariant(name string, s *scope) bool {
+	for ; s != nil; s = s.outer {
+		if s.variants[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// inFunction reports whether s is or is nested in the scope of a function
+// body, evaluated when the function is called rather than where it is.
+func inFunction(s *scope) bool {
+	for ; s != nil; s = s.outer {
+		if s.function {
+			return true
+		}
+	}
+	return false
+}
+
+// finish binds the references recorded, now that every scope is known, and
+// reports undefined and unused variables.
+func (r *resolver) finish() []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for _, ref := range r.references {
+		name := ref.ident.Value
+		depth := 0
+
+		s := ref.scope
+		for ; s.outer != nil; s = s.outer {
+			if v, ok := s.variables[name]; ok {
+				v.used = true
+				ref.ident.Binding = &ast.Binding{Depth: depth, Slot: v.slot}
+				r.declarations[ref.ident] = v.ident
+				break
+			}
+			depth++
+		}
+
+		if s.outer != nil {
+			continue
+		}
+
+		if v, ok := s.variables[name]; ok {
+			r.declarations[ref.ident] = v.ident
+		}
+
+		if ref.method {
+			continue
+		}
+
+		// Top-level variables are looked up by name
+		ref.ident.Binding = &ast.Binding{Depth: depth, Slot: -1}
+
+		if _, ok := s.variables[name]; ok || r.global(name) {
+			continue
+		}
+
+		severity := Error
+		if r.line && inFunction(ref.scope) {
+			severity = Warning
+		}
+
+		diagnostics = append(diagnostics, Diagnostic{
+			Token:    ref.ident.Token,
+			Severity: severity,
+			Message:  "undefined variable " + name,
+		})
+	}
+
+	for _, s := range r.scopes {
+		for _, v := range s.order {
+			if v.used || v.silent || strings.HasPrefix(v.ident.Value, "_") {
+				continue
+			}
+
+			diagnostics = append(diagnostics, Diagnostic{
+				Token:    v.ident.Token,
+				Severity: Warning,
+				Message:  "unused variable " + v.ident.Value,
+			})
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Token, diagnostics[j].Token
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	return diagnostics
+}
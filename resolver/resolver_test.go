@@ -0,0 +1,193 @@
+package resolver
+
+import (
+	"arkham/ast"
+	"arkham/lexer"
+	"arkham/parser"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveBindings(t *testing.T) {
+	program := parse(t, "let g = 1; let f = fn(a) { let c = 2; fn(b) { a + b + c + g } };")
+	require.Empty(t, Resolve(program, nothing))
+
+	outer := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	assert.Equal(t, []string{"a", "c"}, outer.Scope.Names)
+	assert.Equal(t, &ast.Binding{Depth: 0, Slot: 0}, outer.Parameters[0].(*ast.Identifier).Binding)
+
+	inner := outer.Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	assert.Equal(t, []string{"b"}, inner.Scope.Names)
+
+	// ((a + b) + c) + g
+	sum := inner.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	g := sum.Right.(*ast.Identifier)
+	c := sum.Left.(*ast.InfixExpression).Right.(*ast.Identifier)
+	b := sum.Left.(*ast.InfixExpression).Left.(*ast.InfixExpression).Right.(*ast.Identifier)
+	a := sum.Left.(*ast.InfixExpression).Left.(*ast.InfixExpression).Left.(*ast.Identifier)
+
+	assert.Equal(t, &ast.Binding{Depth: 1, Slot: 0}, a.Binding)
+	assert.Equal(t, &ast.Binding{Depth: 0, Slot: 0}, b.Binding)
+	assert.Equal(t, &ast.Binding{Depth: 1, Slot: 1}, c.Binding)
+	assert.Equal(t, &ast.Binding{Depth: 2, Slot: -1}, g.Binding)
+
+	// Top-level variables are bound by name
+	assert.Nil(t, program.Statements[0].(*ast.LetStatement).Name.Binding)
+}
+
+func TestResolveScopes(t *testing.T) {
+	program := parse(t, `fn(x) {
+		let [a, ...rest] = x;
+		match (a) { {k: v} if v => v, _ => 0 };
+		for (i in rest) { i };
+		try { 1 } catch (e) { e };
+		if (a) { let y = 1; y }
+	}`)
+	Resolve(program, nothing)
+
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	assert.Equal(t, []string{"x", "a", "rest", "y"}, fn.Scope.Names)
+
+	body := fn.Body.Statements
+	match := body[1].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	assert.Equal(t, []string{"v"}, match.Arms[0].Scope.Names)
+	assert.Empty(t, match.Arms[1].Scope.Names)
+
+	forLoop := body[2].(*ast.ExpressionStatement).Expression.(*ast.ForExpression)
+	assert.Equal(t, []string{"i"}, forLoop.Scope.Names)
+	// The iterable is evaluated outside the loop body
+	assert.Equal(t, &ast.Binding{Depth: 0, Slot: 2}, forLoop.Iterable.(*ast.Identifier).Binding)
+
+	try := body[3].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+	assert.Equal(t, []string{"e"}, try.CatchScope.Names)
+}
+
+func TestResolveDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", []string{}},
+		{"y", []string{"error 1:1: undefined variable y"}},
+		{"len([1])", []string{}},
+		{"known + 1", []string{}},
+		{"let f = fn() { g() }; let g = fn() { 1 }", []string{}},
+		{"let f = fn() { let x = 1; 2 }", []string{"warning 1:20: unused variable x"}},
+		{"let f = fn(unused) { let _x = 1; 2 }", []string{}},
+		{"let f = fn() { let [a, b] = [1, 2]; a }", []string{"warning 1:24: unused variable b"}},
+		{"fn(x) { match (x) { n => 1 } }", []string{"warning 1:21: unused variable n"}},
+		{"fn(x) { match (x) { [h, ..._] => h } }", []string{}},
+		{"fn(x) { x.missing() }", []string{}},
+		{"fn() { let double = fn(x) { x * 2 }; 2.double() }", []string{}},
+		{"fn() { m.x }", []string{"error 1:8: undefined variable m"}},
+		{"fn(x) { match (x) { Point{x: px} => px } }", []string{"error 1:21: undefined variable Point"}},
+		{"fn(x) { enum E { A, B }; match (x) { A => 1, B => 2 } }", []string{}},
+		{"fn(x) { match (x) { Circle(r) => r } }", []string{"error 1:21: undefined variable Circle"}},
+		{"fn(ch) { select { recv(ch) as v => 1, _ => 2 } }", []string{"warning 1:31: unused variable v"}},
+		{"let f = fn(y = z) { y }", []string{"error 1:16: undefined variable z"}},
+		{"a + b", []string{"error 1:1: undefined variable a", "error 1:5: undefined variable b"}},
+		{"quote(a + unquote(b))", []string{"error 1:19: undefined variable b"}},
+		{"fn(x) { let y = 1; quote(x + unquote(y)) }", []string{}},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+
+		diagnostics := Resolve(program, func(name string) bool { return name == "len" || name == "known" })
+
+		got := []string{}
+		for _, d := range diagnostics {
+			severity := "error"
+			if d.Severity == Warning {
+				severity = "warning"
+			}
+			got = append(got, severity+" "+d.String())
+		}
+
+		assert.Equal(t, tt.expected, got, "input: %s", tt.input)
+	}
+}
+
+func TestResolveLine(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fn() { a + b }", []string{"warning 1:16: undefined variable a", "warning 1:20: undefined variable b"}},
+		{"let f = fn(x = y) { x }", []string{"warning 1:16: undefined variable y"}},
+		{"let f = fn() { match (1) { _ => z } }", []string{"warning 1:33: undefined variable z"}},
+		{"a + 1", []string{"error 1:1: undefined variable a"}},
+		{"match (1) { _ => z }", []string{"error 1:18: undefined variable z"}},
+		{"fn() { 1 }(missing)", []string{"error 1:12: undefined variable missing"}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, d := range ResolveLine(parse(t, tt.input), nothing) {
+			severity := "error"
+			if d.Severity == Warning {
+				severity = "warning"
+			}
+			got = append(got, severity+" "+d.String())
+		}
+
+		assert.Equal(t, tt.expected, got, "input: %s", tt.input)
+	}
+}
+
+func TestResolveDeclarations(t *testing.T) {
+	program := parse(t, `let f = fn(a) { let a = a + 1; a }
+let g = fn() { f(1) }
+let f = 2
+fn(x) { match (x) { [y] => y } }
+fn(o) { o.f() }
+undefined`)
+	_, declarations := ResolveDeclarations(program, nothing)
+
+	declared := func(ident *ast.Identifier) string {
+		decl, ok := declarations[ident]
+		if !ok {
+			return "none"
+		}
+		return fmt.Sprintf("%s %d:%d", decl.Value, decl.Token.Line, decl.Token.Column)
+	}
+
+	uses := []string{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok {
+			uses = append(uses, fmt.Sprintf("%s %d:%d -> %s", ident.Value, ident.Token.Line, ident.Token.Column, declared(ident)))
+		}
+		return true
+	})
+
+	assert.Equal(t, []string{
+		"f 1:5 -> f 1:5",
+		"a 1:12 -> a 1:12",
+		"a 1:21 -> a 1:12",
+		"a 1:25 -> a 1:12",
+		"a 1:32 -> a 1:12",
+		"g 2:5 -> g 2:5",
+		"f 2:16 -> f 1:5",
+		"f 3:5 -> f 1:5",
+		"x 4:4 -> x 4:4",
+		"x 4:16 -> x 4:4",
+		"y 4:22 -> y 4:22",
+		"y 4:28 -> y 4:22",
+		"o 5:4 -> o 5:4",
+		"o 5:9 -> o 5:4",
+		"f 5:11 -> f 1:5",
+		"undefined 6:1 -> none",
+	}, uses)
+}
+
+func nothing(string) bool { return false }
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), "input: %s", input)
+	return program
+}
@@ -1,21 +1,32 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // only returned by a lexer made with NewWithComments
 
 	// Identifiers + literals
 	IDENT  = "IDENT" // add, foobar, x, y, ...
 	INT    = "INT"   // 1343456
 	STRING = "STRING"
 
+	// Parts of an interpolated string "a${x}b${y}c": STRING_START is "a",
+	// STRING_MIDDLE is "b" and STRING_END is "c". The tokens of each embedded
+	// expression come in between.
+	STRING_START  = "STRING_START"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_END    = "STRING_END"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
 	MINUS    = "-"
 	BANG     = "!"
+	QUESTION = "?"
 	ASTERISK = "*"
 	SLASH    = "/"
 
@@ -25,15 +36,24 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	ARROW = "=>"
+	PIPE  = "|>"
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
+	ELLIPSIS  = "..."
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
 
+	LBRACKET = "["
+	RBRACKET = "]"
+
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -42,21 +62,63 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MATCH    = "MATCH"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	IMPORT   = "IMPORT"
+	AS       = "AS"
+	STRUCT   = "STRUCT"
+	ENUM     = "ENUM"
+	YIELD    = "YIELD"
+	FOR      = "FOR"
+	IN       = "IN"
+	SPAWN    = "SPAWN"
+	SELECT   = "SELECT"
+	MACRO    = "MACRO"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line of the first character, 0 if unknown
+	Column  int // 1-based column of the first character, 0 if unknown
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"match":   MATCH,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
+	"import":  IMPORT,
+	"as":      AS,
+	"struct":  STRUCT,
+	"enum":    ENUM,
+	"yield":   YIELD,
+	"for":     FOR,
+	"in":      IN,
+	"spawn":   SPAWN,
+	"select":  SELECT,
+	"macro":   MACRO,
+}
+
+// Keywords returns the keywords in alphabetical order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(ident string) TokenType {